@@ -1,16 +1,100 @@
 package iginx
 
 import (
-	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/iot"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/iot"
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
 	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
 )
 
 // BaseGenerator contains settings specific for Iginx
 type BaseGenerator struct {
+	// PathTemplate is the layout the data was loaded with, see pathtemplate.Template
+	PathTemplate string
+	// TagMode is the tag mode the data was loaded with, with
+	// pathtemplate.TagModeIginx queries filter on tags with WITH clauses
+	TagMode string
+
+	template *pathtemplate.Template
+}
+
+// initTemplate parses the path template the queries are generated for.
+func (g *BaseGenerator) initTemplate() error {
+	t, err := pathtemplate.ParseWithTagMode(g.PathTemplate, g.TagMode)
+	if err != nil {
+		return err
+	}
+	g.template = t
+	return nil
+}
+
+// series returns the FROM and WITH clauses selecting the series of
+// measurement whose tags have any of the given sets of values. tagKeys are
+// all the tag keys of the measurement in the order they are generated, tags
+// without a value match anything. Tags in the path are matched by the FROM
+// paths, the others by the WITH clause, which is empty unless the data was
+// loaded with IginX tags.
+func (g *BaseGenerator) series(measurement string, tagKeys []string, valueSets ...map[string]string) (string, string) {
+	paths, filters := g.selection(measurement, tagKeys, valueSets)
+	from := strings.Join(paths, ", ")
+	if len(filters) == 0 {
+		return from, ""
+	}
+	conditions := make([]string, len(filters))
+	for i, filter := range filters {
+		terms := make([]string, len(filter))
+		for j, tag := range filter {
+			terms[j] = fmt.Sprintf("%s=%s", tag.Key, tag.Value)
+		}
+		conditions[i] = strings.Join(terms, " AND ")
+		if len(filters) > 1 && len(terms) > 1 {
+			conditions[i] = "(" + conditions[i] + ")"
+		}
+	}
+	return from, " WITH " + strings.Join(conditions, " OR ")
+}
+
+// selection returns the path patterns and the IginX tag filters, any of which
+// must match, selecting the series of measurement whose tags have any of the
+// given sets of values, see series. There are no filters if the data was not
+// loaded with IginX tags or if a set of values matches all series.
+func (g *BaseGenerator) selection(measurement string, tagKeys []string, valueSets []map[string]string) ([]string, [][]pathtemplate.Tag) {
+	if len(valueSets) == 0 {
+		valueSets = []map[string]string{nil}
+	}
+	var paths []string
+	var filters [][]pathtemplate.Tag
+	seen := map[string]bool{}
+	matchAll := false
+	for _, values := range valueSets {
+		tags := make([]pathtemplate.Tag, len(tagKeys))
+		for i, k := range tagKeys {
+			tags[i].Key = []byte(k)
+			if v, ok := values[k]; ok {
+				tags[i].Value = []byte(v)
+			}
+		}
+		if p := g.template.Pattern(measurement, tags); !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+
+		filter := g.template.IginxTags(tags)
+		if len(filter) == 0 {
+			matchAll = true
+			continue
+		}
+		filters = append(filters, filter)
+	}
+	if matchAll {
+		return paths, nil
+	}
+	return paths, filters
 }
 
 // GenerateEmptyQuery returns an empty query.Iginx.
@@ -26,8 +110,26 @@ func (g *BaseGenerator) fillInQuery(qi query.Query, humanLabel, humanDesc, sql s
 	q.SqlQuery = []byte(sql)
 }
 
+// addStatement appends a statement to the plan of the query, run after the
+// previous ones and joined on path with them.
+func (g *BaseGenerator) addStatement(qi query.Query, sql string) {
+	q := qi.(*query.Iginx)
+	q.Statements = append(q.Statements, []byte(sql))
+}
+
+// setReduction has the runner complete the query with the given client-side
+// reduction of its result, see query.IginxReduction.
+func (g *BaseGenerator) setReduction(qi query.Query, r *query.IginxReduction) {
+	r.PathTemplate = g.PathTemplate
+	r.TagMode = g.TagMode
+	qi.(*query.Iginx).Reduction = r
+}
+
 // NewDevops creates a new devops use case query generator.
 func (g *BaseGenerator) NewDevops(start, end time.Time, scale int) (utils.QueryGenerator, error) {
+	if err := g.initTemplate(); err != nil {
+		return nil, err
+	}
 	core, err := devops.NewCore(start, end, scale)
 
 	if err != nil {
@@ -42,8 +144,27 @@ func (g *BaseGenerator) NewDevops(start, end time.Time, scale int) (utils.QueryG
 	return devops, nil
 }
 
+// NewDevopsGeneric creates a new devops-generic use case query generator.
+func (g *BaseGenerator) NewDevopsGeneric(start, end time.Time, scale int, maxMetricCount uint64) (utils.QueryGenerator, error) {
+	if err := g.initTemplate(); err != nil {
+		return nil, err
+	}
+	core, err := devops.NewGenericCore(start, end, scale, maxMetricCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DevopsGeneric{
+		BaseGenerator: g,
+		GenericCore:   core,
+	}, nil
+}
+
 // NewIoT creates a new iot use case query generator.
 func (g *BaseGenerator) NewIoT(start, end time.Time, scale int) (utils.QueryGenerator, error) {
+	if err := g.initTemplate(); err != nil {
+		return nil, err
+	}
 	core, err := iot.NewCore(start, end, scale)
 
 	if err != nil {
@@ -0,0 +1,72 @@
+package iginx
+
+import (
+	"testing"
+
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+func TestSeries(t *testing.T) {
+	keys := []string{"name", "fleet", "driver"}
+	cases := []struct {
+		desc      string
+		template  string
+		tagMode   string
+		valueSets []map[string]string
+		wantFrom  string
+		wantWith  string
+	}{
+		{
+			desc:     "all series",
+			wantFrom: "readings.*",
+		},
+		{
+			desc:      "tags in path",
+			valueSets: []map[string]string{{"fleet": "East"}},
+			wantFrom:  "readings.*.East.*",
+		},
+		{
+			desc:      "several trucks in path",
+			valueSets: []map[string]string{{"name": "truck_1"}, {"name": "truck_2"}},
+			wantFrom:  "readings.truck_1.*, readings.truck_2.*",
+		},
+		{
+			desc:      "named tag in path",
+			template:  "{measurement}.{fleet}.{field}",
+			valueSets: []map[string]string{{"fleet": "East", "driver": "Trish"}},
+			wantFrom:  "readings.East",
+		},
+		{
+			desc:      "iginx tags",
+			tagMode:   pathtemplate.TagModeIginx,
+			valueSets: []map[string]string{{"fleet": "East"}},
+			wantFrom:  "readings",
+			wantWith:  " WITH fleet=East",
+		},
+		{
+			desc:      "several trucks with iginx tags",
+			tagMode:   pathtemplate.TagModeIginx,
+			valueSets: []map[string]string{{"name": "truck_1", "fleet": "East"}, {"name": "truck_2"}},
+			wantFrom:  "readings",
+			wantWith:  " WITH (fleet=East AND name=truck_1) OR name=truck_2",
+		},
+		{
+			desc:      "iginx tags with named tag in path",
+			template:  "{measurement}.{name}.{field}",
+			tagMode:   pathtemplate.TagModeIginx,
+			valueSets: []map[string]string{{"name": "truck_1", "fleet": "East"}},
+			wantFrom:  "readings.truck_1",
+			wantWith:  " WITH fleet=East",
+		},
+	}
+	for _, c := range cases {
+		g := &BaseGenerator{PathTemplate: c.template, TagMode: c.tagMode}
+		if err := g.initTemplate(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		from, with := g.series("readings", keys, c.valueSets...)
+		if from != c.wantFrom || with != c.wantWith {
+			t.Errorf("%s: got %q %q want %q %q", c.desc, from, with, c.wantFrom, c.wantWith)
+		}
+	}
+}
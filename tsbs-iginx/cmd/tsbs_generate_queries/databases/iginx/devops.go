@@ -6,9 +6,18 @@ import (
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	"github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/pkg/query"
 )
 
+const devopsCPUTable = "cpu"
+
+// hostTagKeys are the tags of a host in the order they are generated.
+var hostTagKeys = []string{
+	"hostname", "region", "datacenter", "rack", "os", "arch",
+	"team", "service", "service_version", "service_environment",
+}
+
 func panicIfErr(err error) {
 	if err != nil {
 		panic(err.Error())
@@ -25,75 +34,120 @@ type Devops struct {
 // a set of column idents.
 //
 // For instance:
-//      max(cpu_time) AS max_cpu_time
-func (d *Devops) getSelectAggClauses(aggFunc string, idents []string) []string {
+//
+//	max(usage_user), max(usage_system)
+func (d *Devops) getSelectAggClauses(aggFunc string, idents []string) string {
 	selectAggClauses := make([]string, len(idents))
 	for i, ident := range idents {
-		selectAggClauses[i] =
-			fmt.Sprintf("%[1]s(%[2]s) AS %[1]s_%[2]s", aggFunc, ident)
+		selectAggClauses[i] = fmt.Sprintf("%s(%s)", aggFunc, ident)
 	}
-	return selectAggClauses
+	return strings.Join(selectAggClauses, ", ")
 }
 
-// MaxAllCPU selects the MAX of all metrics under 'cpu' per hour for N random
-// hosts
-//
-// Queries:
-// cpu-max-all-1
-// cpu-max-all-8
-func (d *Devops) MaxAllCPU(qi query.Query, nHosts int, duration time.Duration) {
-	interval := d.Interval.MustRandWindow(duration)
+// getRandomHostsValues returns the tag values selecting nHosts random hosts,
+// or none, which selects all hosts, if nHosts is 0.
+func (d *Devops) getRandomHostsValues(nHosts int) []map[string]string {
+	if nHosts == 0 {
+		return nil
+	}
 	hosts, err := d.GetRandomHosts(nHosts)
-	metrics := devops.GetAllCPUMetrics()
 	panicIfErr(err)
+	return hostsValues(hosts)
+}
 
-	sql := fmt.Sprintf(`{
-  		"start_absolute": %d,
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-
-		interval.StartUnixMillis(),
-		interval.EndUnixMillis())
-	i := 0
-	for i = 0; i < len(metrics); i++ {
-		sql += fmt.Sprintf(`
+// getRandomHostsSeries returns the FROM and WITH clauses selecting the cpu
+// series of nHosts random hosts, or of all hosts if nHosts is 0.
+func (d *Devops) getRandomHostsSeries(nHosts int) (string, string) {
+	return d.series(devopsCPUTable, hostTagKeys, d.getRandomHostsValues(nHosts)...)
+}
 
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "max",
-				"tags": {
-            		"hostname": [
-              			"%s"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "hours"
-          		}
-        	}]
-    	}
-	`,
-			metrics[i],
-			strings.Join(hosts, "\", \""))
-		if i != len(metrics)-1 {
-			sql += ","
-		}
+// hostsValues returns the tag values selecting the given hosts.
+func hostsValues(hosts []string) []map[string]string {
+	valueSets := make([]map[string]string, len(hosts))
+	for i, host := range hosts {
+		valueSets[i] = map[string]string{"hostname": host}
 	}
-	sql += "]}"
-	humanLabel := devops.GetMaxAllLabel("Iginx", nHosts)
+	return valueSets
+}
+
+// groupByWindows returns the clause downsampling the interval into windows
+// of the given length, e.g. GROUP [1451606400000, 1451610000000) BY 1m
+func groupByWindows(interval *utils.TimeInterval, window string) string {
+	return fmt.Sprintf(" GROUP [%d, %d) BY %s", interval.StartUnixMillis(), interval.EndUnixMillis(), window)
+}
+
+// GroupByTime selects the MAX for numMetrics metrics under 'cpu',
+// per minute for nhosts hosts,
+// e.g. in pseudo-SQL:
+//
+// SELECT minute, max(metric1), ..., max(metricN)
+// FROM cpu
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY minute ORDER BY minute ASC
+//
+// Resultsets:
+// single-groupby-1-1-12
+// single-groupby-1-1-1
+// single-groupby-1-8-1
+// single-groupby-5-1-12
+// single-groupby-5-1-1
+// single-groupby-5-8-1
+func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange time.Duration) {
+	interval := d.Interval.MustRandWindow(timeRange)
+	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
+	panicIfErr(err)
+	hosts := d.getRandomHostsValues(nHosts)
+	from, with := d.series(devopsCPUTable, hostTagKeys, hosts...)
+
+	sql := fmt.Sprintf("SELECT %s FROM %s%s%s",
+		d.getSelectAggClauses("max", metrics), from, with, groupByWindows(interval, "1m"))
+
+	humanLabel := fmt.Sprintf(
+		"Iginx %d cpu metric(s), random %4d hosts, random %s by 1m",
+		numMetrics, nHosts, timeRange)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	d.setRestQuery(qi, devopsCPUTable, hostTagKeys, hosts, "max", metrics, interval, "1m")
+}
+
+// GroupByOrderByLimit populates a query.Query that has a time WHERE clause,
+// that groups by a truncated date, orders by that date, and takes a limit:
+// SELECT time_bucket('1 minute', time) AS t, MAX(cpu)
+// FROM cpu
+// WHERE time < '$TIME'
+// GROUP BY t ORDER BY t DESC
+// LIMIT $LIMIT
+//
+// IginX has no ORDER BY nor LIMIT over windows and aggregates the series of
+// each host on its own, so the hour before the end time is downsampled and
+// the runner takes the max across hosts of each window and keeps the 5
+// latest windows with data.
+//
+// Queries:
+// groupby-orderby-limit
+func (d *Devops) GroupByOrderByLimit(qi query.Query) {
+	interval := d.Interval.MustRandWindow(time.Hour)
+	from, with := d.getRandomHostsSeries(0)
+
+	sql := fmt.Sprintf("SELECT max(usage_user) FROM %s%s%s", from, with, groupByWindows(interval, "1m"))
+
+	humanLabel := "Iginx max cpu over last 5 min-intervals (random end)"
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.EndString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	d.setReduction(qi, &query.IginxReduction{Kind: query.IginxReduceTopK, Field: "usage_user", Limit: 5})
 }
 
-// GroupByTimeAndPrimaryTag selects the AVG of metrics in the group `cpu` per device
-// per hour for a day
+// GroupByTimeAndPrimaryTag selects the AVG of numMetrics metrics under 'cpu' per device per hour for a day,
+// e.g. in pseudo-SQL:
+//
+// SELECT AVG(metric1), ..., AVG(metricN)
+// FROM cpu
+// WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hour, hostname ORDER BY hour, hostname
+//
+// IginX aggregates every series on its own, so the series of each host come
+// back as separate columns.
 //
 // Queries:
 // double-groupby-1
@@ -103,106 +157,41 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
 	interval := d.Interval.MustRandWindow(devops.DoubleGroupByDuration)
+	from, with := d.getRandomHostsSeries(0)
 
-	sql := fmt.Sprintf(`{
-  		"start_absolute": %d,
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-		interval.StartUnixMillis(),
-		interval.EndUnixMillis())
-	i := 0
-	for i = 0; i < len(metrics); i++ {
-		sql += fmt.Sprintf(`
-
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "avg",
-				"tags": {
-            		"hostname": [
-              			"*"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "hours"
-          		}
-        	}]
-    	}
-	`,
-			metrics[i])
-		if i != len(metrics)-1 {
-			sql += ","
-		}
-	}
-	sql += "]}"
+	sql := fmt.Sprintf("SELECT %s FROM %s%s%s",
+		d.getSelectAggClauses("avg", metrics), from, with, groupByWindows(interval, "1h"))
 
 	humanLabel := devops.GetDoubleGroupByLabel("Iginx", numMetrics)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	d.setRestQuery(qi, devopsCPUTable, hostTagKeys, nil, "avg", metrics, interval, "1h")
 }
 
-// GroupByOrderByLimit populates a query.Query that has a time WHERE clause,
-// that groups by a truncated date, orders by that date, and takes a limit:
+// MaxAllCPU selects the MAX of all metrics under 'cpu' per hour for nhosts hosts,
+// e.g. in pseudo-SQL:
+//
+// SELECT MAX(metric1), ..., MAX(metricN)
+// FROM cpu WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hour ORDER BY hour
 //
 // Queries:
-// groupby-orderby-limit
-func (d *Devops) GroupByOrderByLimit(qi query.Query) {
-	interval := d.Interval.MustRandWindow(time.Hour)
-
-	metrics := [1]string{"usage_user"}
-
-	sql := fmt.Sprintf(`{
-  		"start_relative": {
-		"value": "5",
-		"unit": "minutes"
-	},
-
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-		interval.EndUnixMillis())
-	i := 0
-	for i = 0; i < len(metrics); i++ {
-		sql += fmt.Sprintf(`
+// cpu-max-all-1
+// cpu-max-all-8
+func (d *Devops) MaxAllCPU(qi query.Query, nHosts int, duration time.Duration) {
+	interval := d.Interval.MustRandWindow(duration)
+	metrics := devops.GetAllCPUMetrics()
+	hosts := d.getRandomHostsValues(nHosts)
+	from, with := d.series(devopsCPUTable, hostTagKeys, hosts...)
 
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "max",
-				"tags": {
-            		"hostname": [
-              			"*"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "minutes"
-          		}
-        	}]
-    	}
-	`,
-			metrics[i])
-		if i != len(metrics)-1 {
-			sql += ","
-		}
-	}
-	sql += "]}"
+	sql := fmt.Sprintf("SELECT %s FROM %s%s%s",
+		d.getSelectAggClauses("max", metrics), from, with, groupByWindows(interval, "1h"))
 
-	humanLabel := "Iginx max cpu over last 5 min-intervals (random end)"
-	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.EndString())
+	humanLabel := devops.GetMaxAllLabel("Iginx", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	d.setRestQuery(qi, devopsCPUTable, hostTagKeys, hosts, "max", metrics, interval, "1h")
 }
 
 // LastPointPerHost finds the last row for every host in the dataset
@@ -211,43 +200,9 @@ func (d *Devops) GroupByOrderByLimit(qi query.Query) {
 // lastpoint
 func (d *Devops) LastPointPerHost(qi query.Query) {
 	metrics := devops.GetAllCPUMetrics()
+	from, with := d.getRandomHostsSeries(0)
 
-	sql := fmt.Sprintf(`{
-  		"start_absolute": 0,
-  		"end_absolute": 2000000000000,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`)
-	i := 0
-	for i = 0; i < len(metrics); i++ {
-		sql += fmt.Sprintf(`
-
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "last",
-				"tags": {
-            		"hostname": [
-              			"*"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "years"
-          		}
-        	}]
-    	}
-	`,
-			metrics[i])
-		if i != len(metrics)-1 {
-			sql += ","
-		}
-	}
-	sql += "]}"
+	sql := fmt.Sprintf("SELECT %s FROM %s%s", d.getSelectAggClauses("last", metrics), from, with)
 
 	humanLabel := "Iginx last row per host"
 	humanDesc := humanLabel
@@ -256,187 +211,52 @@ func (d *Devops) LastPointPerHost(qi query.Query) {
 
 // HighCPUForHosts populates a query that gets CPU metrics when the CPU has
 // high usage between a time period for a number of hosts (if 0, it will
-// search all hosts)
+// search all hosts),
+// e.g. in pseudo-SQL:
+//
+// SELECT * FROM cpu
+// WHERE usage_user > 90.0
+// AND time >= '$TIME_START' AND time < '$TIME_END'
+// AND (hostname = '$HOST' OR hostname = '$HOST2'...)
+//
+// A value filter on usage_user applies to the series of all the hosts of a
+// statement at once, so the plan has a statement per host, filtering its
+// values on the server, and the runner joins their results on path. With all
+// hosts, that would be a statement per host of the dataset, so instead the
+// runner filters the values of each host on its own.
 //
 // Queries:
 // high-cpu-1
 // high-cpu-all
 func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 	interval := d.Interval.MustRandWindow(devops.HighCPUDuration)
-	sql := ""
 	metrics := devops.GetAllCPUMetrics()
-
-	if nHosts > 0 {
-		hosts, err := d.GetRandomHosts(nHosts)
-		panicIfErr(err)
-
-		sql = fmt.Sprintf(`
-		      SELECT *
-		      FROM cpu
-		      WHERE usage_user > 90.0
-		       AND hostname IN ('%s')
-		       AND timestamp >= '%s'
-		       AND timestamp < '%s'`,
-			strings.Join(hosts, "', '"),
-			interval.StartString(),
-			interval.EndString())
-
-		sql = fmt.Sprintf(`{
-  		"start_absolute": %d,
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-			interval.StartUnixMillis(),
-			interval.EndUnixMillis())
-		i := 0
-		for i = 0; i < len(metrics); i++ {
-			sql += fmt.Sprintf(`
-
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "avg",
-				"tags": {
-            		"hostname": [
-              			"*"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "hours"
-          		}
-        	}]
-    	}
-	`,
-				metrics[i])
-			if i != len(metrics)-1 {
-				sql += ","
-			}
-		}
-		sql += "]}"
-
-	} else {
-		sql = fmt.Sprintf(`
-		      SELECT *
-		      FROM cpu
-		      WHERE usage_user > 90.0
-		       AND timestamp >= '%s'
-		       AND timestamp < '%s'`,
-			interval.StartString(),
-			interval.EndString())
-
-		sql = fmt.Sprintf(`{
-  		"start_absolute": %d,
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-			interval.StartUnixMillis(),
-			interval.EndUnixMillis())
-		i := 0
-		for i = 0; i < len(metrics); i++ {
-			sql += fmt.Sprintf(`
-
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "avg",
-				"tags": {
-            		"hostname": [
-              			"*"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "hours"
-          		}
-        	}]
-    	}
-	`,
-				metrics[i])
-			if i != len(metrics)-1 {
-				sql += ","
-			}
-		}
-		sql += "]}"
-
-	}
-
 	humanLabel, err := devops.GetHighCPULabel("Iginx", nHosts)
 	panicIfErr(err)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
-	d.fillInQuery(qi, humanLabel, humanDesc, sql)
-}
-
-// GroupByTime selects the MAX for metrics under 'cpu', per minute for N random
-// hosts
-//
-// Resultsets:
-// single-groupby-1-1-12
-// single-groupby-1-1-1
-// single-groupby-1-8-1
-// single-groupby-5-1-12
-// single-groupby-5-1-1
-// single-groupby-5-8-1
-func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange time.Duration) {
-	interval := d.Interval.MustRandWindow(timeRange)
-	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
-	panicIfErr(err)
-	hosts, err := d.GetRandomHosts(nHosts)
-	panicIfErr(err)
 
-	sql := fmt.Sprintf(`{
-  		"start_absolute": %d,
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-		interval.StartUnixMillis(),
-		interval.EndUnixMillis())
-	i := 0
-	for i = 0; i < len(metrics); i++ {
-		sql += fmt.Sprintf(`
+	if nHosts == 0 {
+		from, with := d.getRandomHostsSeries(0)
+		sql := fmt.Sprintf("SELECT %s FROM %s WHERE time >= %d AND time < %d%s",
+			strings.Join(metrics, ", "), from, interval.StartUnixMillis(), interval.EndUnixMillis(), with)
+		d.fillInQuery(qi, humanLabel, humanDesc, sql)
+		d.setReduction(qi, &query.IginxReduction{
+			Kind:      query.IginxReduceFilter,
+			TagKeys:   hostTagKeys,
+			Field:     "usage_user",
+			Threshold: 90,
+		})
+		return
+	}
 
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "max",
-				"tags": {
-            		"hostname": [
-              			"%s"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "minutes"
-          		}
-        	}]
-    	}
-	`,
-			metrics[i],
-			strings.Join(hosts, "\", \""))
-		if i != len(metrics)-1 {
-			sql += ","
+	for i, host := range d.getRandomHostsValues(nHosts) {
+		from, with := d.series(devopsCPUTable, hostTagKeys, host)
+		sql := fmt.Sprintf("SELECT %s FROM %s WHERE time >= %d AND time < %d AND usage_user > 90%s",
+			strings.Join(metrics, ", "), from, interval.StartUnixMillis(), interval.EndUnixMillis(), with)
+		if i == 0 {
+			d.fillInQuery(qi, humanLabel, humanDesc, sql)
+		} else {
+			d.addStatement(qi, sql)
 		}
 	}
-	sql += "]}"
-
-	humanLabel := fmt.Sprintf(
-		"Iginx %d cpu metric(s), random %4d hosts, random %s by 1m",
-		numMetrics, nHosts, timeRange)
-	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
-	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
@@ -0,0 +1,78 @@
+package iginx
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// DevopsGeneric produces Iginx-specific queries for the devops-generic use
+// case. Its hosts have zipf-distributed metric counts and half of them only
+// live for a while, so most metric_N series exist for few hosts and few
+// points: the sparse, high-cardinality series IginX has to place fragments
+// for.
+type DevopsGeneric struct {
+	*BaseGenerator
+	*devops.GenericCore
+}
+
+// GenericMetricAgg selects the average of a random generic metric per hour
+// over all the hosts that have it, e.g. in pseudo-SQL:
+//
+// SELECT hour, avg(metric_N) FROM generic_metrics
+// WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hour, hostname
+//
+// Queries:
+// generic-agg
+func (d *DevopsGeneric) GenericMetricAgg(qi query.Query, timeRange time.Duration) {
+	interval := d.Interval.MustRandWindow(timeRange)
+	metric, nHosts := d.GetRandomGenericMetric()
+	from, with := d.series(devops.GenericMetricsTableName, hostTagKeys)
+
+	sql := fmt.Sprintf("SELECT avg(%s) FROM %s%s%s", metric, from, with, groupByWindows(interval, "1h"))
+
+	humanLabel := fmt.Sprintf("Iginx mean of a generic metric, all hosts, random %s by 1h", timeRange)
+	humanDesc := fmt.Sprintf("%s: %s of %d hosts at %s", humanLabel, metric, nHosts, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	d.setRestQuery(qi, devops.GenericMetricsTableName, hostTagKeys, nil, "avg", []string{metric}, interval, "1h")
+}
+
+// GenericLastPoint finds the last value of a random generic metric for
+// every host that has it.
+//
+// Queries:
+// generic-lastpoint
+func (d *DevopsGeneric) GenericLastPoint(qi query.Query) {
+	metric, nHosts := d.GetRandomGenericMetric()
+	from, with := d.series(devops.GenericMetricsTableName, hostTagKeys)
+
+	sql := fmt.Sprintf("SELECT last(%s) FROM %s%s", metric, from, with)
+
+	humanLabel := "Iginx last generic metric per host"
+	humanDesc := fmt.Sprintf("%s: %s of %d hosts", humanLabel, metric, nHosts)
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// ShortLivedHosts selects the max of metric_0, which every host has, per hour
+// over the whole dataset for nHosts random short-lived hosts. Their series
+// only have points for part of the dataset.
+//
+// Queries:
+// generic-short-lived-1
+// generic-short-lived-8
+func (d *DevopsGeneric) ShortLivedHosts(qi query.Query, nHosts int) {
+	hosts, err := d.GetRandomShortLivedHosts(nHosts)
+	panicIfErr(err)
+	values := hostsValues(hosts)
+	from, with := d.series(devops.GenericMetricsTableName, hostTagKeys, values...)
+
+	sql := fmt.Sprintf("SELECT max(metric_0) FROM %s%s%s", from, with, groupByWindows(d.Interval, "1h"))
+
+	humanLabel := fmt.Sprintf("Iginx max of a generic metric, random %4d short-lived hosts, by 1h", nHosts)
+	humanDesc := humanLabel
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	d.setRestQuery(qi, devops.GenericMetricsTableName, hostTagKeys, values, "max", []string{"metric_0"}, d.Interval, "1h")
+}
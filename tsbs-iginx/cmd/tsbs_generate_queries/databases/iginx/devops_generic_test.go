@@ -0,0 +1,51 @@
+package iginx
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+func TestDevopsGenericQueries(t *testing.T) {
+	start := time.Unix(1451606400, 0)
+	end := start.Add(24 * time.Hour)
+
+	cases := []struct {
+		desc string
+		fn   func(d *DevopsGeneric, q query.Query)
+		want string
+	}{
+		{
+			desc: "generic-agg",
+			fn:   func(d *DevopsGeneric, q query.Query) { d.GenericMetricAgg(q, devops.GenericAggDuration) },
+			want: "SELECT avg(metric_6) FROM generic_metrics.* GROUP [1451628982646, 1451672182646) BY 1h",
+		},
+		{
+			desc: "generic-lastpoint",
+			fn:   func(d *DevopsGeneric, q query.Query) { d.GenericLastPoint(q) },
+			want: "SELECT last(metric_9) FROM generic_metrics.*",
+		},
+		{
+			desc: "generic-short-lived-1",
+			fn:   func(d *DevopsGeneric, q query.Query) { d.ShortLivedHosts(q, 1) },
+			want: "SELECT max(metric_0) FROM generic_metrics.host_5.* GROUP [1451606400000, 1451692800000) BY 1h",
+		},
+	}
+
+	for _, c := range cases {
+		rand.Seed(123)
+		b := &BaseGenerator{}
+		dq, err := b.NewDevopsGeneric(start, end, 10, 20)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		q := b.GenerateEmptyQuery()
+		c.fn(dq.(*DevopsGeneric), q)
+		if got := string(q.(*query.Iginx).SqlQuery); got != c.want {
+			t.Errorf("%s: incorrect query\ngot  %s\nwant %s", c.desc, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package iginx
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+func TestDevopsQueries(t *testing.T) {
+	start := time.Unix(1451606400, 0)
+	end := start.Add(24 * time.Hour)
+
+	cases := []struct {
+		desc          string
+		tagMode       string
+		fn            func(d *Devops, q query.Query)
+		want          string
+		wantReduction string
+		// wantStatements are the statements of the plan after want
+		wantStatements []string
+	}{
+		{
+			desc: "single-groupby-1-1-1",
+			fn:   func(d *Devops, q query.Query) { d.GroupByTime(q, 1, 1, time.Hour) },
+			want: "SELECT max(usage_user) FROM cpu.host_9.* GROUP [1451679382646, 1451682982646) BY 1m",
+		},
+		{
+			desc:    "single-groupby on 2 hosts with iginx tags",
+			tagMode: pathtemplate.TagModeIginx,
+			fn:      func(d *Devops, q query.Query) { d.GroupByTime(q, 2, 1, time.Hour) },
+			want:    "SELECT max(usage_user) FROM cpu WITH hostname=host_9 OR hostname=host_3 GROUP [1451679382646, 1451682982646) BY 1m",
+		},
+		{
+			desc: "cpu-max-all-1",
+			fn:   func(d *Devops, q query.Query) { d.MaxAllCPU(q, 1, devops.MaxAllDuration) },
+			want: "SELECT max(usage_user), max(usage_system), max(usage_idle), max(usage_nice), max(usage_iowait), max(usage_irq), max(usage_softirq), max(usage_steal), max(usage_guest), max(usage_guest_nice) FROM cpu.host_9.* GROUP [1451614582646, 1451643382646) BY 1h",
+		},
+		{
+			desc: "double-groupby-1",
+			fn:   func(d *Devops, q query.Query) { d.GroupByTimeAndPrimaryTag(q, 1) },
+			want: "SELECT avg(usage_user) FROM cpu.* GROUP [1451628982646, 1451672182646) BY 1h",
+		},
+		{
+			desc:          "groupby-orderby-limit",
+			fn:            func(d *Devops, q query.Query) { d.GroupByOrderByLimit(q) },
+			want:          "SELECT max(usage_user) FROM cpu.* GROUP [1451679382646, 1451682982646) BY 1m",
+			wantReduction: query.IginxReduceTopK,
+		},
+		{
+			desc: "lastpoint",
+			fn:   func(d *Devops, q query.Query) { d.LastPointPerHost(q) },
+			want: "SELECT last(usage_user), last(usage_system), last(usage_idle), last(usage_nice), last(usage_iowait), last(usage_irq), last(usage_softirq), last(usage_steal), last(usage_guest), last(usage_guest_nice) FROM cpu.*",
+		},
+		{
+			desc: "high-cpu-1",
+			fn:   func(d *Devops, q query.Query) { d.HighCPUForHosts(q, 1) },
+			want: "SELECT usage_user, usage_system, usage_idle, usage_nice, usage_iowait, usage_irq, usage_softirq, usage_steal, usage_guest, usage_guest_nice FROM cpu.host_9.* WHERE time >= 1451628982646 AND time < 1451672182646 AND usage_user > 90",
+		},
+		{
+			desc:    "high-cpu on 2 hosts with iginx tags",
+			tagMode: pathtemplate.TagModeIginx,
+			fn:      func(d *Devops, q query.Query) { d.HighCPUForHosts(q, 2) },
+			want:    "SELECT usage_user, usage_system, usage_idle, usage_nice, usage_iowait, usage_irq, usage_softirq, usage_steal, usage_guest, usage_guest_nice FROM cpu WHERE time >= 1451628982646 AND time < 1451672182646 AND usage_user > 90 WITH hostname=host_9",
+			wantStatements: []string{
+				"SELECT usage_user, usage_system, usage_idle, usage_nice, usage_iowait, usage_irq, usage_softirq, usage_steal, usage_guest, usage_guest_nice FROM cpu WHERE time >= 1451628982646 AND time < 1451672182646 AND usage_user > 90 WITH hostname=host_3",
+			},
+		},
+		{
+			desc:          "high-cpu-all",
+			fn:            func(d *Devops, q query.Query) { d.HighCPUForHosts(q, 0) },
+			want:          "SELECT usage_user, usage_system, usage_idle, usage_nice, usage_iowait, usage_irq, usage_softirq, usage_steal, usage_guest, usage_guest_nice FROM cpu.* WHERE time >= 1451628982646 AND time < 1451672182646",
+			wantReduction: query.IginxReduceFilter,
+		},
+	}
+
+	for _, c := range cases {
+		rand.Seed(123)
+		b := &BaseGenerator{TagMode: c.tagMode}
+		dq, err := b.NewDevops(start, end, 10)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		q := b.GenerateEmptyQuery()
+		c.fn(dq.(*Devops), q)
+		hq := q.(*query.Iginx)
+		if got := string(hq.SqlQuery); got != c.want {
+			t.Errorf("%s: incorrect query\ngot  %s\nwant %s", c.desc, got, c.want)
+		}
+		if got := len(hq.Statements); got != len(c.wantStatements) {
+			t.Errorf("%s: incorrect number of statements: got %d want %d", c.desc, got, len(c.wantStatements))
+		} else {
+			for i, stmt := range hq.Statements {
+				if string(stmt) != c.wantStatements[i] {
+					t.Errorf("%s: incorrect statement %d\ngot  %s\nwant %s", c.desc, i, stmt, c.wantStatements[i])
+				}
+			}
+		}
+		gotReduction := ""
+		if hq.Reduction != nil {
+			gotReduction = hq.Reduction.Kind
+		}
+		if gotReduction != c.wantReduction {
+			t.Errorf("%s: incorrect reduction: got %q want %q", c.desc, gotReduction, c.wantReduction)
+		}
+	}
+}
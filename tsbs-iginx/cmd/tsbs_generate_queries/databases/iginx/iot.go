@@ -2,7 +2,6 @@ package iginx
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/iot"
@@ -10,9 +9,13 @@ import (
 )
 
 const (
-	iotReadingsTable = "readings"
+	iotReadingsTable    = "readings"
+	iotDiagnosticsTable = "diagnostics"
 )
 
+// truckTagKeys are the string tags of a truck in the order they are generated.
+var truckTagKeys = []string{"name", "fleet", "driver", "model", "device_version"}
+
 // IoT produces TimescaleDB-specific queries for all the iot query types.
 type IoT struct {
 	*iot.Core
@@ -21,6 +24,7 @@ type IoT struct {
 
 // NewIoT makes an IoT object ready to generate Queries.
 func NewIoT(start, end time.Time, scale int, g *BaseGenerator) *IoT {
+	panicIfErr(g.initTemplate())
 	c, err := iot.NewCore(start, end, scale)
 	panicIfErr(err)
 	return &IoT{
@@ -29,28 +33,30 @@ func NewIoT(start, end time.Time, scale int, g *BaseGenerator) *IoT {
 	}
 }
 
-func (i *IoT) getTrucksWhereWithNames(names []string) string {
-	nameClauses := []string{}
-	for _, s := range names {
-		nameClauses = append(nameClauses, fmt.Sprintf("\"name\" = '%s'", s))
-	}
-
-	combinedHostnameClause := strings.Join(nameClauses, " or ")
-	return "(" + combinedHostnameClause + ")"
-}
-
-func (i *IoT) getTruckWhereString(nTrucks int) string {
+// getRandomTrucksSeries returns the FROM and WITH clauses selecting the
+// readings or diagnostics of nTrucks random trucks.
+func (i *IoT) getRandomTrucksSeries(measurement string, nTrucks int) (string, string) {
 	names, err := i.GetRandomTrucks(nTrucks)
 	if err != nil {
 		panic(err.Error())
 	}
-	return i.getTrucksWhereWithNames(names)
+	valueSets := make([]map[string]string, len(names))
+	for j, name := range names {
+		valueSets[j] = map[string]string{"name": name}
+	}
+	return i.series(measurement, truckTagKeys, valueSets...)
+}
+
+// getFleetSeries returns the FROM and WITH clauses selecting the readings or
+// diagnostics of a random fleet.
+func (i *IoT) getFleetSeries(measurement string) (string, string) {
+	return i.series(measurement, truckTagKeys, map[string]string{"fleet": i.GetRandomFleet()})
 }
 
 // LastLocByTruck finds the truck location for nTrucks.
 func (i *IoT) LastLocByTruck(qi query.Query, nTrucks int) {
-	iginxql := fmt.Sprintf("SELECT last(longitude), last(latitude) FROM readings.%s.*.*.*",
-		i.getTruckWhereString(nTrucks))
+	from, with := i.getRandomTrucksSeries(iotReadingsTable, nTrucks)
+	iginxql := fmt.Sprintf("SELECT last(longitude), last(latitude) FROM %s%s", from, with)
 
 	humanLabel := "Iginx last location by specific truck"
 	humanDesc := fmt.Sprintf("%s: random %4d trucks", humanLabel, nTrucks)
@@ -60,8 +66,8 @@ func (i *IoT) LastLocByTruck(qi query.Query, nTrucks int) {
 
 // LastLocPerTruck finds all the truck locations along with truck and driver names.
 func (i *IoT) LastLocPerTruck(qi query.Query) {
-	iginxql := fmt.Sprintf("SELECT last(longitude), last(latitude) FROM readings.*.%s.*.*",
-		i.GetRandomFleet())
+	from, with := i.getFleetSeries(iotReadingsTable)
+	iginxql := fmt.Sprintf("SELECT last(longitude), last(latitude) FROM %s%s", from, with)
 
 	humanLabel := "Iginx last location per truck"
 	humanDesc := humanLabel
@@ -71,133 +77,165 @@ func (i *IoT) LastLocPerTruck(qi query.Query) {
 
 // TrucksWithLowFuel finds all trucks with low fuel (less than 10%).
 func (i *IoT) TrucksWithLowFuel(qi query.Query) {
-	iginxql := fmt.Sprintf("SELECT fuel_state FROM diagnostics.*.%s.* where fuel_state <= 0.1",
-		i.GetRandomFleet())
+	from, with := i.getFleetSeries(iotDiagnosticsTable)
+	iginxql := fmt.Sprintf("SELECT last(fuel_state) FROM %s%s", from, with)
 
 	humanLabel := "Iginx trucks with low fuel"
 	humanDesc := fmt.Sprintf("%s: under 10 percent", humanLabel)
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
+	i.reduce(qi, query.IginxReduceBelow, 0.1, 0)
 }
 
 // TrucksWithHighLoad finds all trucks that have load over 90%.
 func (i *IoT) TrucksWithHighLoad(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	iginxql := fmt.Sprintf("SELECT current_load, load_capacity FROM diagnostics.*.%s.*",
-		i.GetRandomFleet())
+	from, with := i.getFleetSeries(iotDiagnosticsTable)
+	iginxql := fmt.Sprintf("SELECT last(current_load), last(load_capacity) FROM %s%s", from, with)
 
 	humanLabel := "Iginx trucks with high load"
 	humanDesc := fmt.Sprintf("%s: over 90 percent", humanLabel)
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
+	i.reduce(qi, query.IginxReduceHighLoad, 0.9, 0)
 }
 
 // StationaryTrucks finds all trucks that have low average velocity in a time window.
 func (i *IoT) StationaryTrucks(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
 	interval := i.Interval.MustRandWindow(iot.StationaryDuration)
-
-	iginxql := fmt.Sprintf("SELECT AVG(velocity) FROM readings.*.%s where time >=%d and time <= %d",
-		i.GetRandomFleet(), interval.Start().Unix()*1000, interval.End().Unix()*1000)
+	from, with := i.getFleetSeries(iotReadingsTable)
+	iginxql := fmt.Sprintf("SELECT avg(velocity) FROM %s%s%s",
+		from, with, groupByWindows(interval, "10m"))
 
 	humanLabel := "Iginx stationary trucks"
 	humanDesc := fmt.Sprintf("%s: with low avg velocity in last 10 minutes", humanLabel)
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
+	i.reduce(qi, query.IginxReduceBelow, 1, 0)
 }
 
 // TrucksWithLongDrivingSessions finds all trucks that have not stopped at least 20 mins in the last 4 hours.
 func (i *IoT) TrucksWithLongDrivingSessions(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	interval := i.Interval.MustRandWindow(iot.StationaryDuration)
-	iginxql := fmt.Sprintf("SELECT AVG(velocity) FROM readings.*.%s.* GROUP [%d, %d] BY 10ms",
-		i.GetRandomFleet(), interval.Start().Unix(), interval.End().Unix())
+	interval := i.Interval.MustRandWindow(iot.LongDrivingSessionDuration)
+	from, with := i.getFleetSeries(iotReadingsTable)
+	iginxql := fmt.Sprintf("SELECT avg(velocity) FROM %s%s%s",
+		from, with, groupByWindows(interval, "10m"))
 
 	humanLabel := "Iginx trucks with longer driving sessions"
 	humanDesc := fmt.Sprintf("%s: stopped less than 20 mins in 4 hour period", humanLabel)
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
+	// Calculate number of 10 min intervals that is the max driving duration for the session if we rest 5 mins per hour.
+	i.reduce(qi, query.IginxReduceLongSessions, 1, tenMinutePeriods(5, iot.LongDrivingSessionDuration))
 }
 
 // TrucksWithLongDailySessions finds all trucks that have driven more than 10 hours in the last 24 hours.
 func (i *IoT) TrucksWithLongDailySessions(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	interval := i.Interval.MustRandWindow(iot.StationaryDuration)
-	iginxql := fmt.Sprintf("SELECT AVG(velocity) FROM readings.*.%s.* GROUP [%d, %d] BY 10ms",
-		i.GetRandomFleet(), interval.Start().Unix(), interval.End().Unix())
+	interval := i.Interval.MustRandWindow(iot.DailyDrivingDuration)
+	from, with := i.getFleetSeries(iotReadingsTable)
+	iginxql := fmt.Sprintf("SELECT avg(velocity) FROM %s%s%s",
+		from, with, groupByWindows(interval, "10m"))
 
-	humanLabel := "Iginx trucks with longer driving sessions"
-	humanDesc := fmt.Sprintf("%s: stopped less than 20 mins in 4 hour period", humanLabel)
+	humanLabel := "Iginx trucks with longer daily sessions"
+	humanDesc := fmt.Sprintf("%s: drove more than 10 hours in the last 24 hours", humanLabel)
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
+	// Calculate number of 10 min intervals that is the max driving duration for the session if we rest 35 mins per hour.
+	i.reduce(qi, query.IginxReduceLongSessions, 1, tenMinutePeriods(35, iot.DailyDrivingDuration))
 }
 
 // AvgVsProjectedFuelConsumption calculates average and projected fuel consumption per fleet.
+//
+// IginX cannot filter the readings of each truck on their own velocity, so
+// the readings are averaged in ten minute windows and the runner averages,
+// per fleet, the windows driven faster than 1, weighted by their readings.
 func (i *IoT) AvgVsProjectedFuelConsumption(qi query.Query) {
-	iginxql := fmt.Sprintf("SELECT AVG(fuel_consumption) FROM readings.*.%s.*", i.GetRandomFleet())
+	from, with := i.series(iotReadingsTable, truckTagKeys)
+	iginxql := fmt.Sprintf("SELECT avg(fuel_consumption), avg(nominal_fuel_consumption), avg(velocity), count(velocity) FROM %s%s%s",
+		from, with, groupByWindows(i.Interval, "10m"))
 
 	humanLabel := "Iginx average vs projected fuel consumption per fleet"
 	humanDesc := humanLabel
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
+	i.reduce(qi, query.IginxReduceFuelConsumption, 1, 0)
 }
 
 // AvgDailyDrivingDuration finds the average driving duration per driver.
 func (i *IoT) AvgDailyDrivingDuration(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	iginxql := fmt.Sprintf("SELECT AVG(velocity) FROM readings.*.%s.*", i.GetRandomFleet())
+	from, with := i.series(iotReadingsTable, truckTagKeys)
+	iginxql := fmt.Sprintf("SELECT avg(velocity) FROM %s%s%s",
+		from, with, groupByWindows(i.Interval, "10m"))
 
 	humanLabel := "Iginx average driver driving duration per day"
 	humanDesc := humanLabel
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
+	i.reduce(qi, query.IginxReduceDailyDrivingDuration, 1, 0)
 }
 
 // AvgDailyDrivingSession finds the average driving session without stopping per driver per day.
 func (i *IoT) AvgDailyDrivingSession(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	iginxql := fmt.Sprintf("SELECT AVG(velocity) FROM readings.*.%s.*", i.GetRandomFleet())
+	from, with := i.series(iotReadingsTable, truckTagKeys)
+	iginxql := fmt.Sprintf("SELECT avg(velocity) FROM %s%s%s",
+		from, with, groupByWindows(i.Interval, "10m"))
 
 	humanLabel := "Iginx average driver driving session without stopping per day"
 	humanDesc := humanLabel
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
+	i.reduce(qi, query.IginxReduceDailyDrivingSession, 5, 0)
 }
 
 // AvgLoad finds the average load per truck model per fleet.
 func (i *IoT) AvgLoad(qi query.Query) {
-	iginxql := fmt.Sprintf("SELECT AVG(current_load) FROM diagnostics.*.%s.*", i.GetRandomFleet())
+	from, with := i.series(iotDiagnosticsTable, truckTagKeys)
+	iginxql := fmt.Sprintf("SELECT avg(current_load), avg(load_capacity) FROM %s%s", from, with)
 
 	humanLabel := "Iginx average load per truck model per fleet"
 	humanDesc := humanLabel
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
+	i.reduce(qi, query.IginxReduceAvgLoad, 0, 0)
 }
 
 // DailyTruckActivity returns the number of hours trucks has been active (not out-of-commission) per day per fleet per model.
 func (i *IoT) DailyTruckActivity(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	start := i.Interval.Start().Unix()
-	end := i.Interval.End().Unix()
-	iginxql := fmt.Sprintf(`SELECT AVG(status) FROM diagnostics.*.*.*.* GROUP [%d, %d] BY time(1d)`, start, end)
+	from, with := i.series(iotDiagnosticsTable, truckTagKeys)
+	iginxql := fmt.Sprintf("SELECT avg(status), count(status) FROM %s%s%s",
+		from, with, groupByWindows(i.Interval, "10m"))
 
 	humanLabel := "Iginx daily truck activity per fleet per model"
 	humanDesc := humanLabel
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
+	i.reduce(qi, query.IginxReduceDailyActivity, 1, 0)
 }
 
 // TruckBreakdownFrequency calculates the amount of times a truck model broke down in the last period.
+//
+// IginX cannot count the statuses of each truck that are 0, so the statuses
+// are averaged in ten minute windows and the runner takes a window whose
+// average is below 0.5 for one where the truck is broken down.
 func (i *IoT) TruckBreakdownFrequency(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	start := i.Interval.Start().Unix()
-	end := i.Interval.End().Unix()
-	iginxql := fmt.Sprintf(`SELECT AVG(status) FROM diagnostics.*.*.*.* GROUP [%d, %d] BY time(1d)`, start, end)
+	from, with := i.series(iotDiagnosticsTable, truckTagKeys)
+	iginxql := fmt.Sprintf("SELECT avg(status) FROM %s%s%s",
+		from, with, groupByWindows(i.Interval, "10m"))
 
 	humanLabel := "Iginx truck breakdown frequency per model"
 	humanDesc := humanLabel
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
+	i.reduce(qi, query.IginxReduceBreakdownFrequency, 0.5, 0)
+}
+
+// reduce sets the client-side reduction of the query over the trucks.
+func (i *IoT) reduce(qi query.Query, kind string, threshold float64, minCount int) {
+	i.setReduction(qi, &query.IginxReduction{
+		Kind:      kind,
+		TagKeys:   truckTagKeys,
+		Threshold: threshold,
+		MinCount:  minCount,
+	})
 }
 
 // tenMinutePeriods calculates the number of 10 minute periods that can fit in
@@ -0,0 +1,94 @@
+package iginx
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+func TestIoTQueries(t *testing.T) {
+	start := time.Unix(1451606400, 0)
+	end := start.Add(24 * time.Hour)
+
+	cases := []struct {
+		desc          string
+		fn            func(i *IoT, q query.Query)
+		want          string
+		wantReduction string
+		wantMinCount  int
+	}{
+		{
+			desc: "last-loc",
+			fn:   func(i *IoT, q query.Query) { i.LastLocByTruck(q, 1) },
+			want: "SELECT last(longitude), last(latitude) FROM readings.truck_5.*",
+		},
+		{
+			desc:          "low-fuel",
+			fn:            func(i *IoT, q query.Query) { i.TrucksWithLowFuel(q) },
+			want:          "SELECT last(fuel_state) FROM diagnostics.*.South.*",
+			wantReduction: query.IginxReduceBelow,
+		},
+		{
+			desc:          "stationary-trucks",
+			fn:            func(i *IoT, q query.Query) { i.StationaryTrucks(q) },
+			want:          "SELECT avg(velocity) FROM readings.*.West.* GROUP [1451668582646, 1451669182646) BY 10m",
+			wantReduction: query.IginxReduceBelow,
+		},
+		{
+			desc:          "long-driving-sessions",
+			fn:            func(i *IoT, q query.Query) { i.TrucksWithLongDrivingSessions(q) },
+			want:          "SELECT avg(velocity) FROM readings.*.West.* GROUP [1451614582646, 1451628982646) BY 10m",
+			wantReduction: query.IginxReduceLongSessions,
+			wantMinCount:  22,
+		},
+		{
+			desc:          "avg-vs-projected-fuel-consumption",
+			fn:            func(i *IoT, q query.Query) { i.AvgVsProjectedFuelConsumption(q) },
+			want:          "SELECT avg(fuel_consumption), avg(nominal_fuel_consumption), avg(velocity), count(velocity) FROM readings.* GROUP [1451606400000, 1451692800000) BY 10m",
+			wantReduction: query.IginxReduceFuelConsumption,
+		},
+		{
+			desc:          "truck-breakdown-frequency",
+			fn:            func(i *IoT, q query.Query) { i.TruckBreakdownFrequency(q) },
+			want:          "SELECT avg(status) FROM diagnostics.* GROUP [1451606400000, 1451692800000) BY 10m",
+			wantReduction: query.IginxReduceBreakdownFrequency,
+		},
+		{
+			desc:          "daily-activity",
+			fn:            func(i *IoT, q query.Query) { i.DailyTruckActivity(q) },
+			want:          "SELECT avg(status), count(status) FROM diagnostics.* GROUP [1451606400000, 1451692800000) BY 10m",
+			wantReduction: query.IginxReduceDailyActivity,
+		},
+	}
+
+	for _, c := range cases {
+		rand.Seed(123)
+		b := &BaseGenerator{}
+		iq, err := b.NewIoT(start, end, 10)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		q := b.GenerateEmptyQuery()
+		c.fn(iq.(*IoT), q)
+		hq := q.(*query.Iginx)
+		if got := string(hq.SqlQuery); got != c.want {
+			t.Errorf("%s: incorrect query\ngot  %s\nwant %s", c.desc, got, c.want)
+		}
+		if c.wantReduction == "" {
+			if hq.Reduction != nil {
+				t.Errorf("%s: unexpected reduction %s", c.desc, hq.Reduction.Kind)
+			}
+			continue
+		}
+		if hq.Reduction == nil {
+			t.Errorf("%s: missing reduction", c.desc)
+			continue
+		}
+		if hq.Reduction.Kind != c.wantReduction || hq.Reduction.MinCount != c.wantMinCount {
+			t.Errorf("%s: incorrect reduction: got %s, %d want %s, %d",
+				c.desc, hq.Reduction.Kind, hq.Reduction.MinCount, c.wantReduction, c.wantMinCount)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package iginx
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/timescale/tsbs/internal/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// restQuery is the KairosDB-style JSON query the IginX REST API takes.
+type restQuery struct {
+	StartAbsolute int64         `json:"start_absolute"`
+	EndAbsolute   int64         `json:"end_absolute"`
+	Metrics       []*restMetric `json:"metrics"`
+}
+
+type restMetric struct {
+	Name        string              `json:"name"`
+	Tags        map[string][]string `json:"tags,omitempty"`
+	Aggregators []*restAggregator   `json:"aggregators"`
+}
+
+type restAggregator struct {
+	Name     string        `json:"name"`
+	Sampling *restSampling `json:"sampling"`
+}
+
+type restSampling struct {
+	Value int64  `json:"value"`
+	Unit  string `json:"unit"`
+}
+
+// newRestSampling expresses a window in the largest unit that divides it.
+func newRestSampling(window time.Duration) *restSampling {
+	switch {
+	case window%time.Hour == 0:
+		return &restSampling{Value: int64(window / time.Hour), Unit: "hours"}
+	case window%time.Minute == 0:
+		return &restSampling{Value: int64(window / time.Minute), Unit: "minutes"}
+	case window%time.Second == 0:
+		return &restSampling{Value: int64(window / time.Second), Unit: "seconds"}
+	}
+	return &restSampling{Value: int64(window / time.Millisecond), Unit: "milliseconds"}
+}
+
+// setRestQuery gives the query a REST form aggregating each of the fields of
+// the selected series with aggFunc over windows of the interval, the same as
+// SELECT aggFunc(field), ... GROUP [start, end) BY window, e.g. 1m.
+// Selections the REST API cannot express, that is IginX tag filters on more
+// than one tag key, leave the query without a REST form.
+func (g *BaseGenerator) setRestQuery(qi query.Query, measurement string, tagKeys []string, valueSets []map[string]string,
+	aggFunc string, fields []string, interval *utils.TimeInterval, window string) {
+	sampling, err := time.ParseDuration(window)
+	panicIfErr(err)
+	paths, filters := g.selection(measurement, tagKeys, valueSets)
+	var tags map[string][]string
+	for _, filter := range filters {
+		if len(filter) != 1 {
+			return
+		}
+		key := string(filter[0].Key)
+		if tags == nil {
+			tags = map[string][]string{}
+		}
+		tags[key] = append(tags[key], string(filter[0].Value))
+	}
+	if len(tags) > 1 {
+		return
+	}
+
+	rq := &restQuery{
+		StartAbsolute: interval.StartUnixMillis(),
+		EndAbsolute:   interval.EndUnixMillis(),
+	}
+	for _, p := range paths {
+		for _, field := range fields {
+			rq.Metrics = append(rq.Metrics, &restMetric{
+				Name:        string(g.template.AppendField([]byte(p), []byte(field))),
+				Tags:        tags,
+				Aggregators: []*restAggregator{{Name: aggFunc, Sampling: newRestSampling(sampling)}},
+			})
+		}
+	}
+	b, err := json.Marshal(rq)
+	panicIfErr(err)
+	qi.(*query.Iginx).RestQuery = b
+}
@@ -0,0 +1,77 @@
+package iginx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/internal/utils"
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+func TestNewRestSampling(t *testing.T) {
+	cases := []struct {
+		window time.Duration
+		want   restSampling
+	}{
+		{time.Hour, restSampling{Value: 1, Unit: "hours"}},
+		{10 * time.Minute, restSampling{Value: 10, Unit: "minutes"}},
+		{90 * time.Second, restSampling{Value: 90, Unit: "seconds"}},
+		{1500 * time.Millisecond, restSampling{Value: 1500, Unit: "milliseconds"}},
+	}
+	for _, c := range cases {
+		if got := newRestSampling(c.window); *got != c.want {
+			t.Errorf("%s: got %v want %v", c.window, *got, c.want)
+		}
+	}
+}
+
+func TestSetRestQuery(t *testing.T) {
+	start := time.Unix(1451606400, 0)
+	interval, err := utils.NewTimeInterval(start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{"name", "fleet"}
+
+	cases := []struct {
+		desc      string
+		template  string
+		tagMode   string
+		valueSets []map[string]string
+		want      string
+	}{
+		{
+			desc:      "paths",
+			valueSets: []map[string]string{{"name": "truck_1"}, {"name": "truck_2"}},
+			want: `{"start_absolute":1451606400000,"end_absolute":1451610000000,"metrics":[` +
+				`{"name":"readings.truck_1.*.velocity","aggregators":[{"name":"max","sampling":{"value":1,"unit":"minutes"}}]},` +
+				`{"name":"readings.truck_2.*.velocity","aggregators":[{"name":"max","sampling":{"value":1,"unit":"minutes"}}]}]}`,
+		},
+		{
+			desc:      "iginx tags",
+			template:  "{measurement}.{field}",
+			tagMode:   pathtemplate.TagModeIginx,
+			valueSets: []map[string]string{{"name": "truck_1"}, {"name": "truck_2"}},
+			want: `{"start_absolute":1451606400000,"end_absolute":1451610000000,"metrics":[` +
+				`{"name":"readings.velocity","tags":{"name":["truck_1","truck_2"]},"aggregators":[{"name":"max","sampling":{"value":1,"unit":"minutes"}}]}]}`,
+		},
+		{
+			desc:      "iginx tags on several keys",
+			template:  "{measurement}.{field}",
+			tagMode:   pathtemplate.TagModeIginx,
+			valueSets: []map[string]string{{"name": "truck_1", "fleet": "North"}},
+		},
+	}
+	for _, c := range cases {
+		g := &BaseGenerator{PathTemplate: c.template, TagMode: c.tagMode}
+		if err := g.initTemplate(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		q := g.GenerateEmptyQuery()
+		g.setRestQuery(q, "readings", keys, c.valueSets, "max", []string{"velocity"}, interval, "1m")
+		if got := string(q.(*query.Iginx).RestQuery); got != c.want {
+			t.Errorf("%s: incorrect REST query\ngot  %s\nwant %s", c.desc, got, c.want)
+		}
+	}
+}
@@ -51,6 +51,12 @@ var useCaseMatrix = map[string]map[string]utils.QueryFillerMaker{
 		iot.LabelDailyActivity:                 iot.NewDailyTruckActivity,
 		iot.LabelBreakdownFrequency:            iot.NewTruckBreakdownFrequency,
 	},
+	"devops-generic": {
+		devops.LabelGenericAgg:               devops.NewGenericAgg,
+		devops.LabelGenericLastpoint:         devops.NewGenericLastPoint,
+		devops.LabelGenericShortLived + "-1": devops.NewGenericShortLived(1),
+		devops.LabelGenericShortLived + "-8": devops.NewGenericShortLived(8),
+	},
 }
 
 var conf = &config.QueryGeneratorConfig{}
@@ -0,0 +1,27 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/common"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// GenericAgg returns QueryFiller for the devops-generic generic-agg case
+type GenericAgg struct {
+	core utils.QueryGenerator
+}
+
+// NewGenericAgg returns a new GenericAgg for given paremeters
+func NewGenericAgg(core utils.QueryGenerator) utils.QueryFiller {
+	return &GenericAgg{core}
+}
+
+// Fill fills in the query.Query with query details
+func (d *GenericAgg) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(GenericAggFiller)
+	if !ok {
+		common.PanicUnimplementedQuery(d.core)
+	}
+	fc.GenericMetricAgg(q, GenericAggDuration)
+	return q
+}
@@ -0,0 +1,27 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/common"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// GenericLastPoint returns QueryFiller for the devops-generic lastpoint case
+type GenericLastPoint struct {
+	core utils.QueryGenerator
+}
+
+// NewGenericLastPoint returns a new GenericLastPoint for given paremeters
+func NewGenericLastPoint(core utils.QueryGenerator) utils.QueryFiller {
+	return &GenericLastPoint{core}
+}
+
+// Fill fills in the query.Query with query details
+func (d *GenericLastPoint) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(GenericLastPointFiller)
+	if !ok {
+		common.PanicUnimplementedQuery(d.core)
+	}
+	fc.GenericLastPoint(q)
+	return q
+}
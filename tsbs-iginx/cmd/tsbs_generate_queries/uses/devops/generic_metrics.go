@@ -0,0 +1,108 @@
+package devops
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/common"
+	"github.com/timescale/tsbs/pkg/data/usecases/devops"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+const (
+	// GenericMetricsTableName is the name of the table where the generic
+	// metrics of the devops-generic use case are stored.
+	GenericMetricsTableName = "generic_metrics"
+
+	// GenericAggDuration is the how big the time range for GenericAgg query is
+	GenericAggDuration = 12 * time.Hour
+
+	// LabelGenericAgg is the label for the generic-agg query
+	LabelGenericAgg = "generic-agg"
+	// LabelGenericLastpoint is the label for the generic-lastpoint query
+	LabelGenericLastpoint = "generic-lastpoint"
+	// LabelGenericShortLived is the label prefix for queries of the generic
+	// short-lived hosts variety
+	LabelGenericShortLived = "generic-short-lived"
+)
+
+// GenericCore is the common component of all generators for the
+// devops-generic use case. Hosts there have zipf-distributed metric counts
+// and half of them are short-lived.
+type GenericCore struct {
+	*Core
+	// MetricCounts is the number of generic metrics of each host, by index
+	MetricCounts []uint64
+	// MaxMetricCount is the max number of generic metrics per host
+	MaxMetricCount uint64
+}
+
+// NewGenericCore returns a new GenericCore for the given time range,
+// cardinality and max number of metrics per host the data was generated with.
+func NewGenericCore(start, end time.Time, scale int, maxMetricCount uint64) (*GenericCore, error) {
+	if scale < 1 {
+		return nil, fmt.Errorf("scale must be at least 1 for the devops-generic use case")
+	}
+	if maxMetricCount < 1 {
+		return nil, fmt.Errorf("max metric count per host has to be greater than 0")
+	}
+	c, err := NewCore(start, end, scale)
+	if err != nil {
+		return nil, err
+	}
+	return &GenericCore{
+		Core:           c,
+		MetricCounts:   devops.HostMetricCounts(uint64(scale), maxMetricCount),
+		MaxMetricCount: maxMetricCount,
+	}, nil
+}
+
+// GetRandomGenericMetric returns a random generic metric, e.g. metric_12, and
+// how many hosts have it.
+func (d *GenericCore) GetRandomGenericMetric() (string, int) {
+	n := uint64(rand.Int63n(int64(d.MaxMetricCount)))
+	hosts := 0
+	for _, count := range d.MetricCounts {
+		if count > n {
+			hosts++
+		}
+	}
+	return fmt.Sprintf("metric_%d", n), hosts
+}
+
+// GetRandomShortLivedHosts returns a random set of nHosts of the short-lived
+// hosts.
+func (d *GenericCore) GetRandomShortLivedHosts(nHosts int) ([]string, error) {
+	shortLived := int(devops.ShortLivedHostCount(uint64(d.Scale)))
+	if nHosts < 1 {
+		return nil, fmt.Errorf("number of hosts cannot be < 1; got %d", nHosts)
+	}
+	if nHosts > shortLived {
+		return nil, fmt.Errorf("number of hosts (%d) larger than short-lived hosts (%d). See --scale (%d)", nHosts, shortLived, d.Scale)
+	}
+	randomNumbers, err := common.GetRandomSubsetPerm(nHosts, shortLived)
+	if err != nil {
+		return nil, err
+	}
+	hostnames := make([]string, len(randomNumbers))
+	for i, n := range randomNumbers {
+		hostnames[i] = fmt.Sprintf("host_%d", d.Scale-shortLived+n)
+	}
+	return hostnames, nil
+}
+
+// GenericAggFiller is a type that can fill in a generic-agg query
+type GenericAggFiller interface {
+	GenericMetricAgg(query.Query, time.Duration)
+}
+
+// GenericLastPointFiller is a type that can fill in a generic-lastpoint query
+type GenericLastPointFiller interface {
+	GenericLastPoint(query.Query)
+}
+
+// GenericShortLivedFiller is a type that can fill in a short-lived hosts query
+type GenericShortLivedFiller interface {
+	ShortLivedHosts(query.Query, int)
+}
@@ -0,0 +1,80 @@
+package devops
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestNewGenericCore(t *testing.T) {
+	s := time.Now()
+	e := s.Add(time.Hour)
+	c, err := NewGenericCore(s, e, 10, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := len(c.MetricCounts); got != 10 {
+		t.Errorf("incorrect number of metric counts: got %d want %d", got, 10)
+	}
+	for i, count := range c.MetricCounts {
+		if count < 1 || count > 20 {
+			t.Errorf("metric count of host %d out of range: got %d", i, count)
+		}
+	}
+	if _, err := NewGenericCore(s, e, 10, 0); err == nil {
+		t.Errorf("unexpected lack of error for 0 max metric count")
+	}
+	if _, err := NewGenericCore(s, e, 0, 20); err == nil {
+		t.Errorf("unexpected lack of error for 0 scale")
+	}
+}
+
+func TestGenericCoreGetRandomGenericMetric(t *testing.T) {
+	rand.Seed(123)
+	s := time.Now()
+	c, err := NewGenericCore(s, s.Add(time.Hour), 10, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		metric, hosts := c.GetRandomGenericMetric()
+		var n uint64
+		if _, err := fmt.Sscanf(metric, "metric_%d", &n); err != nil || n >= 20 {
+			t.Fatalf("incorrect metric: %s", metric)
+		}
+		// the last host always has all the metrics
+		if hosts < 1 || hosts > 10 {
+			t.Errorf("incorrect number of hosts with %s: got %d", metric, hosts)
+		}
+	}
+}
+
+func TestGenericCoreGetRandomShortLivedHosts(t *testing.T) {
+	rand.Seed(123)
+	s := time.Now()
+	c, err := NewGenericCore(s, s.Add(time.Hour), 10, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hosts, err := c.GetRandomShortLivedHosts(5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, h := range hosts {
+		var n int
+		if _, err := fmt.Sscanf(h, "host_%d", &n); err != nil || n < 5 || n >= 10 {
+			t.Errorf("not a short-lived host: %s", h)
+		}
+		if seen[h] {
+			t.Errorf("duplicate host: %s", h)
+		}
+		seen[h] = true
+	}
+	for _, n := range []int{0, 6} {
+		if _, err := c.GetRandomShortLivedHosts(n); err == nil {
+			t.Errorf("unexpected lack of error for %d hosts", n)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/common"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// GenericShortLived produces a QueryFiller for the devops-generic
+// short-lived hosts cases
+type GenericShortLived struct {
+	core  utils.QueryGenerator
+	hosts int
+}
+
+// NewGenericShortLived produces a new function that produces a new GenericShortLived
+func NewGenericShortLived(hosts int) utils.QueryFillerMaker {
+	return func(core utils.QueryGenerator) utils.QueryFiller {
+		return &GenericShortLived{
+			core:  core,
+			hosts: hosts,
+		}
+	}
+}
+
+// Fill fills in the query.Query with query details
+func (d *GenericShortLived) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(GenericShortLivedFiller)
+	if !ok {
+		common.PanicUnimplementedQuery(d.core)
+	}
+	fc.ShortLivedHosts(q, d.hosts)
+	return q
+}
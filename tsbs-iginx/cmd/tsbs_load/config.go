@@ -25,10 +25,16 @@ type RunnerConfig struct {
 	DoAbortOnExist  bool          `yaml:"do-abort-on-exist" mapstructure:"do-abort-on-exist"`
 	ReportingPeriod time.Duration `yaml:"reporting-period" mapstructure:"reporting-period"`
 	Seed            int64
-	HashWorkers     bool   `yaml:"hash-workers" mapstructure:"hash-workers"`
-	InsertIntervals string `yaml:"insert-intervals" mapstructure:"insert-intervals"`
-	FlowControl     bool   `yaml:"flow-control" mapstructure:"flow-control"`
-	ChannelCapacity uint   `yaml:"channel-capacity" mapstructure:"channel-capacity"`
+	HashWorkers     bool    `yaml:"hash-workers" mapstructure:"hash-workers"`
+	InsertIntervals string  `yaml:"insert-intervals" mapstructure:"insert-intervals"`
+	FlowControl     bool    `yaml:"flow-control" mapstructure:"flow-control"`
+	ChannelCapacity uint    `yaml:"channel-capacity" mapstructure:"channel-capacity"`
+	ReportFile      string  `yaml:"report-file" mapstructure:"report-file"`
+	ReportFormat    string  `yaml:"report-format" mapstructure:"report-format"`
+	HDRLatencies    string  `yaml:"hdr-latencies" mapstructure:"hdr-latencies"`
+	TargetRate      float64 `yaml:"target-rate" mapstructure:"target-rate"`
+	TargetRateUnit  string  `yaml:"target-rate-unit" mapstructure:"target-rate-unit"`
+	LoadProfile     string  `yaml:"load-profile" mapstructure:"load-profile"`
 }
 
 type DataSourceConfig struct {
@@ -34,6 +34,36 @@ func addLoaderRunnerFlags(fs *pflag.FlagSet) {
 		"Whether to abort if a database with the given name already exists.",
 	)
 	fs.Duration("loader.runner.reporting-period", 10*time.Second, "Period to report write stats")
+	fs.String(
+		"loader.runner.report-file",
+		"",
+		"Also write the write stats of each reporting period to this file, along with the number of batches of each worker",
+	)
+	fs.String("loader.runner.report-format", load.ReportFormatCSV, "Format of report-file: 'csv', or 'json' for a JSON object per line")
+	fs.String(
+		"loader.runner.hdr-latencies",
+		"",
+		"Write the High Dynamic Range (HDR) Histogram of batch insert latencies to this file",
+	)
+	fs.Float64(
+		"loader.runner.target-rate",
+		0,
+		"Insert on a fixed timeline at this many target-rate-units per second, however fast the database is, "+
+			"and time batches from when they were due. 0 = each worker inserts as insert-intervals allows",
+	)
+	fs.String(
+		"loader.runner.target-rate-unit",
+		load.TargetRateRows,
+		"Unit of target-rate: 'rows', assuming batch-size rows per batch, or 'batches'",
+	)
+	fs.String(
+		"loader.runner.load-profile",
+		"",
+		"Steps to go through while loading, comma-separated, each 'duration:workers=n' or 'duration:rate=r' "+
+			"with r in target-rate-units per second, e.g. '1m:workers=2,1m:workers=4'. A range such as 'workers=1-8' or 'rate=1000-5000' "+
+			"ramps up over the duration of the step. Workers steps override workers, rate steps load open-loop like target-rate. "+
+			"The load ends with the last step",
+	)
 	fs.Int64("loader.runner.seed", 0, "PRNG seed (default: 0, which uses the current timestamp)")
 	fs.Bool(
 		"loader.runner.do-load",
@@ -74,6 +74,12 @@ func convertRunnerConfigToInternalRep(r *RunnerConfig) *load.BenchmarkRunnerConf
 		InsertIntervals: r.InsertIntervals,
 		NoFlowControl:   !r.FlowControl,
 		ChannelCapacity: r.ChannelCapacity,
+		ReportFile:      r.ReportFile,
+		ReportFormat:    r.ReportFormat,
+		HDRLatencies:    r.HDRLatencies,
+		TargetRate:      r.TargetRate,
+		TargetRateUnit:  r.TargetRateUnit,
+		LoadProfile:     r.LoadProfile,
 	}
 }
 
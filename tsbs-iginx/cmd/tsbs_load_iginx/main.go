@@ -5,56 +5,44 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"fmt"
-	"log"
-	"sync"
-	"time"
 
 	"github.com/blagojts/viper"
 	"github.com/spf13/pflag"
 	"github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/load"
-	"github.com/timescale/tsbs/pkg/targets"
-	"github.com/timescale/tsbs/pkg/targets/constants"
-	"github.com/timescale/tsbs/pkg/targets/initializers"
+	"github.com/timescale/tsbs/pkg/data/source"
+	"github.com/timescale/tsbs/pkg/targets/iginx"
 )
 
-// Program option vars:
-var (
-	//session *client.Session
-	doAbortOnExist    bool
-)
-
-// Global vars
-var (
-	loader  load.BenchmarkRunner
-	config  load.BenchmarkRunnerConfig
-	bufPool sync.Pool
-	target  targets.ImplementedTarget
-)
+// addLoaderFlags adds the flags of load.BenchmarkRunnerConfig to fs, with the
+// defaults and help of some of them changed to fit IginX, and the flow
+// control flags the common loader flags lack.
+func addLoaderFlags(fs *pflag.FlagSet) {
+	load.BenchmarkRunnerConfig{}.AddToFlagSet(fs)
+	setFlagDefault(fs, "batch-size", "10")
+	setFlagDefault(fs, "file", "/home/humanfy/tmp_data")
+	fs.Lookup("do-create-db").Usage = "Whether to remove the series of a previous run and set IginX up with storage-engines and create-statements. " +
+		"Disable on all but one client if running on a multi client setup."
+	fs.Lookup("do-abort-on-exist").Usage = "Whether to abort if series of the benchmark measurements already exist."
+	fs.Bool("no-flow-control", false, "Whether to use flow control. Set this flag to false to load all data first.")
+	fs.Uint64("channel-capacity", 100000, "Channel capacity")
+}
 
-// allows for testing
-var fatal = log.Fatalf
+// setFlagDefault changes the default value of the flag name of fs.
+func setFlagDefault(fs *pflag.FlagSet, name, value string) {
+	f := fs.Lookup(name)
+	if err := f.Value.Set(value); err != nil {
+		panic(fmt.Errorf("invalid default '%s' for flag %s: %v", value, name, err))
+	}
+	f.DefValue = value
+}
 
 // Parse args:
-func init() {
-	target = initializers.GetTarget(constants.FormatIginx)
-	config = load.BenchmarkRunnerConfig{}
-	// Not all the default flags apply to Iginx
-	// config.AddToFlagSet(pflag.CommandLine)
-	pflag.CommandLine.Uint("batch-size", 10, "Number of items to batch together in a single insert")
-	pflag.CommandLine.Uint("workers", 1, "Number of parallel clients inserting")
-	pflag.CommandLine.Int64("limit", 0, "Number of items to insert (0 = all of them).")
-	pflag.CommandLine.Bool("do-load", true, "Whether to write data. Set this flag to false to check input read speed.")
-	pflag.CommandLine.Bool("no-flow-control", false, "Whether to use flow control. Set this flag to false to load all data first.")
-	pflag.CommandLine.Duration("reporting-period", 10*time.Second, "Period to report write stats")
-	pflag.CommandLine.String("file", "/home/humanfy/tmp_data", "File name to read data from")
-	pflag.CommandLine.Int64("seed", 0, "PRNG seed (default: 0, which uses the current timestamp)")
-	pflag.CommandLine.Uint64("channel-capacity", 100000, "Channel capacity")
-	pflag.CommandLine.String("insert-intervals", "", "Time to wait between each insert, default '' => all workers insert ASAP. '1,2' = worker 1 waits 1s between inserts, worker 2 and others wait 2s")
-	pflag.CommandLine.Bool("hash-workers", false, "Whether to consistently hash insert data to the same workers (i.e., the data for a particular host always goes to the same worker)")
+func initProgramOptions() (*iginx.SpecificConfig, load.BenchmarkRunner, *load.BenchmarkRunnerConfig) {
+	target := iginx.NewTarget()
+	loaderConf := load.BenchmarkRunnerConfig{}
+	addLoaderFlags(pflag.CommandLine)
 	target.TargetSpecificFlags("", pflag.CommandLine)
 	pflag.Parse()
 
@@ -64,47 +52,29 @@ func init() {
 		panic(fmt.Errorf("fatal error config file: %s", err))
 	}
 
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := viper.Unmarshal(&loaderConf); err != nil {
 		panic(fmt.Errorf("unable to decode config: %s", err))
 	}
 
-	//session = client.NewSession("127.0.0.1", "6888", "root", "root")
-	//if err := session.Open(); err != nil {
-	//	log.Fatal(err)
-	//}
-
-	config.HashWorkers = false
-	loader = load.GetBenchmarkRunner(config)
-}
-
-type benchmark struct{}
-
-func (b *benchmark) GetDataSource() targets.DataSource {
-	return &fileDataSource{scanner: bufio.NewScanner(load.GetBufferedReader(config.FileName))}
-}
-
-func (b *benchmark) GetBatchFactory() targets.BatchFactory {
-	return &factory{}
-}
-
-func (b *benchmark) GetPointIndexer(_ uint) targets.PointIndexer {
-	return &targets.ConstantIndexer{}
-}
-
-func (b *benchmark) GetProcessor() targets.Processor {
-	return &processor{}
-}
+	var iginxConf iginx.SpecificConfig
+	if err := viper.Unmarshal(&iginxConf); err != nil {
+		panic(fmt.Errorf("unable to decode iginx config: %s", err))
+	}
 
-func (b *benchmark) GetDBCreator() targets.DBCreator {
-	return &dbCreator{}
+	loaderConf.HashWorkers = false
+	loader := load.GetBenchmarkRunner(loaderConf)
+	return &iginxConf, loader, &loaderConf
 }
 
 func main() {
-	bufPool = sync.Pool{
-		New: func() interface{} {
-			return bytes.NewBuffer(make([]byte, 0, 4*1024*1024))
-		},
-	}
+	iginxConf, loader, loaderConf := initProgramOptions()
 
-	loader.RunBenchmark(&benchmark{})
+	benchmark, err := iginx.NewBenchmark(iginxConf, &source.DataSourceConfig{
+		Type: source.FileDataSourceType,
+		File: &source.FileDataSourceConfig{Location: loaderConf.FileName},
+	})
+	if err != nil {
+		panic(err)
+	}
+	loader.RunBenchmark(benchmark)
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/blagojts/viper"
+	"github.com/spf13/pflag"
+	"github.com/timescale/tsbs/load"
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+type emptyDataSource struct{}
+
+func (d *emptyDataSource) NextItem() data.LoadedPoint { return data.LoadedPoint{} }
+
+func (d *emptyDataSource) Headers() *common.GeneratedDataHeaders { return nil }
+
+type testBatch struct{}
+
+func (b *testBatch) Len() uint               { return 0 }
+func (b *testBatch) Append(data.LoadedPoint) {}
+
+type testFactory struct{}
+
+func (f *testFactory) New() targets.Batch { return &testBatch{} }
+
+type testProcessor struct{}
+
+func (p *testProcessor) Init(int, bool, bool) {}
+
+func (p *testProcessor) ProcessBatch(targets.Batch, bool) (uint64, uint64) { return 0, 0 }
+
+type testCreator struct {
+	existsCalled bool
+	removeCalled bool
+	createCalled bool
+}
+
+func (c *testCreator) Init() {}
+
+func (c *testCreator) DBExists(string) bool {
+	c.existsCalled = true
+	return true
+}
+
+func (c *testCreator) RemoveOldDB(string) error {
+	c.removeCalled = true
+	return nil
+}
+
+func (c *testCreator) CreateDB(string) error {
+	c.createCalled = true
+	return nil
+}
+
+type testBenchmark struct {
+	creator *testCreator
+}
+
+func (b *testBenchmark) GetDataSource() targets.DataSource     { return &emptyDataSource{} }
+func (b *testBenchmark) GetBatchFactory() targets.BatchFactory { return &testFactory{} }
+func (b *testBenchmark) GetPointIndexer(uint) targets.PointIndexer {
+	return &targets.ConstantIndexer{}
+}
+func (b *testBenchmark) GetProcessor() targets.Processor { return &testProcessor{} }
+func (b *testBenchmark) GetDBCreator() targets.DBCreator { return b.creator }
+
+func TestLoadCreatesDB(t *testing.T) {
+	cases := []struct {
+		desc       string
+		args       []string
+		wantExists bool
+		wantCreate bool
+		wantPanic  bool
+	}{
+		{desc: "default", args: []string{}, wantExists: true, wantCreate: true},
+		{desc: "do-create-db", args: []string{"--do-create-db"}, wantExists: true, wantCreate: true},
+		{desc: "no do-create-db", args: []string{"--do-create-db=false"}, wantExists: true},
+		{desc: "do-abort-on-exist only", args: []string{"--do-create-db=false", "--do-abort-on-exist"}, wantExists: true, wantPanic: true},
+	}
+	for _, c := range cases {
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		addLoaderFlags(fs)
+		if err := fs.Parse(append(c.args, "--reporting-period=0s")); err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		v := viper.New()
+		if err := v.BindPFlags(fs); err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		var conf load.BenchmarkRunnerConfig
+		if err := v.Unmarshal(&conf); err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+
+		b := &testBenchmark{creator: &testCreator{}}
+		func() {
+			defer func() {
+				if r := recover(); (r != nil) != c.wantPanic {
+					t.Errorf("%s: got panic %v, want panic %v", c.desc, r, c.wantPanic)
+				}
+			}()
+			load.GetBenchmarkRunner(conf).RunBenchmark(b)
+		}()
+		if got := b.creator.existsCalled; got != c.wantExists {
+			t.Errorf("%s: DBExists called: got %v want %v", c.desc, got, c.wantExists)
+		}
+		if got := b.creator.createCalled; got != c.wantCreate {
+			t.Errorf("%s: CreateDB called: got %v want %v", c.desc, got, c.wantCreate)
+		}
+		if got := b.creator.removeCalled; got != c.wantCreate {
+			t.Errorf("%s: RemoveOldDB called: got %v want %v", c.desc, got, c.wantCreate)
+		}
+	}
+}
+
+func TestLoaderFlags(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	addLoaderFlags(fs)
+	want := map[string]string{
+		"batch-size":       "10",
+		"file":             "/home/humanfy/tmp_data",
+		"do-create-db":     "true",
+		"channel-capacity": "100000",
+		"results-file":     "",
+	}
+	for name, def := range want {
+		f := fs.Lookup(name)
+		if f == nil {
+			t.Errorf("flag %s is missing", name)
+			continue
+		}
+		if f.DefValue != def || f.Value.String() != def {
+			t.Errorf("flag %s: got default %s (value %s) want %s", name, f.DefValue, f.Value, def)
+		}
+	}
+}
@@ -1,31 +1,73 @@
-// tsbs_run_queries_influx speed tests InfluxDB using requests from stdin.
+// tsbs_run_queries_iginx speed tests IginX using requests from stdin.
 //
 // It reads encoded Query objects from stdin, and makes concurrent requests
-// to the provided HTTP endpoint. This program has no knowledge of the
+// to the provided IginX nodes. This program has no knowledge of the
 // internals of the endpoint.
+//
+// With load-file set, it also loads that data while the queries run, as
+// tsbs_load_iginx would, and reports the write rates and query latencies of
+// both side by side.
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/blagojts/viper"
 	"github.com/spf13/pflag"
 	"github.com/thulab/iginx-client-go/client"
 	"github.com/timescale/tsbs/internal/utils"
+	"github.com/timescale/tsbs/load"
+	"github.com/timescale/tsbs/pkg/data/source"
 	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx"
+)
+
+// Program option vars:
+var (
+	iginxConfig  iginx.SpecificConfig
+	responsesDir string
+	protocol     string
+	queryTimeout time.Duration
+	loadFile     string
+	loaderConfig load.BenchmarkRunnerConfig
+	reportWindow time.Duration
 )
 
 // Global vars:
 var (
 	runner *query.BenchmarkRunner
+	// pool holds the sessions shared by all workers, nil if each worker
+	// opens its own
+	pool *iginx.SessionPool
 )
 
 // Parse args:
 func init() {
 	var config query.BenchmarkRunnerConfig
 	config.AddToFlagSet(pflag.CommandLine)
+	iginx.AddConnectionFlags("", pflag.CommandLine)
+	pflag.String("protocol", protocolSession,
+		"How to send the queries: 'session' runs their SQL over IginX sessions, "+
+			"'rest' posts their KairosDB-style JSON form to the REST API at url, runner-side reductions are not applied")
+	pflag.Int("sessions", 0,
+		"Number of session groups shared by the workers, each with sessions to the hosts as set by session-assignment. "+
+			"0 gives each worker its own")
+	pflag.Duration("health-check-interval", 30*time.Second,
+		"Sessions of the shared pool left idle for longer than this are checked before being reused, 0 to never check them")
+	pflag.Duration("query-timeout", 0, "Time after which a query is given up on and counted as a timeout of its type, 0 for no limit")
+	pflag.String("responses-dir", "", "Directory to write the response of each query to as canonical JSON, in a file named after the query ID")
+	pflag.String("load-file", "", "File of data to load while the queries run, in the format set by data-format. Empty to only run queries")
+	pflag.Uint("load-workers", 1, "Number of parallel clients loading load-file")
+	pflag.Uint("load-batch-size", 10, "Number of items to batch together in a single insert of load-file")
+	pflag.Uint64("load-limit", 0, "Number of items of load-file to insert (0 = all of them)")
+	pflag.String("load-insert-intervals", "",
+		"Time to wait between each insert of load-file, default '' => all workers insert ASAP. '1,2' = worker 1 waits 1s between inserts, worker 2 and others wait 2s")
+	pflag.Duration("report-window", 10*time.Second, "Period to report the write rates and query latencies of a run with load-file over, 0 to only print the summaries")
+	iginx.AddLoadFlags("", pflag.CommandLine, iginx.DataFormatInflux)
 
 	pflag.Parse()
 
@@ -39,68 +81,228 @@ func init() {
 		panic(fmt.Errorf("unable to decode config: %s", err))
 	}
 
+	if err := viper.Unmarshal(&iginxConfig); err != nil {
+		panic(fmt.Errorf("unable to decode iginx config: %s", err))
+	}
+	if err := iginxConfig.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	protocol = viper.GetString("protocol")
+	if protocol != protocolSession && protocol != protocolRest {
+		log.Fatalf("invalid protocol '%s', supported: %s, %s", protocol, protocolSession, protocolRest)
+	}
+	queryTimeout = viper.GetDuration("query-timeout")
+	if n := viper.GetInt("sessions"); n > 0 && protocol == protocolSession {
+		pool, err = iginxConfig.NewSessionPool(n, viper.GetDuration("health-check-interval"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	responsesDir = viper.GetString("responses-dir")
+	if responsesDir != "" {
+		if err := os.MkdirAll(responsesDir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	loadFile = viper.GetString("load-file")
+	loaderConfig = load.BenchmarkRunnerConfig{
+		DBName:          config.DBName,
+		BatchSize:       viper.GetUint("load-batch-size"),
+		Workers:         viper.GetUint("load-workers"),
+		Limit:           viper.GetUint64("load-limit"),
+		DoLoad:          true,
+		InsertIntervals: viper.GetString("load-insert-intervals"),
+	}
+	reportWindow = viper.GetDuration("report-window")
+
 	runner = query.NewBenchmarkRunner(config)
 }
 
 func main() {
-	runner.Run(&query.IginxPool, newProcessor)
+	if loadFile == "" {
+		runner.Run(&query.IginxPool, newProcessor)
+		return
+	}
+	benchmark, err := iginx.NewBenchmark(&iginxConfig, &source.DataSourceConfig{
+		Type: source.FileDataSourceType,
+		File: &source.FileDataSourceConfig{Location: loadFile},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	loader := load.GetBenchmarkRunner(loaderConfig)
+	load.NewMixedRunner(loader, runner, reportWindow).Run(benchmark, &query.IginxPool, newProcessor)
 }
 
 type processor struct {
-	session *client.Session
+	sessions      *iginx.SessionGroup
+	rest          *restClient
+	printResponse bool
+
+	// failovers counts the failovers of the pooled sessions this worker used
+	failovers uint64
 }
 
 func newProcessor() query.Processor { return &processor{} }
 
 func (p *processor) Init(workerNumber int) {
-	//if workerNumber%2 == 0 {
-	//	p.session = client.NewSession("172.16.17.21", "6888", "root", "root")
-	//} else if workerNumber%2 == 1 {
-	//	p.session = client.NewSession("172.16.17.23", "6888", "root", "root")
-	//}
-	p.session = client.NewSession("172.16.17.21", "6888", "root", "root")
-	if err := p.session.Open(); err != nil {
+	p.printResponse = runner.DoPrintResponses()
+	if protocol == protocolRest {
+		p.rest = newRestClient(iginxConfig.URL)
+		return
+	}
+	if pool != nil {
+		return
+	}
+	sessions, err := iginxConfig.NewSessionGroup(workerNumber)
+	if err != nil {
 		log.Fatal(err)
 	}
+	p.sessions = sessions
 }
 
+// ProcessQuery runs a query within the query timeout, if any. A query that
+// runs out of time returns an error wrapping context.DeadlineExceeded, which
+// the benchmark runner counts as a timeout.
 func (p *processor) ProcessQuery(q query.Query, _ bool) ([]*query.Stat, error) {
 	hq := q.(*query.Iginx)
-	lag, err := Do(hq, p.session)
+	ctx := context.Background()
+	if queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, queryTimeout)
+		defer cancel()
+	}
+	return p.processQuery(ctx, hq)
+}
+
+func (p *processor) processQuery(ctx context.Context, hq *query.Iginx) ([]*query.Stat, error) {
+	if p.rest != nil {
+		return p.processRestQuery(hq)
+	}
+	sessions := p.sessions
+	if pool != nil {
+		g, err := pool.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		before := g.Failovers()
+		defer func() {
+			p.failovers += g.Failovers() - before
+			pool.Put(g)
+		}()
+		sessions = g
+	}
+	pr, err := executePlan(ctx, hq, sessions)
 	if err != nil {
 		return nil, err
 	}
-	stat := query.GetStat()
-	stat.Init(q.HumanLabelName(), lag)
-	return []*query.Stat{stat}, nil
+	var lag float64
+	for _, l := range pr.lags {
+		lag += l
+	}
+
+	var res *queryResult
+	var reduceLag float64
+	if hq.Reduction != nil {
+		// The reduction completes the query, so it is timed along with it.
+		start := time.Now()
+		res, err = reduce(hq.Reduction, pr.decode())
+		if err != nil {
+			return nil, err
+		}
+		reduceLag = float64(time.Since(start).Nanoseconds()) / 1e6
+		lag += reduceLag
+	}
+	if p.printResponse || responsesDir != "" {
+		if res == nil {
+			res = pr.decode()
+		}
+		if p.printResponse {
+			prettyPrintResponse(res, hq)
+		}
+		if responsesDir != "" {
+			if err := dumpResponse(responsesDir, res, hq); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Plans of more than one step also report each step on its own.
+	var stats []*query.Stat
+	if len(pr.lags) > 1 || hq.Reduction != nil {
+		label := hq.HumanLabelName()
+		for i, l := range pr.lags {
+			stepLabel := append(append([]byte{}, label...), fmt.Sprintf("-step-%d", i+1)...)
+			stats = append(stats, query.GetPartialStat().Init(stepLabel, l))
+		}
+		if hq.Reduction != nil {
+			reduceLabel := append(append([]byte{}, label...), "-reduce"...)
+			stats = append(stats, query.GetPartialStat().Init(reduceLabel, reduceLag))
+		}
+	}
+	stats = append(stats, query.GetStat().Init(hq.HumanLabelName(), lag))
+	return stats, nil
 }
 
-type QueryResponseColumns struct {
-	Name string
-	Type string
+// processRestQuery posts the REST form of a query. Only the request is timed.
+func (p *processor) processRestQuery(q *query.Iginx) ([]*query.Stat, error) {
+	lag, body, err := p.rest.Do(q, queryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if p.printResponse || responsesDir != "" {
+		res, err := decodeRestResponse(body)
+		if err != nil {
+			return nil, err
+		}
+		if p.printResponse {
+			prettyPrintResponse(res, q)
+		}
+		if responsesDir != "" {
+			if err := dumpResponse(responsesDir, res, q); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return []*query.Stat{query.GetStat().Init(q.HumanLabelName(), lag)}, nil
 }
 
-type QueryResponse struct {
-	Query   string
-	Columns []QueryResponseColumns
-	Dataset []interface{}
-	Count   int
-	Error   string
+// Totals reports how many times this worker had to fail over to another node.
+func (p *processor) Totals() map[string]uint64 {
+	failovers := p.failovers
+	if p.sessions != nil {
+		failovers += p.sessions.Failovers()
+	}
+	return map[string]uint64{"failovers": failovers}
 }
 
-// Do performs the action specified by the given Query. It uses fasthttp, and
-// tries to minimize heap allocations.
-func Do(q *query.Iginx, session *client.Session) (lag float64, err error) {
-	sql := string(q.SqlQuery)
+// Do executes a statement on one of the sessions, giving up once ctx is done.
+// If the session drops, the statement is retried on the next healthy node and
+// only the successful attempt is timed. The result set is returned as is,
+// decoding it is left out of the timing.
+func Do(ctx context.Context, sql string, sessions *iginx.SessionGroup) (lag float64, ds *client.SQLDataSet, err error) {
+	session, err := sessions.Session()
+	if err != nil {
+		return 0, nil, err
+	}
 	start := time.Now()
 	// execute sql
-	_, err = session.ExecuteSQL(sql)
+	ds, err = iginx.ExecuteSQL(ctx, sessions, session, sql)
+	// a query whose ctx is done would fail the same way on every node
+	for err != nil && ctx.Err() == nil && iginx.IsConnectionError(err) {
+		session, err = sessions.Failover(session)
+		if err != nil {
+			break
+		}
+		start = time.Now()
+		ds, err = iginx.ExecuteSQL(ctx, sessions, session, sql)
+	}
 
 	if err != nil {
-		fmt.Println(err)
-		panic(err)
+		return 0, nil, err
 	}
 
 	lag = float64(time.Since(start).Nanoseconds()) / 1e6 // milliseconds
-	return lag, err
+	return lag, ds, err
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/thulab/iginx-client-go/client"
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx"
+)
+
+// planResult holds what running the plan of a query gave: the data set and
+// latency of each statement, in plan order.
+type planResult struct {
+	lags []float64
+	sets []*client.SQLDataSet
+}
+
+// executePlan runs the statements of the plan of a query in order, each on
+// a healthy session, and stops at the first error or once ctx is done.
+func executePlan(ctx context.Context, q *query.Iginx, sessions *iginx.SessionGroup) (*planResult, error) {
+	plan := q.Plan()
+	pr := &planResult{lags: make([]float64, 0, len(plan)), sets: make([]*client.SQLDataSet, 0, len(plan))}
+	for _, statement := range plan {
+		lag, ds, err := Do(ctx, string(statement), sessions)
+		if err != nil {
+			return nil, err
+		}
+		pr.lags = append(pr.lags, lag)
+		pr.sets = append(pr.sets, ds)
+	}
+	return pr, nil
+}
+
+// decode returns the result of the plan, joining the results of its
+// statements on path if there are several.
+func (pr *planResult) decode() *queryResult {
+	results := make([]*queryResult, len(pr.sets))
+	for i, ds := range pr.sets {
+		results[i] = decodeResult(ds)
+	}
+	return joinResults(results)
+}
+
+// joinResults merges the results of several statements into one with a
+// column per path. Rows are matched on time, or on their position if any of
+// the results has no time column. A path returned by several statements keeps
+// the last value that is not null.
+func joinResults(results []*queryResult) *queryResult {
+	if len(results) == 1 {
+		return results[0]
+	}
+	byTime := true
+	for _, res := range results {
+		if len(res.Rows) > 0 && (len(res.Columns) == 0 || res.Columns[0] != timeColumn) {
+			byTime = false
+		}
+	}
+
+	out := &queryResult{Columns: []string{}, Rows: [][]interface{}{}}
+	if byTime {
+		out.Columns = append(out.Columns, timeColumn)
+	}
+	columns := map[string]int{}
+	for _, res := range results {
+		for _, c := range res.Columns {
+			if _, ok := columns[c]; !ok && c != timeColumn {
+				columns[c] = len(out.Columns)
+				out.Columns = append(out.Columns, c)
+			}
+		}
+	}
+
+	rows := map[int64][]interface{}{}
+	var keys []int64
+	for _, res := range results {
+		for i, row := range res.Rows {
+			key := int64(i)
+			if byTime {
+				key, _ = timeOf(res, i)
+			}
+			merged, ok := rows[key]
+			if !ok {
+				merged = make([]interface{}, len(out.Columns))
+				if byTime {
+					merged[0] = key
+				}
+				rows[key] = merged
+				keys = append(keys, key)
+			}
+			for j, v := range row {
+				if c := res.Columns[j]; c != timeColumn && v != nil {
+					merged[columns[c]] = v
+				}
+			}
+		}
+	}
+	sort.Slice(keys, func(a, b int) bool { return keys[a] < keys[b] })
+	for _, key := range keys {
+		out.Rows = append(out.Rows, rows[key])
+	}
+	return out
+}
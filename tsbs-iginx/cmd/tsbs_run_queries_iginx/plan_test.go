@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJoinResults(t *testing.T) {
+	cases := []struct {
+		desc    string
+		results []*queryResult
+		want    *queryResult
+	}{
+		{
+			desc: "single result",
+			results: []*queryResult{
+				{Columns: []string{timeColumn, "cpu.host_0.usage_user"}, Rows: [][]interface{}{{int64(1), 1.0}}},
+			},
+			want: &queryResult{Columns: []string{timeColumn, "cpu.host_0.usage_user"}, Rows: [][]interface{}{{int64(1), 1.0}}},
+		},
+		{
+			desc: "on time",
+			results: []*queryResult{
+				{
+					Columns: []string{timeColumn, "cpu.host_0.usage_user"},
+					Rows:    [][]interface{}{{int64(1), 1.0}, {int64(3), 3.0}},
+				},
+				{
+					Columns: []string{timeColumn, "cpu.host_0.usage_system", "cpu.host_0.usage_user"},
+					Rows:    [][]interface{}{{int64(2), 2.0, nil}, {int64(3), 4.0, nil}},
+				},
+			},
+			want: &queryResult{
+				Columns: []string{timeColumn, "cpu.host_0.usage_user", "cpu.host_0.usage_system"},
+				Rows: [][]interface{}{
+					{int64(1), 1.0, nil},
+					{int64(2), nil, 2.0},
+					{int64(3), 3.0, 4.0},
+				},
+			},
+		},
+		{
+			desc: "statement per host",
+			results: []*queryResult{
+				{
+					Columns: []string{timeColumn, "cpu.host_0.usage_user", "cpu.host_0.usage_system"},
+					Rows:    [][]interface{}{{int64(1), 91.0, 1.0}, {int64(3), 95.0, 3.0}},
+				},
+				{
+					Columns: []string{timeColumn, "cpu.host_1.usage_user", "cpu.host_1.usage_system"},
+					Rows:    [][]interface{}{{int64(3), 92.0, 2.0}},
+				},
+			},
+			want: &queryResult{
+				Columns: []string{timeColumn, "cpu.host_0.usage_user", "cpu.host_0.usage_system", "cpu.host_1.usage_user", "cpu.host_1.usage_system"},
+				Rows: [][]interface{}{
+					{int64(1), 91.0, 1.0, nil, nil},
+					{int64(3), 95.0, 3.0, 92.0, 2.0},
+				},
+			},
+		},
+		{
+			desc: "on position",
+			results: []*queryResult{
+				{Columns: []string{"count"}, Rows: [][]interface{}{{int64(10)}}},
+				{Columns: []string{timeColumn, "cpu.host_0.usage_user"}, Rows: [][]interface{}{{int64(5), 1.0}}},
+			},
+			want: &queryResult{
+				Columns: []string{"count", "cpu.host_0.usage_user"},
+				Rows:    [][]interface{}{{int64(10), 1.0}},
+			},
+		},
+	}
+	for _, c := range cases {
+		if got := joinResults(c.results); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: incorrect result:\ngot\n%v\nwant\n%v", c.desc, got, c.want)
+		}
+	}
+}
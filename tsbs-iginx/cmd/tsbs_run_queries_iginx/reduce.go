@@ -0,0 +1,512 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+const dayMillis = int64(24 * 60 * 60 * 1000)
+
+// entity holds the result columns of the series of a single host or truck,
+// by column name without the measurement and tags, e.g. avg(velocity).
+type entity struct {
+	tags    map[string]string
+	columns map[string]int
+}
+
+// key returns a string identifying the given tags of the entity.
+func (t *entity) key(keys ...string) string {
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i] = t.tags[k]
+	}
+	return strings.Join(values, "\x00")
+}
+
+// value returns the value of the column in the given row, if it is a number.
+func (t *entity) value(res *queryResult, row int, column string) (float64, bool) {
+	j, ok := t.columns[column]
+	if !ok || j >= len(res.Rows[row]) {
+		return 0, false
+	}
+	return toFloat(res.Rows[row][j])
+}
+
+// last returns the last non-null value of the column.
+func (t *entity) last(res *queryResult, column string) (float64, bool) {
+	for row := len(res.Rows) - 1; row >= 0; row-- {
+		if v, ok := t.value(res, row, column); ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// reducer completes a query on the result of its plan.
+type reducer func(r *query.IginxReduction, res *queryResult, entities []*entity) *queryResult
+
+var reducers = map[string]reducer{
+	query.IginxReduceBelow:                reduceBelow,
+	query.IginxReduceHighLoad:             reduceHighLoad,
+	query.IginxReduceLongSessions:         reduceLongSessions,
+	query.IginxReduceFuelConsumption:      reduceFuelConsumption,
+	query.IginxReduceDailyDrivingDuration: reduceDailyDrivingDuration,
+	query.IginxReduceDailyDrivingSession:  reduceDailyDrivingSession,
+	query.IginxReduceAvgLoad:              reduceAvgLoad,
+	query.IginxReduceDailyActivity:        reduceDailyActivity,
+	query.IginxReduceBreakdownFrequency:   reduceBreakdownFrequency,
+	query.IginxReduceFilter:               reduceFilter,
+	query.IginxReduceTopK:                 reduceTopK,
+}
+
+// reduce runs the client-side reduction of a query on its result.
+func reduce(r *query.IginxReduction, res *queryResult) (*queryResult, error) {
+	fn, ok := reducers[r.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown reduction '%s'", r.Kind)
+	}
+	entities, err := groupEntities(r, res)
+	if err != nil {
+		return nil, err
+	}
+	return fn(r, res, entities), nil
+}
+
+// groupEntities reads the tags of the series of each result column and groups
+// the columns by entity. Like the reference queries, entities without their
+// first tag, e.g. trucks without a name, are left out, and so are the series
+// whose tags cannot be told apart.
+func groupEntities(r *query.IginxReduction, res *queryResult) ([]*entity, error) {
+	if len(r.TagKeys) == 0 {
+		return nil, nil
+	}
+	primary := r.TagKeys[0]
+	template, err := pathtemplate.ParseWithTagMode(r.PathTemplate, r.TagMode)
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]*entity{}
+	var entities []*entity
+	for j, column := range res.Columns {
+		if column == timeColumn {
+			continue
+		}
+		fn, series := splitAggregate(column)
+		tags, field, ok := template.Match(series, r.TagKeys)
+		if !ok || tags[primary] == "" {
+			continue
+		}
+		t := &entity{tags: tags}
+		k := t.key(r.TagKeys...)
+		if existing, ok := byKey[k]; ok {
+			t = existing
+		} else {
+			t.columns = map[string]int{}
+			byKey[k] = t
+			entities = append(entities, t)
+		}
+		if fn != "" {
+			field = fn + "(" + field + ")"
+		}
+		t.columns[field] = j
+	}
+	sort.Slice(entities, func(a, b int) bool { return entities[a].tags[primary] < entities[b].tags[primary] })
+	return entities, nil
+}
+
+// splitAggregate splits a column such as avg(readings.truck_1.velocity) into
+// the aggregate function and the series. IginX tags may follow either the
+// path or the closing parenthesis.
+func splitAggregate(column string) (string, string) {
+	open := strings.IndexByte(column, '(')
+	if open <= 0 {
+		return "", column
+	}
+	fn, series := column[:open], column[open+1:]
+	if close := strings.LastIndexByte(series, ')'); close >= 0 {
+		series = series[:close] + series[close+1:]
+	}
+	return fn, series
+}
+
+// timeOf returns the timestamp of a row, in milliseconds.
+func timeOf(res *queryResult, row int) (int64, bool) {
+	if len(res.Columns) == 0 || res.Columns[0] != timeColumn {
+		return 0, false
+	}
+	v, ok := res.Rows[row][0].(int64)
+	return v, ok
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// onlyColumn returns the name of the single column of an entity.
+func onlyColumn(t *entity) string {
+	for c := range t.columns {
+		return c
+	}
+	return ""
+}
+
+// reduceBelow keeps the trucks whose last value is below the threshold, like
+// the HAVING clause of the reference query.
+func reduceBelow(r *query.IginxReduction, res *queryResult, trucks []*entity) *queryResult {
+	out := &queryResult{Columns: []string{"name", "driver", "value"}, Rows: [][]interface{}{}}
+	for _, t := range trucks {
+		if v, ok := t.last(res, onlyColumn(t)); ok && v < r.Threshold {
+			out.Rows = append(out.Rows, []interface{}{t.tags["name"], t.tags["driver"], v})
+		}
+	}
+	return out
+}
+
+// reduceHighLoad keeps the trucks whose last load is over the threshold of
+// their capacity.
+func reduceHighLoad(r *query.IginxReduction, res *queryResult, trucks []*entity) *queryResult {
+	out := &queryResult{Columns: []string{"name", "driver", "current_load", "load_capacity"}, Rows: [][]interface{}{}}
+	for _, t := range trucks {
+		load, ok := t.last(res, "last(current_load)")
+		capacity, ok2 := t.last(res, "last(load_capacity)")
+		if ok && ok2 && capacity != 0 && load/capacity > r.Threshold {
+			out.Rows = append(out.Rows, []interface{}{t.tags["name"], t.tags["driver"], load, capacity})
+		}
+	}
+	return out
+}
+
+// reduceLongSessions keeps the trucks with more than MinCount windows over
+// the threshold.
+func reduceLongSessions(r *query.IginxReduction, res *queryResult, trucks []*entity) *queryResult {
+	out := &queryResult{Columns: []string{"name", "driver"}, Rows: [][]interface{}{}}
+	for _, t := range trucks {
+		column, count := onlyColumn(t), 0
+		for row := range res.Rows {
+			if v, ok := t.value(res, row, column); ok && v > r.Threshold {
+				count++
+			}
+		}
+		if count > r.MinCount {
+			out.Rows = append(out.Rows, []interface{}{t.tags["name"], t.tags["driver"]})
+		}
+	}
+	return out
+}
+
+// reduceFuelConsumption averages, per fleet, the fuel consumption and the
+// nominal one of the windows driven faster than the threshold on average,
+// weighted by the number of readings of each window.
+func reduceFuelConsumption(r *query.IginxReduction, res *queryResult, trucks []*entity) *queryResult {
+	type sums struct{ fuel, nominal, n float64 }
+	byFleet := map[string]*sums{}
+	for _, t := range trucks {
+		fleet := t.tags["fleet"]
+		if fleet == "" {
+			continue
+		}
+		for row := range res.Rows {
+			velocity, ok := t.value(res, row, "avg(velocity)")
+			readings, ok2 := t.value(res, row, "count(velocity)")
+			fuel, ok3 := t.value(res, row, "avg(fuel_consumption)")
+			nominal, ok4 := t.value(res, row, "avg(nominal_fuel_consumption)")
+			if !ok || !ok2 || !ok3 || !ok4 || readings == 0 || velocity <= r.Threshold {
+				continue
+			}
+			s := byFleet[fleet]
+			if s == nil {
+				s = &sums{}
+				byFleet[fleet] = s
+			}
+			s.fuel += fuel * readings
+			s.nominal += nominal * readings
+			s.n += readings
+		}
+	}
+
+	out := &queryResult{Columns: []string{"fleet", "avg_fuel_consumption", "projected_fuel_consumption"}, Rows: [][]interface{}{}}
+	fleets := make([]string, 0, len(byFleet))
+	for fleet := range byFleet {
+		fleets = append(fleets, fleet)
+	}
+	sort.Strings(fleets)
+	for _, fleet := range fleets {
+		s := byFleet[fleet]
+		out.Rows = append(out.Rows, []interface{}{fleet, s.fuel / s.n, s.nominal / s.n})
+	}
+	return out
+}
+
+// reduceDailyDrivingDuration averages, per truck, the whole hours per day
+// spent in ten minute windows driving faster than the threshold.
+func reduceDailyDrivingDuration(r *query.IginxReduction, res *queryResult, trucks []*entity) *queryResult {
+	out := &queryResult{Columns: []string{"fleet", "name", "driver", "avg_daily_hours"}, Rows: [][]interface{}{}}
+	for _, t := range trucks {
+		column := onlyColumn(t)
+		windowsPerDay := map[int64]int64{}
+		for row := range res.Rows {
+			ts, ok := timeOf(res, row)
+			v, ok2 := t.value(res, row, column)
+			if ok && ok2 && v > r.Threshold {
+				windowsPerDay[ts-ts%dayMillis]++
+			}
+		}
+		if len(windowsPerDay) == 0 {
+			continue
+		}
+		var hours int64
+		for _, windows := range windowsPerDay {
+			hours += windows / 6
+		}
+		avg := float64(hours) / float64(len(windowsPerDay))
+		out.Rows = append(out.Rows, []interface{}{t.tags["fleet"], t.tags["name"], t.tags["driver"], avg})
+	}
+	return out
+}
+
+// reduceDailyDrivingSession averages, per truck and day, the length of the
+// driving sessions, that is the time from a ten minute window where the truck
+// starts driving faster than the threshold to the next one where it stops.
+func reduceDailyDrivingSession(r *query.IginxReduction, res *queryResult, trucks []*entity) *queryResult {
+	out := &queryResult{Columns: []string{"name", "day", "duration_ms"}, Rows: [][]interface{}{}}
+	for _, t := range trucks {
+		column := onlyColumn(t)
+		type change struct {
+			start   int64
+			driving bool
+		}
+		var changes []change
+		hasPrev, prevDriving := false, false
+		for row := range res.Rows {
+			ts, ok := timeOf(res, row)
+			v, ok2 := t.value(res, row, column)
+			if !ok || !ok2 {
+				continue
+			}
+			driving := v > r.Threshold
+			if hasPrev && driving != prevDriving {
+				changes = append(changes, change{start: ts, driving: driving})
+			}
+			hasPrev, prevDriving = true, driving
+		}
+
+		type sums struct{ total, n int64 }
+		byDay := map[int64]*sums{}
+		var days []int64
+		for c := 0; c+1 < len(changes); c++ {
+			if !changes[c].driving {
+				continue
+			}
+			day := changes[c].start - changes[c].start%dayMillis
+			s := byDay[day]
+			if s == nil {
+				s = &sums{}
+				byDay[day] = s
+				days = append(days, day)
+			}
+			s.total += changes[c+1].start - changes[c].start
+			s.n++
+		}
+		for _, day := range days {
+			s := byDay[day]
+			out.Rows = append(out.Rows, []interface{}{t.tags["name"], day, float64(s.total) / float64(s.n)})
+		}
+	}
+	return out
+}
+
+// reduceAvgLoad averages the load percentage of the trucks per fleet, model
+// and load capacity.
+func reduceAvgLoad(_ *query.IginxReduction, res *queryResult, trucks []*entity) *queryResult {
+	type group struct {
+		fleet, model string
+		capacity     float64
+		total, n     float64
+	}
+	groups := map[string]*group{}
+	for _, t := range trucks {
+		load, ok := t.last(res, "avg(current_load)")
+		capacity, ok2 := t.last(res, "avg(load_capacity)")
+		if !ok || !ok2 || capacity == 0 {
+			continue
+		}
+		k := fmt.Sprintf("%s\x00%s\x00%v", t.tags["fleet"], t.tags["model"], capacity)
+		g := groups[k]
+		if g == nil {
+			g = &group{fleet: t.tags["fleet"], model: t.tags["model"], capacity: capacity}
+			groups[k] = g
+		}
+		g.total += load / capacity
+		g.n++
+	}
+
+	out := &queryResult{Columns: []string{"fleet", "model", "load_capacity", "avg_load_percentage"}, Rows: [][]interface{}{}}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		g := groups[k]
+		out.Rows = append(out.Rows, []interface{}{g.fleet, g.model, g.capacity, g.total / g.n})
+	}
+	return out
+}
+
+// reduceDailyActivity sums, per fleet, model and day, the readings of the ten
+// minute windows whose average status is below the threshold, as a fraction
+// of the 144 windows of a day.
+func reduceDailyActivity(r *query.IginxReduction, res *queryResult, trucks []*entity) *queryResult {
+	type group struct {
+		fleet, model string
+		day          int64
+		readings     float64
+	}
+	groups := map[string]*group{}
+	for _, t := range trucks {
+		for row := range res.Rows {
+			ts, ok := timeOf(res, row)
+			status, ok2 := t.value(res, row, "avg(status)")
+			count, ok3 := t.value(res, row, "count(status)")
+			if !ok || !ok2 || !ok3 || status >= r.Threshold {
+				continue
+			}
+			day := ts - ts%dayMillis
+			k := fmt.Sprintf("%020d\x00%s", day, t.key("fleet", "model"))
+			g := groups[k]
+			if g == nil {
+				g = &group{fleet: t.tags["fleet"], model: t.tags["model"], day: day}
+				groups[k] = g
+			}
+			g.readings += count
+		}
+	}
+
+	out := &queryResult{Columns: []string{"fleet", "model", "day", "daily_activity"}, Rows: [][]interface{}{}}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		g := groups[k]
+		out.Rows = append(out.Rows, []interface{}{g.fleet, g.model, g.day, g.readings / 144})
+	}
+	return out
+}
+
+// reduceBreakdownFrequency counts, per model, the ten minute windows in which
+// a truck breaks down, that is its average status is below the threshold
+// while it was not in its previous window with readings.
+func reduceBreakdownFrequency(r *query.IginxReduction, res *queryResult, trucks []*entity) *queryResult {
+	byModel := map[string]int64{}
+	for _, t := range trucks {
+		column := onlyColumn(t)
+		hasPrev, prevBroken := false, false
+		for row := range res.Rows {
+			status, ok := t.value(res, row, column)
+			if !ok {
+				continue
+			}
+			broken := status < r.Threshold
+			if hasPrev && !prevBroken && broken {
+				byModel[t.tags["model"]]++
+			}
+			hasPrev, prevBroken = true, broken
+		}
+	}
+
+	out := &queryResult{Columns: []string{"model", "count"}, Rows: [][]interface{}{}}
+	models := make([]string, 0, len(byModel))
+	for model := range byModel {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for _, model := range models {
+		out.Rows = append(out.Rows, []interface{}{model, byModel[model]})
+	}
+	return out
+}
+
+// reduceFilter keeps the values of each entity at the times its Field is over
+// the threshold, and the rows where any entity is kept.
+func reduceFilter(r *query.IginxReduction, res *queryResult, entities []*entity) *queryResult {
+	out := &queryResult{Columns: res.Columns, Rows: [][]interface{}{}}
+	for row := range res.Rows {
+		filtered := make([]interface{}, len(res.Columns))
+		kept := false
+		for _, e := range entities {
+			if v, ok := e.value(res, row, r.Field); !ok || v <= r.Threshold {
+				continue
+			}
+			for _, j := range e.columns {
+				filtered[j] = res.Rows[row][j]
+			}
+			kept = true
+		}
+		if !kept {
+			continue
+		}
+		if t, ok := timeOf(res, row); ok {
+			filtered[0] = t
+		}
+		out.Rows = append(out.Rows, filtered)
+	}
+	return out
+}
+
+// reduceTopK takes the max of each row across all series and keeps the Limit
+// latest rows with a value, newest first, like grouping by time only and
+// ORDER BY time DESC LIMIT. The max is named after the Field, if any.
+func reduceTopK(r *query.IginxReduction, res *queryResult, _ []*entity) *queryResult {
+	column := "max"
+	if r.Field != "" {
+		column = "max(" + r.Field + ")"
+	}
+	hasTime := len(res.Columns) > 0 && res.Columns[0] == timeColumn
+	out := &queryResult{Columns: []string{column}, Rows: [][]interface{}{}}
+	if hasTime {
+		out.Columns = []string{timeColumn, column}
+	}
+	for row := len(res.Rows) - 1; row >= 0 && len(out.Rows) < r.Limit; row-- {
+		max, found := 0.0, false
+		for j, v := range res.Rows[row] {
+			if j == 0 && hasTime {
+				continue
+			}
+			if f, ok := toFloat(v); ok && (!found || f > max) {
+				max, found = f, true
+			}
+		}
+		if !found {
+			continue
+		}
+		if hasTime {
+			out.Rows = append(out.Rows, []interface{}{res.Rows[row][0], max})
+		} else {
+			out.Rows = append(out.Rows, []interface{}{max})
+		}
+	}
+	return out
+}
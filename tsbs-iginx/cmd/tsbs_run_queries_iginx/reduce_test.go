@@ -0,0 +1,318 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+const tenMinutesMillis = int64(10 * 60 * 1000)
+
+var testTruckTagKeys = []string{"name", "fleet", "driver", "model", "device_version"}
+
+const (
+	truck1 = "truck_1.North.Derek.F-150.v1_0"
+	truck2 = "truck_2.South.Trish.G-2000.v1_5"
+	// truck3 misses a tag, so its series cannot be told apart.
+	truck3 = "truck_3.West.H-2.v1_0"
+)
+
+func testReduction(kind string, threshold float64, minCount int) *query.IginxReduction {
+	return &query.IginxReduction{
+		Kind:         kind,
+		PathTemplate: pathtemplate.Default,
+		TagKeys:      testTruckTagKeys,
+		Threshold:    threshold,
+		MinCount:     minCount,
+	}
+}
+
+func TestSplitAggregate(t *testing.T) {
+	cases := []struct {
+		column, fn, series string
+	}{
+		{"readings.truck_1.velocity", "", "readings.truck_1.velocity"},
+		{"avg(readings.truck_1.velocity)", "avg", "readings.truck_1.velocity"},
+		{"avg(readings.truck_1.velocity{fleet=North})", "avg", "readings.truck_1.velocity{fleet=North}"},
+		{"avg(readings.truck_1.velocity){fleet=North}", "avg", "readings.truck_1.velocity{fleet=North}"},
+	}
+	for _, c := range cases {
+		fn, series := splitAggregate(c.column)
+		if fn != c.fn || series != c.series {
+			t.Errorf("%s: got %s, %s want %s, %s", c.column, fn, series, c.fn, c.series)
+		}
+	}
+}
+
+func TestReduce(t *testing.T) {
+	cases := []struct {
+		desc      string
+		reduction *query.IginxReduction
+		res       *queryResult
+		want      *queryResult
+	}{
+		{
+			desc:      "below",
+			reduction: testReduction(query.IginxReduceBelow, 0.1, 0),
+			res: &queryResult{
+				Columns: []string{
+					"last(diagnostics." + truck1 + ".fuel_state)",
+					"last(diagnostics." + truck2 + ".fuel_state)",
+					"last(diagnostics." + truck3 + ".fuel_state)",
+				},
+				Rows: [][]interface{}{{0.05, 0.5, 0.01}},
+			},
+			want: &queryResult{
+				Columns: []string{"name", "driver", "value"},
+				Rows:    [][]interface{}{{"truck_1", "Derek", 0.05}},
+			},
+		},
+		{
+			desc:      "high load",
+			reduction: testReduction(query.IginxReduceHighLoad, 0.9, 0),
+			res: &queryResult{
+				Columns: []string{
+					"last(diagnostics." + truck1 + ".current_load)",
+					"last(diagnostics." + truck1 + ".load_capacity)",
+					"last(diagnostics." + truck2 + ".current_load)",
+					"last(diagnostics." + truck2 + ".load_capacity)",
+				},
+				Rows: [][]interface{}{{1900.0, 2000.0, 1000.0, 2000.0}},
+			},
+			want: &queryResult{
+				Columns: []string{"name", "driver", "current_load", "load_capacity"},
+				Rows:    [][]interface{}{{"truck_1", "Derek", 1900.0, 2000.0}},
+			},
+		},
+		{
+			desc:      "long sessions",
+			reduction: testReduction(query.IginxReduceLongSessions, 1, 1),
+			res: &queryResult{
+				Columns: []string{timeColumn, "avg(readings." + truck1 + ".velocity)", "avg(readings." + truck2 + ".velocity)"},
+				Rows: [][]interface{}{
+					{int64(0), 10.0, 10.0},
+					{int64(600000), 12.0, 0.5},
+					{int64(1200000), nil, nil},
+				},
+			},
+			want: &queryResult{
+				Columns: []string{"name", "driver"},
+				Rows:    [][]interface{}{{"truck_1", "Derek"}},
+			},
+		},
+		{
+			desc:      "fuel consumption",
+			reduction: testReduction(query.IginxReduceFuelConsumption, 1, 0),
+			res: &queryResult{
+				Columns: []string{
+					timeColumn,
+					"avg(readings." + truck1 + ".fuel_consumption)",
+					"avg(readings." + truck1 + ".nominal_fuel_consumption)",
+					"avg(readings." + truck1 + ".velocity)",
+					"count(readings." + truck1 + ".velocity)",
+				},
+				Rows: [][]interface{}{
+					{int64(0), 20.0, 15.0, 50.0, int64(60)},
+					{int64(600000), 40.0, 10.0, 60.0, int64(20)},
+					{int64(1200000), 90.0, 15.0, 0.5, int64(60)},
+					{int64(1800000), nil, nil, nil, int64(0)},
+				},
+			},
+			want: &queryResult{
+				Columns: []string{"fleet", "avg_fuel_consumption", "projected_fuel_consumption"},
+				Rows:    [][]interface{}{{"North", 25.0, 13.75}},
+			},
+		},
+		{
+			desc:      "daily driving duration",
+			reduction: testReduction(query.IginxReduceDailyDrivingDuration, 1, 0),
+			res: &queryResult{
+				Columns: []string{timeColumn, "avg(readings." + truck1 + ".velocity)"},
+				Rows: func() [][]interface{} {
+					var rows [][]interface{}
+					// 7 windows of driving on the first day, 12 on the second.
+					for w := int64(0); w < 7; w++ {
+						rows = append(rows, []interface{}{w * tenMinutesMillis, 10.0})
+					}
+					for w := int64(0); w < 12; w++ {
+						rows = append(rows, []interface{}{dayMillis + w*tenMinutesMillis, 10.0})
+					}
+					return rows
+				}(),
+			},
+			want: &queryResult{
+				Columns: []string{"fleet", "name", "driver", "avg_daily_hours"},
+				Rows:    [][]interface{}{{"North", "truck_1", "Derek", 1.5}},
+			},
+		},
+		{
+			desc:      "daily driving session",
+			reduction: testReduction(query.IginxReduceDailyDrivingSession, 5, 0),
+			res: &queryResult{
+				Columns: []string{timeColumn, "avg(readings." + truck1 + ".velocity)"},
+				Rows: [][]interface{}{
+					{int64(0), 0.0},
+					{int64(600000), 10.0},
+					{int64(1200000), 10.0},
+					{int64(1800000), 0.0},
+					{int64(2400000), 10.0},
+					{int64(3000000), 0.0},
+					{int64(3600000), 10.0},
+				},
+			},
+			want: &queryResult{
+				Columns: []string{"name", "day", "duration_ms"},
+				Rows:    [][]interface{}{{"truck_1", int64(0), 900000.0}},
+			},
+		},
+		{
+			desc:      "avg load",
+			reduction: testReduction(query.IginxReduceAvgLoad, 0, 0),
+			res: &queryResult{
+				Columns: []string{
+					"avg(diagnostics." + truck1 + ".current_load)",
+					"avg(diagnostics." + truck1 + ".load_capacity)",
+					"avg(diagnostics." + truck2 + ".current_load)",
+					"avg(diagnostics." + truck2 + ".load_capacity)",
+				},
+				Rows: [][]interface{}{{1000.0, 2000.0, 1500.0, 3000.0}},
+			},
+			want: &queryResult{
+				Columns: []string{"fleet", "model", "load_capacity", "avg_load_percentage"},
+				Rows: [][]interface{}{
+					{"North", "F-150", 2000.0, 0.5},
+					{"South", "G-2000", 3000.0, 0.5},
+				},
+			},
+		},
+		{
+			desc:      "daily activity",
+			reduction: testReduction(query.IginxReduceDailyActivity, 1, 0),
+			res: &queryResult{
+				Columns: []string{
+					timeColumn,
+					"avg(diagnostics." + truck1 + ".status)",
+					"count(diagnostics." + truck1 + ".status)",
+				},
+				Rows: [][]interface{}{
+					{int64(0), 0.0, int64(72)},
+					{int64(600000), 2.0, int64(60)},
+					{int64(1200000), 0.5, int64(72)},
+				},
+			},
+			want: &queryResult{
+				Columns: []string{"fleet", "model", "day", "daily_activity"},
+				Rows:    [][]interface{}{{"North", "F-150", int64(0), 1.0}},
+			},
+		},
+		{
+			desc:      "breakdown frequency",
+			reduction: testReduction(query.IginxReduceBreakdownFrequency, 0.5, 0),
+			res: &queryResult{
+				Columns: []string{timeColumn, "avg(diagnostics." + truck1 + ".status)"},
+				Rows: [][]interface{}{
+					{int64(0), 1.2},
+					{int64(600000), 0.1},
+					{int64(1200000), 0.3},
+					{int64(1800000), 2.5},
+					{int64(2400000), nil},
+					{int64(3000000), 0.0},
+				},
+			},
+			want: &queryResult{
+				Columns: []string{"model", "count"},
+				Rows:    [][]interface{}{{"F-150", int64(2)}},
+			},
+		},
+		{
+			desc: "filter",
+			reduction: &query.IginxReduction{
+				Kind:         query.IginxReduceFilter,
+				PathTemplate: pathtemplate.Default,
+				TagKeys:      []string{"hostname", "region"},
+				Field:        "usage_user",
+				Threshold:    90,
+			},
+			res: &queryResult{
+				Columns: []string{
+					timeColumn,
+					"cpu.host_0.eu_west_1.usage_user",
+					"cpu.host_0.eu_west_1.usage_system",
+					"cpu.host_1.eu_west_1.usage_user",
+					"cpu.host_1.eu_west_1.usage_system",
+				},
+				Rows: [][]interface{}{
+					{int64(0), 95.0, 10.0, 50.0, 20.0},
+					{int64(10000), 50.0, 10.0, 50.0, 20.0},
+					{int64(20000), nil, nil, 91.0, 30.0},
+				},
+			},
+			want: &queryResult{
+				Columns: []string{
+					timeColumn,
+					"cpu.host_0.eu_west_1.usage_user",
+					"cpu.host_0.eu_west_1.usage_system",
+					"cpu.host_1.eu_west_1.usage_user",
+					"cpu.host_1.eu_west_1.usage_system",
+				},
+				Rows: [][]interface{}{
+					{int64(0), 95.0, 10.0, nil, nil},
+					{int64(20000), nil, nil, 91.0, 30.0},
+				},
+			},
+		},
+		{
+			desc:      "top-k",
+			reduction: &query.IginxReduction{Kind: query.IginxReduceTopK, Field: "usage_user", Limit: 2},
+			res: &queryResult{
+				Columns: []string{timeColumn, "max(cpu.host_0.usage_user)"},
+				Rows: [][]interface{}{
+					{int64(0), 1.0},
+					{int64(60000), 2.0},
+					{int64(120000), 3.0},
+					{int64(180000), nil},
+				},
+			},
+			want: &queryResult{
+				Columns: []string{timeColumn, "max(usage_user)"},
+				Rows:    [][]interface{}{{int64(120000), 3.0}, {int64(60000), 2.0}},
+			},
+		},
+		{
+			desc:      "top-k across hosts",
+			reduction: &query.IginxReduction{Kind: query.IginxReduceTopK, Field: "usage_user", Limit: 3},
+			res: &queryResult{
+				Columns: []string{timeColumn, "max(cpu.host_0.usage_user)", "max(cpu.host_1.usage_user)", "max(cpu.host_2.usage_user)"},
+				Rows: [][]interface{}{
+					{int64(0), 1.0, 9.0, 5.0},
+					{int64(60000), 2.0, nil, 7.0},
+					{int64(120000), nil, nil, nil},
+					{int64(180000), 8.0, 4.0, nil},
+					{int64(240000), nil, 6.0, 3.0},
+				},
+			},
+			want: &queryResult{
+				Columns: []string{timeColumn, "max(usage_user)"},
+				Rows:    [][]interface{}{{int64(240000), 6.0}, {int64(180000), 8.0}, {int64(60000), 7.0}},
+			},
+		},
+	}
+	for _, c := range cases {
+		got, err := reduce(c.reduction, c.res)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: incorrect result:\ngot\n%v\nwant\n%v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestReduceUnknown(t *testing.T) {
+	if _, err := reduce(testReduction("foo", 0, 0), &queryResult{}); err == nil {
+		t.Errorf("unexpected lack of error for unknown reduction")
+	}
+}
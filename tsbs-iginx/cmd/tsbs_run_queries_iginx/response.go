@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+
+	"github.com/thulab/iginx-client-go/client"
+	"github.com/thulab/iginx-client-go/rpc"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// timeColumn names the column holding the timestamps of a result.
+const timeColumn = "time"
+
+// queryResult is the result set of a query decoded into columns and rows.
+type queryResult struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// decodeResult turns the data set returned by ExecuteSQL into a queryResult.
+// Statements that return no rows, such as inserts, give an empty result.
+func decodeResult(ds *client.SQLDataSet) *queryResult {
+	res := &queryResult{Columns: []string{}, Rows: [][]interface{}{}}
+	if ds == nil {
+		return res
+	}
+	switch ds.Type {
+	case rpc.SqlType_Query:
+		qds := ds.GetQueryDataSet()
+		if qds == nil {
+			return res
+		}
+		hasTime := len(qds.Timestamps) > 0
+		if hasTime {
+			res.Columns = append(res.Columns, timeColumn)
+		}
+		res.Columns = append(res.Columns, qds.Paths...)
+		for i, values := range qds.Values {
+			row := make([]interface{}, 0, len(res.Columns))
+			if hasTime && i < len(qds.Timestamps) {
+				row = append(row, qds.Timestamps[i])
+			}
+			for _, v := range values {
+				row = append(row, decodeValue(v))
+			}
+			res.Rows = append(res.Rows, row)
+		}
+	case rpc.SqlType_CountPoints:
+		res.Columns = []string{"count"}
+		res.Rows = [][]interface{}{{ds.GetPointsNum()}}
+	case rpc.SqlType_ShowTimeSeries:
+		res.Columns = []string{"path", "type"}
+		for _, ts := range ds.GetTimeSeries() {
+			res.Rows = append(res.Rows, []interface{}{ts.GetPath(), ts.GetType().String()})
+		}
+	}
+	return res
+}
+
+// decodeValue makes a value JSON friendly: BINARY values come back as bytes,
+// and JSON has no NaN nor infinities.
+func decodeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Sprint(v)
+		}
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Sprint(v)
+		}
+	}
+	return v
+}
+
+// canonical returns a copy of the result with the time column first and the
+// other columns sorted by name, so that results of the same query can be
+// compared regardless of the order IginX returns the series in.
+func (r *queryResult) canonical() *queryResult {
+	order := make([]int, len(r.Columns))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := r.Columns[order[i]], r.Columns[order[j]]
+		if a == timeColumn || b == timeColumn {
+			return a == timeColumn && b != timeColumn
+		}
+		return a < b
+	})
+
+	c := &queryResult{Columns: make([]string, len(order)), Rows: make([][]interface{}, len(r.Rows))}
+	for i, o := range order {
+		c.Columns[i] = r.Columns[o]
+	}
+	for i, row := range r.Rows {
+		c.Rows[i] = make([]interface{}, len(order))
+		for j, o := range order {
+			if o < len(row) {
+				c.Rows[i][j] = row[o]
+			}
+		}
+	}
+	return c
+}
+
+// planText returns the statements of the plan of a query separated by "; ".
+func planText(q *query.Iginx) string {
+	return string(bytes.Join(q.Plan(), []byte("; ")))
+}
+
+// queryText returns the query as it is sent with the protocol in use.
+func queryText(q *query.Iginx) string {
+	if protocol == protocolRest {
+		return string(q.RestQuery)
+	}
+	return planText(q)
+}
+
+// prettyPrintResponse prints a Query and its response in JSON format with two
+// keys: 'query' which has a value of the query sent to generate the second key
+// 'results' which is an array of each row in the return set.
+func prettyPrintResponse(res *queryResult, q *query.Iginx) {
+	resp := make(map[string]interface{})
+	resp["query"] = queryText(q)
+
+	results := []map[string]interface{}{}
+	for _, row := range res.Rows {
+		r := make(map[string]interface{})
+		for i, v := range row {
+			r[res.Columns[i]] = v
+		}
+		results = append(results, r)
+	}
+	resp["results"] = results
+
+	line, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(string(line) + "\n")
+}
+
+// dumpResponse writes the canonical form of a response to <dir>/<query id>.json.
+func dumpResponse(dir string, res *queryResult, q *query.Iginx) error {
+	c := res.canonical()
+	out := struct {
+		ID    uint64 `json:"id"`
+		Label string `json:"label"`
+		Query string `json:"query"`
+		*queryResult
+	}{
+		ID:          q.GetID(),
+		Label:       string(q.HumanLabel),
+		Query:       queryText(q),
+		queryResult: c,
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.json", q.GetID())), append(b, '\n'), 0644)
+}
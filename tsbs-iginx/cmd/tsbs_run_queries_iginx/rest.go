@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/valyala/fasthttp"
+)
+
+// Protocols the queries can be sent with.
+const (
+	// protocolSession runs the SQL plan of the queries over IginX sessions
+	protocolSession = "session"
+	// protocolRest posts the KairosDB-style JSON form of the queries to the
+	// IginX REST API
+	protocolRest = "rest"
+)
+
+// restQueryPath is where the REST API takes queries, relative to its url.
+const restQueryPath = "/api/v1/query"
+
+// restClient posts queries to the IginX REST API.
+type restClient struct {
+	client fasthttp.Client
+	uri    string
+}
+
+func newRestClient(url string) *restClient {
+	return &restClient{uri: strings.TrimSuffix(url, "/") + restQueryPath}
+}
+
+// Do posts the REST form of a query and returns its latency in milliseconds
+// along with the response body. Reading the body is part of the request, so
+// it is timed too. A non-zero timeout bounds the whole request, a request
+// that runs out of time returns an error wrapping context.DeadlineExceeded.
+func (c *restClient) Do(q *query.Iginx, timeout time.Duration) (lag float64, body []byte, err error) {
+	if len(q.RestQuery) == 0 {
+		return 0, nil, fmt.Errorf("query '%s' has no REST form, run it with --protocol=%s", q.HumanLabel, protocolSession)
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(c.uri)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.SetBody(q.RestQuery)
+
+	start := time.Now()
+	if timeout > 0 {
+		err = c.client.DoTimeout(req, resp, timeout)
+	} else {
+		err = c.client.Do(req, resp)
+	}
+	if errors.Is(err, fasthttp.ErrTimeout) {
+		return 0, nil, fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	lag = float64(time.Since(start).Nanoseconds()) / 1e6 // milliseconds
+
+	if resp.StatusCode() != fasthttp.StatusOK {
+		return 0, nil, fmt.Errorf("REST query returned status %d: %s", resp.StatusCode(), resp.Body())
+	}
+	return lag, append([]byte{}, resp.Body()...), nil
+}
+
+// restResponse is the KairosDB-style answer of the REST API.
+type restResponse struct {
+	Queries []struct {
+		Results []struct {
+			Name   string              `json:"name"`
+			Tags   map[string][]string `json:"tags"`
+			Values [][]json.Number     `json:"values"`
+		} `json:"results"`
+	} `json:"queries"`
+}
+
+// decodeRestResponse turns the body of a REST answer into a queryResult with
+// a column per returned series, joined on time. Series are named after their
+// metric followed by their tags, if any, e.g. cpu.usage_user{hostname=host_1}.
+func decodeRestResponse(body []byte) (*queryResult, error) {
+	var resp restResponse
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("cannot decode REST response: %v", err)
+	}
+
+	var results []*queryResult
+	for _, q := range resp.Queries {
+		for _, r := range q.Results {
+			res := &queryResult{Columns: []string{timeColumn, restSeriesName(r.Name, r.Tags)}, Rows: [][]interface{}{}}
+			for _, point := range r.Values {
+				if len(point) != 2 {
+					return nil, fmt.Errorf("invalid REST data point %v of %s", point, r.Name)
+				}
+				ts, err := point[0].Int64()
+				if err != nil {
+					return nil, fmt.Errorf("invalid REST timestamp %s of %s", point[0], r.Name)
+				}
+				res.Rows = append(res.Rows, []interface{}{ts, restValue(point[1])})
+			}
+			results = append(results, res)
+		}
+	}
+	if len(results) == 0 {
+		return &queryResult{Columns: []string{}, Rows: [][]interface{}{}}, nil
+	}
+	return joinResults(results), nil
+}
+
+func restSeriesName(name string, tags map[string][]string) string {
+	if len(tags) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + strings.Join(tags[k], "|")
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// restValue keeps integers as int64, like the session API returns them.
+func restValue(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+func TestDecodeRestResponse(t *testing.T) {
+	body := []byte(`{"queries":[{"sample_size":4,"results":[
+		{"name":"cpu.host_0.usage_user","values":[[0,1.5],[60000,2]]},
+		{"name":"cpu.usage_user","tags":{"hostname":["host_1"]},"values":[[60000,3.5]]}
+	]}]}`)
+	want := &queryResult{
+		Columns: []string{timeColumn, "cpu.host_0.usage_user", "cpu.usage_user{hostname=host_1}"},
+		Rows: [][]interface{}{
+			{int64(0), 1.5, nil},
+			{int64(60000), int64(2), 3.5},
+		},
+	}
+	got, err := decodeRestResponse(body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result:\ngot\n%v\nwant\n%v", got, want)
+	}
+
+	if _, err := decodeRestResponse([]byte(`{"queries":[{"results":[{"name":"a","values":[[0]]}]}]}`)); err == nil {
+		t.Errorf("unexpected lack of error for a data point without value")
+	}
+}
+
+func TestRestClientDo(t *testing.T) {
+	var gotPath, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		gotPath, gotBody = r.URL.Path, string(b)
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write([]byte(`{"queries":[]}`))
+	}))
+	defer server.Close()
+
+	c := newRestClient(server.URL + "/")
+	q := query.NewIginx()
+	q.RestQuery = []byte(`{"metrics":[]}`)
+	_, body, err := c.Do(q, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != restQueryPath || gotBody != string(q.RestQuery) {
+		t.Errorf("incorrect request: got %s %s", gotPath, gotBody)
+	}
+	if string(body) != `{"queries":[]}` {
+		t.Errorf("incorrect body: got %s", body)
+	}
+
+	q.RestQuery = nil
+	if _, _, err := c.Do(q, 0); err == nil {
+		t.Errorf("unexpected lack of error for a query without REST form")
+	}
+}
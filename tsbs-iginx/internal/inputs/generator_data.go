@@ -127,6 +127,12 @@ func (g *DataGenerator) runSimulator(sim common.Simulator, serializer serialize.
 	return nil
 }
 
+// pathTemplateTarget is implemented by targets whose serializer can lay out
+// series paths according to a path template and tag mode, like IginX.
+type pathTemplateTarget interface {
+	PathTemplateSerializer(template, tagMode string) (serialize.PointSerializer, error)
+}
+
 func (g *DataGenerator) getSerializer(sim common.Simulator, target targets.ImplementedTarget) (serialize.PointSerializer, error) {
 	switch target.TargetName() {
 	case constants.FormatCrateDB:
@@ -136,6 +142,9 @@ func (g *DataGenerator) getSerializer(sim common.Simulator, target targets.Imple
 	case constants.FormatTimescaleDB:
 		g.writeHeader(sim.Headers())
 	}
+	if t, ok := target.(pathTemplateTarget); ok {
+		return t.PathTemplateSerializer(g.config.IginxPathTemplate, g.config.IginxTagMode)
+	}
 	return target.Serializer(), nil
 }
 
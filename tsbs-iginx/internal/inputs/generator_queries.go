@@ -35,6 +35,11 @@ type DevopsGeneratorMaker interface {
 	NewDevops(start, end time.Time, scale int) (queryUtils.QueryGenerator, error)
 }
 
+// DevopsGenericGeneratorMaker creates a query generator for devops-generic use case
+type DevopsGenericGeneratorMaker interface {
+	NewDevopsGeneric(start, end time.Time, scale int, maxMetricCount uint64) (queryUtils.QueryGenerator, error)
+}
+
 // IoTGeneratorMaker creates a quert generator for iot use case
 type IoTGeneratorMaker interface {
 	NewIoT(start, end time.Time, scale int) (queryUtils.QueryGenerator, error)
@@ -158,7 +163,7 @@ func (g *QueryGenerator) addFactory(database string, factory interface{}) error
 	validFactory := false
 
 	switch factory.(type) {
-	case DevopsGeneratorMaker, IoTGeneratorMaker:
+	case DevopsGeneratorMaker, IoTGeneratorMaker, DevopsGenericGeneratorMaker:
 		validFactory = true
 	}
 
@@ -196,6 +201,13 @@ func (g *QueryGenerator) getUseCaseGenerator(c *config.QueryGeneratorConfig) (qu
 		}
 
 		return devopsFactory.NewDevops(g.tsStart, g.tsEnd, scale)
+	case common.UseCaseDevopsGeneric:
+		genericFactory, ok := factory.(DevopsGenericGeneratorMaker)
+		if !ok {
+			return nil, fmt.Errorf(errUseCaseNotImplementedFmt, c.Use, c.Format)
+		}
+
+		return genericFactory.NewDevopsGeneric(g.tsStart, g.tsEnd, scale, c.MaxMetricCountPerHost)
 	default:
 		return nil, fmt.Errorf(errUnknownUseCaseFmt, c.Use)
 	}
@@ -330,6 +330,15 @@ func TestGetUseCaseGenerator(t *testing.T) {
 	}
 	checkType(constants.FormatIginx, igi)
 
+	big.PathTemplate = "{measurement}.{hostname}.{field}"
+	if _, err = big.NewDevops(tsStart, tsEnd, scale); err != nil {
+		t.Errorf("Error creating iginx query generator with a path template: %v", err)
+	}
+	big.PathTemplate = "{hostname}.{measurement}"
+	if _, err = big.NewDevops(tsStart, tsEnd, scale); err == nil {
+		t.Errorf("expected error creating iginx query generator with an invalid path template")
+	}
+
 	bcc.UseTags = true
 	clickt, err := bcc.NewDevops(tsStart, tsEnd, scale)
 	checkType(constants.FormatClickhouse, clickt)
@@ -381,6 +390,49 @@ func TestGetUseCaseGenerator(t *testing.T) {
 	}
 }
 
+func TestGetUseCaseGeneratorDevopsGeneric(t *testing.T) {
+	var useCaseMatrix = map[string]map[string]queryUtils.QueryFillerMaker{
+		common.UseCaseDevopsGeneric: {
+			devops.LabelGenericLastpoint: devops.NewGenericLastPoint,
+		},
+	}
+	c := &config.QueryGeneratorConfig{
+		BaseConfig: common.BaseConfig{
+			Scale:     10,
+			TimeStart: defaultTimeStart,
+			TimeEnd:   defaultTimeEnd,
+			Use:       common.UseCaseDevopsGeneric,
+		},
+		QueryType:             devops.LabelGenericLastpoint,
+		InterleavedNumGroups:  1,
+		MaxMetricCountPerHost: 20,
+	}
+	g := &QueryGenerator{
+		factories:     make(map[string]interface{}),
+		useCaseMatrix: useCaseMatrix,
+	}
+
+	c.Format = constants.FormatIginx
+	if err := g.init(c); err != nil {
+		t.Fatalf("Error initializing query generator: %s", err)
+	}
+	useGen, err := g.getUseCaseGenerator(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := useGen.(*iginx.DevopsGeneric); !ok {
+		t.Errorf("iginx does not give right use case gen: got %T", useGen)
+	}
+
+	c.Format = constants.FormatTimescaleDB
+	want := fmt.Sprintf(errUseCaseNotImplementedFmt, c.Use, c.Format)
+	if _, err := g.getUseCaseGenerator(c); err == nil {
+		t.Errorf("unexpected lack of error for unimplemented use case")
+	} else if got := err.Error(); got != want {
+		t.Errorf("incorrect error:\ngot\n%s\nwant\n%s", got, want)
+	}
+}
+
 // Decoded previously
 var wantQueries = []query.TimescaleDB{
 	{
@@ -0,0 +1,79 @@
+package load
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// hdrScaleFactor converts the microseconds the histograms keep to the
+// milliseconds they are reported in
+const hdrScaleFactor = 1e3
+
+// batchLatencies times the ProcessBatch calls of every worker in a histogram
+// of its own, so that workers do not contend on a lock, and merges them once
+// the workers are done.
+type batchLatencies struct {
+	workers []*hdrhistogram.Histogram
+}
+
+func newBatchLatencies(workers uint) *batchLatencies {
+	b := &batchLatencies{workers: make([]*hdrhistogram.Histogram, workers)}
+	for i := range b.workers {
+		b.workers[i] = newLatencyHistogram()
+	}
+	return b
+}
+
+// newLatencyHistogram returns a histogram of latencies between 1 microsecond
+// and an hour, like the one of the query benchmarks.
+func newLatencyHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, 3600000000, 4)
+}
+
+// record adds the time a worker took to process a batch.
+func (b *batchLatencies) record(workerNum uint, took time.Duration) {
+	b.workers[workerNum].RecordValue(took.Microseconds())
+}
+
+// merge returns the latencies of all workers in one histogram.
+func (b *batchLatencies) merge() *hdrhistogram.Histogram {
+	h := newLatencyHistogram()
+	for _, w := range b.workers {
+		h.Merge(w)
+	}
+	return h
+}
+
+// latencyQuantiles returns the quantiles of h in milliseconds, keyed as the
+// query benchmarks key theirs.
+func latencyQuantiles(h *hdrhistogram.Histogram) map[string]float64 {
+	quantiles := map[string]float64{"q0": 0, "q50": 0, "q95": 0, "q99": 0, "q999": 0, "q100": 0}
+	if h.TotalCount() == 0 {
+		return quantiles
+	}
+	quantiles["q0"] = float64(h.Min()) / hdrScaleFactor
+	quantiles["q50"] = float64(h.ValueAtQuantile(50.0)) / hdrScaleFactor
+	quantiles["q95"] = float64(h.ValueAtQuantile(95.0)) / hdrScaleFactor
+	quantiles["q99"] = float64(h.ValueAtQuantile(99.0)) / hdrScaleFactor
+	quantiles["q999"] = float64(h.ValueAtQuantile(99.9)) / hdrScaleFactor
+	quantiles["q100"] = float64(h.Max()) / hdrScaleFactor
+	return quantiles
+}
+
+// writeHDRLatencies writes the percentile distribution of h, in milliseconds,
+// to path.
+func writeHDRLatencies(h *hdrhistogram.Histogram, path string) error {
+	var b bytes.Buffer
+	bw := bufio.NewWriter(&b)
+	if _, err := h.PercentilesPrint(bw, 10, hdrScaleFactor); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b.Bytes(), 0644)
+}
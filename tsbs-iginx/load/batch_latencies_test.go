@@ -0,0 +1,61 @@
+package load
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchLatencies(t *testing.T) {
+	b := newBatchLatencies(2)
+	for i := 1; i <= 4; i++ {
+		b.record(uint(i%2), time.Duration(i)*time.Millisecond)
+	}
+	h := b.merge()
+	if got := h.TotalCount(); got != 4 {
+		t.Errorf("incorrect count: got %d want 4", got)
+	}
+	q := latencyQuantiles(h)
+	if q["q0"] != 1 || q["q50"] != 2 || q["q100"] != 4 {
+		t.Errorf("incorrect quantiles: got %v", q)
+	}
+	if q := latencyQuantiles(newLatencyHistogram()); q["q100"] != 0 {
+		t.Errorf("incorrect quantiles of an empty histogram: got %v", q)
+	}
+
+	f, err := ioutil.TempFile("", "hdr_latencies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err := writeHDRLatencies(h, f.Name()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, _ := ioutil.ReadFile(f.Name())
+	if !strings.Contains(string(got), "Value") || !strings.Contains(string(got), "#[Max") {
+		t.Errorf("incorrect HDR latencies file:\n%s", got)
+	}
+}
+
+func TestSummaryBatchLatency(t *testing.T) {
+	var b bytes.Buffer
+	printFn = func(s string, args ...interface{}) (n int, err error) {
+		return fmt.Fprintf(&b, s, args...)
+	}
+	br := &CommonBenchmarkRunner{}
+	br.metricCnt = 10
+	br.latencies = newBatchLatencies(1)
+	br.latencies.record(0, 2*time.Millisecond)
+	br.latencies.record(0, 4*time.Millisecond)
+	br.batchLatency = br.latencies.merge()
+	br.summary(time.Second)
+	want := "batch latency of 2 batches: min: 2.00ms, med: 2.00ms, mean: 3.00ms, p95: 4.00ms, p99: 4.00ms, p99.9: 4.00ms, max: 4.00ms\n"
+	if got := b.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("incorrect summary\ngot %s\nwant suffix %s", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package insertstrategy
+
+import "sync"
+
+// ActiveWorkers limits how many of the load workers insert at a time, so that
+// the number of workers can change while loading: all workers are started
+// up front and those numbered from the active count on wait before taking on
+// another batch. It is safe for concurrent use.
+type ActiveWorkers struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	active int
+	closed bool
+}
+
+// NewActiveWorkers returns an ActiveWorkers letting the first n workers
+// insert.
+func NewActiveWorkers(n int) *ActiveWorkers {
+	a := &ActiveWorkers{active: n}
+	a.cond = sync.NewCond(&a.mu)
+	return a
+}
+
+// Set lets the first n workers insert, waking those that were waiting if n
+// grows.
+func (a *ActiveWorkers) Set(n int) {
+	a.mu.Lock()
+	a.active = n
+	a.mu.Unlock()
+	a.cond.Broadcast()
+}
+
+// Active returns the number of workers allowed to insert.
+func (a *ActiveWorkers) Active() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.active
+}
+
+// Close lets all workers through for good, e.g. once there is nothing left
+// to insert.
+func (a *ActiveWorkers) Close() {
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
+	a.cond.Broadcast()
+}
+
+// Wait blocks until the worker is allowed to insert.
+func (a *ActiveWorkers) Wait(workerNum int) {
+	a.mu.Lock()
+	for !a.closed && workerNum >= a.active {
+		a.cond.Wait()
+	}
+	a.mu.Unlock()
+}
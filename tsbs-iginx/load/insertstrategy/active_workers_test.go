@@ -0,0 +1,47 @@
+package insertstrategy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActiveWorkers(t *testing.T) {
+	a := NewActiveWorkers(1)
+	a.Wait(0)
+
+	passed := make(chan int, 2)
+	for _, worker := range []int{1, 2} {
+		go func(worker int) {
+			a.Wait(worker)
+			passed <- worker
+		}(worker)
+	}
+	select {
+	case w := <-passed:
+		t.Fatalf("worker %d passed while inactive", w)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	a.Set(2)
+	if got := a.Active(); got != 2 {
+		t.Errorf("incorrect active workers: got %d want 2", got)
+	}
+	select {
+	case w := <-passed:
+		if w != 1 {
+			t.Errorf("incorrect worker passed: got %d want 1", w)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("worker 1 did not pass once active")
+	}
+
+	a.Close()
+	select {
+	case w := <-passed:
+		if w != 2 {
+			t.Errorf("incorrect worker passed: got %d want 2", w)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("worker 2 did not pass once closed")
+	}
+}
@@ -0,0 +1,129 @@
+package insertstrategy
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Schedule is the open-loop counterpart of SleepRegulator. Instead of making
+// each worker wait between its own inserts, which lowers the offered load
+// whenever the database slows down, it lays all batches out on a fixed
+// timeline from the start of the load: batch n is due at start + n/rate. A
+// worker waits for the due time of the batch it takes on, or starts it right
+// away if it is already late, so that a stall shows up as a growing backlog
+// and latencies measured from the due times instead of being hidden.
+// A Schedule is safe for concurrent use.
+type Schedule struct {
+	nowFn   nowProviderFn
+	sleepFn func(time.Duration)
+
+	mu sync.Mutex
+	// start is when batch number base is due, the following ones are due
+	// every interval after it
+	start    time.Time
+	base     uint64
+	interval time.Duration
+	// taken is the number of batches workers have taken on
+	taken uint64
+
+	// missed is the number of batches started more than an interval late
+	missed uint64
+}
+
+// NewSchedule returns a Schedule of batchesPerSec batches per second from
+// start.
+func NewSchedule(batchesPerSec float64, start time.Time) (*Schedule, error) {
+	interval, err := scheduleInterval(batchesPerSec)
+	if err != nil {
+		return nil, err
+	}
+	return &Schedule{
+		start:    start,
+		interval: interval,
+		nowFn:    time.Now,
+		sleepFn:  time.Sleep,
+	}, nil
+}
+
+func scheduleInterval(batchesPerSec float64) (time.Duration, error) {
+	if batchesPerSec <= 0 {
+		return 0, fmt.Errorf("target rate must be positive, can't be %g", batchesPerSec)
+	}
+	interval := time.Duration(float64(time.Second) / batchesPerSec)
+	if interval <= 0 {
+		return 0, fmt.Errorf("target rate %g is too high", batchesPerSec)
+	}
+	return interval, nil
+}
+
+// SetRate changes the rate of the batches not yet taken on. If the schedule
+// is behind, the backlog is kept and the next batch stays due when it was,
+// otherwise the next batch is due right away.
+func (s *Schedule) SetRate(batchesPerSec float64) error {
+	interval, err := scheduleInterval(batchesPerSec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.due(s.taken)
+	if now := s.nowFn(); next.After(now) {
+		next = now
+	}
+	s.start, s.base, s.interval = next, s.taken, interval
+	return nil
+}
+
+// due returns when batch n is due, s.mu must be held.
+func (s *Schedule) due(n uint64) time.Time {
+	return s.start.Add(time.Duration(n-s.base) * s.interval)
+}
+
+// Wait takes on the next batch of the timeline, waits until it is due and
+// returns its due time, from which its latency is to be measured. A batch
+// started more than an interval after its due time, i.e. after the next one
+// was already due, misses its deadline.
+func (s *Schedule) Wait() time.Time {
+	s.mu.Lock()
+	due := s.due(s.taken)
+	s.taken++
+	interval := s.interval
+	s.mu.Unlock()
+
+	late := s.nowFn().Sub(due)
+	if late < 0 {
+		s.sleepFn(-late)
+	} else if late > interval {
+		atomic.AddUint64(&s.missed, 1)
+	}
+	return due
+}
+
+// Taken returns the number of batches workers have taken on so far.
+func (s *Schedule) Taken() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.taken
+}
+
+// Missed returns the number of batches that missed their deadline so far.
+func (s *Schedule) Missed() uint64 {
+	return atomic.LoadUint64(&s.missed)
+}
+
+// Backlog returns the number of batches due by now that no worker has taken
+// on yet.
+func (s *Schedule) Backlog(now time.Time) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Before(s.start) {
+		return 0
+	}
+	due := s.base + uint64(now.Sub(s.start)/s.interval) + 1
+	if due <= s.taken {
+		return 0
+	}
+	return due - s.taken
+}
@@ -0,0 +1,105 @@
+package insertstrategy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewSchedule(t *testing.T) {
+	start := time.Unix(0, 0)
+	for _, rate := range []float64{0, -1, 1e10} {
+		if _, err := NewSchedule(rate, start); err == nil {
+			t.Errorf("rate %g: unexpected lack of error", rate)
+		}
+	}
+	s, err := NewSchedule(4, start)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.interval != 250*time.Millisecond {
+		t.Errorf("incorrect interval: got %v want 250ms", s.interval)
+	}
+}
+
+func TestScheduleWait(t *testing.T) {
+	start := time.Unix(0, 0)
+	s, _ := NewSchedule(10, start)
+	now := start
+	var slept time.Duration
+	s.nowFn = func() time.Time { return now }
+	s.sleepFn = func(d time.Duration) {
+		slept += d
+		now = now.Add(d)
+	}
+
+	// On time: each batch waits for its due time.
+	for i := 0; i < 3; i++ {
+		due := s.Wait()
+		if want := start.Add(time.Duration(i) * 100 * time.Millisecond); !due.Equal(want) || !now.Equal(want) {
+			t.Errorf("batch %d: got due %v at %v want %v", i, due, now, want)
+		}
+	}
+	if slept != 200*time.Millisecond || s.Missed() != 0 {
+		t.Errorf("incorrect wait: slept %v, missed %d", slept, s.Missed())
+	}
+
+	// Stalled: batch 3 is due at 300ms, batches due up to 750ms are behind.
+	now = start.Add(750 * time.Millisecond)
+	if got := s.Backlog(now); got != 5 {
+		t.Errorf("incorrect backlog: got %d want 5", got)
+	}
+	slept = 0
+	if due := s.Wait(); !due.Equal(start.Add(300 * time.Millisecond)) {
+		t.Errorf("incorrect due time of a late batch: got %v", due)
+	}
+	if slept != 0 || s.Missed() != 1 {
+		t.Errorf("late batch: slept %v, missed %d, want 0 and 1", slept, s.Missed())
+	}
+	if got := s.Backlog(now); got != 4 {
+		t.Errorf("incorrect backlog: got %d want 4", got)
+	}
+	if got := s.Taken(); got != 4 {
+		t.Errorf("incorrect taken batches: got %d want 4", got)
+	}
+	if got := s.Backlog(start.Add(-time.Second)); got != 0 {
+		t.Errorf("incorrect backlog before the start: got %d want 0", got)
+	}
+}
+
+func TestScheduleSetRate(t *testing.T) {
+	start := time.Unix(0, 0)
+	s, _ := NewSchedule(10, start)
+	now := start
+	s.nowFn = func() time.Time { return now }
+	s.sleepFn = func(d time.Duration) { now = now.Add(d) }
+	if err := s.SetRate(0); err == nil {
+		t.Errorf("unexpected lack of error for rate 0")
+	}
+
+	// Ahead of time: the next batch is due right away at the new rate.
+	s.Wait()
+	now = start.Add(50 * time.Millisecond)
+	if err := s.SetRate(20); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if due, want := s.Wait(), start.Add(50*time.Millisecond+time.Duration(i)*50*time.Millisecond); !due.Equal(want) {
+			t.Errorf("batch %d: got due %v want %v", i+1, due, want)
+		}
+	}
+
+	// Behind: the backlog is kept at the new rate.
+	now = start.Add(400 * time.Millisecond)
+	if got := s.Backlog(now); got != 6 {
+		t.Errorf("incorrect backlog: got %d want 6", got)
+	}
+	if err := s.SetRate(5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := s.Backlog(now); got != 2 {
+		t.Errorf("incorrect backlog after slowing down: got %d want 2", got)
+	}
+	if due := s.Wait(); !due.Equal(start.Add(150 * time.Millisecond)) {
+		t.Errorf("incorrect due time of a late batch: got %v", due)
+	}
+}
@@ -27,10 +27,11 @@ func (l *noFlowBenchmarkRunner) RunBenchmark(b targets.Benchmark) {
 		go l.work(b, wg, channels[i%numChannels], i)
 	}
 	// Start scan process - actual data read process
-	scanWithoutFlowControl(b.GetDataSource(), b.GetPointIndexer(numChannels), b.GetBatchFactory(), channels, l.BatchSize, l.Limit)
+	scanWithoutFlowControl(l.dataSource(b), b.GetPointIndexer(numChannels), b.GetBatchFactory(), channels, l.BatchSize, l.Limit)
 	for _, c := range channels {
 		close(c)
 	}
+	l.releaseWorkers()
 	l.postRun(wg, start)
 }
 
@@ -52,10 +53,15 @@ func (l *noFlowBenchmarkRunner) work(b targets.Benchmark, wg *sync.WaitGroup, c
 	proc.Init(int(workerNum), l.DoLoad, l.HashWorkers)
 
 	// Process batches coming from the incoming queue (c)
-	for batch := range c {
-		startedWorkAt := time.Now()
-		l.currPoc = &proc
+	for {
+		l.waitActive(workerNum)
+		batch, ok := <-c
+		if !ok {
+			break
+		}
+		startedWorkAt := l.startBatch()
 		metricCnt, rowCnt := proc.ProcessBatch(batch, l.DoLoad)
+		l.recordBatch(workerNum, time.Since(startedWorkAt))
 		atomic.AddUint64(&l.metricCnt, metricCnt)
 		atomic.AddUint64(&l.rowCnt, rowCnt)
 		l.timeToSleep(workerNum, startedWorkAt)
@@ -66,6 +72,7 @@ func (l *noFlowBenchmarkRunner) work(b targets.Benchmark, wg *sync.WaitGroup, c
 	case targets.ProcessorCloser:
 		c.Close(l.DoLoad)
 	}
+	l.keepTotals(proc, workerNum)
 
 	wg.Done()
 }
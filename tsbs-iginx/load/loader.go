@@ -7,10 +7,12 @@ import (
 	"io/ioutil"
 	"log"
 	"math/rand"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/spf13/pflag"
 	"github.com/timescale/tsbs/load/insertstrategy"
 )
@@ -23,6 +25,15 @@ const (
 	errDBExistsFmt                  = "database \"%s\" exists: aborting."
 )
 
+// Units of the target rate of an open-loop load.
+const (
+	// TargetRateRows counts the target rate in rows, assuming every batch
+	// holds batch-size of them
+	TargetRateRows = "rows"
+	// TargetRateBatches counts the target rate in batches
+	TargetRateBatches = "batches"
+)
+
 // change for more useful testing
 var (
 	printFn = fmt.Printf
@@ -44,6 +55,12 @@ type BenchmarkRunnerConfig struct {
 	ChannelCapacity uint          `yaml:"channel-capacity" mapstructure:"channel-capacity" json:"channel-capacity"`
 	InsertIntervals string        `yaml:"insert-intervals" mapstructure:"insert-intervals" json:"insert-intervals"`
 	ResultsFile     string        `yaml:"results-file" mapstructure:"results-file" json:"results-file"`
+	ReportFile      string        `yaml:"report-file" mapstructure:"report-file" json:"report-file"`
+	ReportFormat    string        `yaml:"report-format" mapstructure:"report-format" json:"report-format"`
+	HDRLatencies    string        `yaml:"hdr-latencies" mapstructure:"hdr-latencies" json:"hdr-latencies"`
+	TargetRate      float64       `yaml:"target-rate" mapstructure:"target-rate" json:"target-rate"`
+	TargetRateUnit  string        `yaml:"target-rate-unit" mapstructure:"target-rate-unit" json:"target-rate-unit"`
+	LoadProfile     string        `yaml:"load-profile" mapstructure:"load-profile" json:"load-profile"`
 	// deprecated, should not be used in other places other than tsbs_load_xx commands
 	FileName string `yaml:"file" mapstructure:"file" json:"file"`
 	Seed     int64  `yaml:"seed" mapstructure:"seed" json:"seed"`
@@ -64,6 +81,46 @@ func (c BenchmarkRunnerConfig) AddToFlagSet(fs *pflag.FlagSet) {
 	fs.String("insert-intervals", "", "Time to wait between each insert, default '' => all workers insert ASAP. '1,2' = worker 1 waits 1s between inserts, worker 2 and others wait 2s")
 	fs.Bool("hash-workers", false, "Whether to consistently hash insert data to the same workers (i.e., the data for a particular host always goes to the same worker)")
 	fs.String("results-file", "", "Write the test results summary json to this file")
+	fs.String("report-file", "", "Also write the write stats of each reporting period to this file, along with the number of batches of each worker")
+	fs.String("report-format", ReportFormatCSV, "Format of report-file: 'csv', or 'json' for a JSON object per line")
+	fs.String("hdr-latencies", "", "Write the High Dynamic Range (HDR) Histogram of batch insert latencies to this file")
+	fs.Float64("target-rate", 0, "Insert on a fixed timeline at this many target-rate-units per second, however fast the database is, "+
+		"and time batches from when they were due. 0 = each worker inserts as insert-intervals allows")
+	fs.String("target-rate-unit", TargetRateRows, "Unit of target-rate: 'rows', assuming batch-size rows per batch, or 'batches'")
+	fs.String("load-profile", "", "Steps to go through while loading, comma-separated, each 'duration:workers=n' or 'duration:rate=r' "+
+		"with r in target-rate-units per second, e.g. '1m:workers=2,1m:workers=4'. A range such as 'workers=1-8' or 'rate=1000-5000' "+
+		"ramps up over the duration of the step. Workers steps override workers, rate steps load open-loop like target-rate. "+
+		"The load ends with the last step")
+}
+
+// batchRate returns the target rate in batches per second.
+func (c BenchmarkRunnerConfig) batchRate() float64 {
+	return c.batchesPerSec(c.TargetRate)
+}
+
+// batchesPerSec converts a rate in target-rate-units per second to batches.
+func (c BenchmarkRunnerConfig) batchesPerSec(rate float64) float64 {
+	if c.TargetRateUnit == TargetRateBatches {
+		return rate
+	}
+	return rate / float64(c.BatchSize)
+}
+
+func (c BenchmarkRunnerConfig) validateTargetRate() error {
+	if c.TargetRate == 0 {
+		return nil
+	}
+	if c.TargetRate < 0 {
+		return fmt.Errorf("target rate must be positive, can't be %g", c.TargetRate)
+	}
+	if c.InsertIntervals != "" {
+		return fmt.Errorf("target-rate and insert-intervals can't be used together")
+	}
+	switch c.TargetRateUnit {
+	case "", TargetRateRows, TargetRateBatches:
+		return nil
+	}
+	return fmt.Errorf("invalid target rate unit '%s', supported: %s, %s", c.TargetRateUnit, TargetRateRows, TargetRateBatches)
 }
 
 type BenchmarkRunner interface {
@@ -78,8 +135,30 @@ type CommonBenchmarkRunner struct {
 	metricCnt      uint64
 	rowCnt         uint64
 	initialRand    *rand.Rand
-	currPoc        *targets.Processor
 	sleepRegulator insertstrategy.SleepRegulator
+
+	// workerTotals are the counters of the processor of each worker, see
+	// targets.ProcessorTotals, totals is their sum once all workers are done
+	workerTotals []map[string]uint64
+	totals       map[string]uint64
+
+	// workerBatches counts the batches each worker processed
+	workerBatches []uint64
+	// latencies times the batches of each worker, batchLatency is their
+	// merge once all workers are done
+	latencies    *batchLatencies
+	batchLatency *hdrhistogram.Histogram
+	// schedule lays the batches out on a fixed timeline in open-loop mode,
+	// nil otherwise
+	schedule *insertstrategy.Schedule
+	// profile is the load profile to go through, if any, loadDone tells it
+	// that all workers are done
+	profile  *loadProfile
+	loadDone chan struct{}
+	// reportDone stops the periodic report, which closes reportStopped once
+	// it has stopped
+	reportDone    chan struct{}
+	reportStopped chan struct{}
 }
 
 // GetBenchmarkRunnerWithBatchSize returns the singleton CommonBenchmarkRunner for use in a benchmark program
@@ -94,6 +173,23 @@ func GetBenchmarkRunner(c BenchmarkRunnerConfig) BenchmarkRunner {
 
 	loader.initialRand = rand.New(rand.NewSource(loader.Seed))
 
+	if err := validateReportFormat(c.ReportFormat); err != nil {
+		panic(fmt.Sprintf("could not initialize BenchmarkRunner: %v", err))
+	}
+	if err := c.validateTargetRate(); err != nil {
+		panic(fmt.Sprintf("could not initialize BenchmarkRunner: %v", err))
+	}
+	if c.LoadProfile != "" {
+		profile, err := c.newLoadProfile()
+		if err != nil {
+			panic(fmt.Sprintf("could not initialize BenchmarkRunner: %v", err))
+		}
+		loader.profile = profile
+		if max := profile.maxWorkers(); max > 0 {
+			loader.Workers = max
+		}
+	}
+
 	var err error
 	if c.InsertIntervals == "" {
 		loader.sleepRegulator = insertstrategy.NoWait()
@@ -111,7 +207,7 @@ func GetBenchmarkRunner(c BenchmarkRunnerConfig) BenchmarkRunner {
 		if c.HashWorkers {
 			loader.ChannelCapacity = defaultChannelCapacityPerWorker
 		} else {
-			loader.ChannelCapacity = c.Workers * defaultChannelCapacityPerWorker
+			loader.ChannelCapacity = loader.Workers * defaultChannelCapacityPerWorker
 		}
 	}
 
@@ -130,12 +226,35 @@ func (l *CommonBenchmarkRunner) preRun(b targets.Benchmark) (*sync.WaitGroup, *t
 		defer cleanupFn()
 	}
 
+	l.workerTotals = make([]map[string]uint64, l.Workers)
+	l.workerBatches = make([]uint64, l.Workers)
+	l.latencies = newBatchLatencies(l.Workers)
+	start := time.Now()
+	batchRate := l.batchRate()
+	if l.profile != nil && l.profile.active == nil {
+		batchRate = l.batchesPerSec(l.profile.steps[0].rate)
+	}
+	if batchRate > 0 {
+		var err error
+		l.schedule, err = insertstrategy.NewSchedule(batchRate, start)
+		if err != nil {
+			panic(fmt.Sprintf("could not schedule the load: %v", err))
+		}
+	}
+	if l.profile != nil {
+		l.loadDone = make(chan struct{})
+		go l.profile.run(l, l.loadDone)
+	}
 	if l.ReportingPeriod.Nanoseconds() > 0 {
-		go l.report(l.ReportingPeriod)
+		l.reportDone = make(chan struct{})
+		l.reportStopped = make(chan struct{})
+		go func() {
+			l.report(l.ReportingPeriod)
+			close(l.reportStopped)
+		}()
 	}
 	wg := &sync.WaitGroup{}
 	wg.Add(int(l.Workers))
-	start := time.Now()
 	return wg, &start
 }
 
@@ -144,7 +263,23 @@ func (l *CommonBenchmarkRunner) postRun(wg *sync.WaitGroup, start *time.Time) {
 	wg.Wait()
 	end := time.Now()
 	took := end.Sub(*start)
+	if l.profile != nil {
+		close(l.loadDone)
+		<-l.profile.stopped
+	}
+	if l.reportDone != nil {
+		close(l.reportDone)
+		<-l.reportStopped
+	}
+	l.sumTotals()
+	l.batchLatency = l.latencies.merge()
 	l.summary(took)
+	if l.HDRLatencies != "" {
+		printFn("Saving High Dynamic Range (HDR) Histogram of batch insert latencies to %s\n", l.HDRLatencies)
+		if err := writeHDRLatencies(l.batchLatency, l.HDRLatencies); err != nil {
+			log.Fatal(err)
+		}
+	}
 	if l.BenchmarkRunnerConfig.ResultsFile != "" {
 		metricRate := float64(l.metricCnt) / took.Seconds()
 		rowRate := float64(l.rowCnt) / took.Seconds()
@@ -158,6 +293,18 @@ func (l *CommonBenchmarkRunner) saveTestResult(took time.Duration, start time.Ti
 	if l.rowCnt > 0 {
 		totals["rowRate"] = rowRate
 	}
+	for name, v := range l.totals {
+		totals[name] = v
+	}
+	if l.batchLatency != nil {
+		totals["batchLatencyQuantiles"] = latencyQuantiles(l.batchLatency)
+	}
+	if l.schedule != nil {
+		totals["missedDeadlines"] = l.schedule.Missed()
+	}
+	if l.profile != nil {
+		totals["steps"] = l.profile.results
+	}
 
 	testResult := LoaderTestResult{
 		ResultFormatVersion: LoaderTestResultVersion,
@@ -200,13 +347,14 @@ func (l *CommonBenchmarkRunner) RunBenchmark(b targets.Benchmark) {
 	}
 
 	// Start scan process - actual data read process
-	scanWithFlowControl(channels, l.BatchSize, l.Limit, b.GetDataSource(), b.GetBatchFactory(), b.GetPointIndexer(uint(len(channels))))
+	scanWithFlowControl(channels, l.BatchSize, l.Limit, l.dataSource(b), b.GetBatchFactory(), b.GetPointIndexer(uint(len(channels))))
 	// After scan process completed (no more data to come) - begin shutdown process
 
 	// Close all communication channels to/from workers
 	for _, c := range channels {
 		c.close()
 	}
+	l.releaseWorkers()
 
 	l.postRun(wg, start)
 }
@@ -281,9 +429,15 @@ func (l *CommonBenchmarkRunner) work(b targets.Benchmark, wg *sync.WaitGroup, c
 
 	// Process batches coming from duplexChannel.toWorker queue
 	// and send ACKs into duplexChannel.toScanner queue
-	for batch := range c.toWorker {
-		startedWorkAt := time.Now()
+	for {
+		l.waitActive(workerNum)
+		batch, ok := <-c.toWorker
+		if !ok {
+			break
+		}
+		startedWorkAt := l.startBatch()
 		metricCnt, rowCnt := proc.ProcessBatch(batch, l.DoLoad)
+		l.recordBatch(workerNum, time.Since(startedWorkAt))
 		atomic.AddUint64(&l.metricCnt, metricCnt)
 		atomic.AddUint64(&l.rowCnt, rowCnt)
 		c.sendToScanner()
@@ -295,10 +449,78 @@ func (l *CommonBenchmarkRunner) work(b targets.Benchmark, wg *sync.WaitGroup, c
 	case targets.ProcessorCloser:
 		c.Close(l.DoLoad)
 	}
+	l.keepTotals(proc, workerNum)
 
 	wg.Done()
 }
 
+// dataSource returns the data source of b, which ends with the load profile
+// if there is one.
+func (l *CommonBenchmarkRunner) dataSource(b targets.Benchmark) targets.DataSource {
+	if l.profile != nil {
+		return l.profile.dataSource(b.GetDataSource())
+	}
+	return b.GetDataSource()
+}
+
+// waitActive blocks until a worker is among the active ones of the current
+// step of the load profile, if there is one.
+func (l *CommonBenchmarkRunner) waitActive(workerNum uint) {
+	if l.profile != nil {
+		l.profile.waitActive(workerNum)
+	}
+}
+
+// releaseWorkers lets all workers go on once the channels are closed, so
+// that those inactive in the current step of the load profile see it.
+func (l *CommonBenchmarkRunner) releaseWorkers() {
+	if l.profile != nil {
+		l.profile.releaseWorkers()
+	}
+}
+
+// startBatch returns when a worker starts on a batch. In open-loop mode it
+// first waits for the batch to be due and returns its due time instead, so
+// that the batch is timed from then.
+func (l *CommonBenchmarkRunner) startBatch() time.Time {
+	if l.schedule != nil {
+		return l.schedule.Wait()
+	}
+	return time.Now()
+}
+
+// recordBatch counts a batch processed by a worker and the time it took
+func (l *CommonBenchmarkRunner) recordBatch(workerNum uint, took time.Duration) {
+	if l.workerBatches != nil {
+		atomic.AddUint64(&l.workerBatches[workerNum], 1)
+	}
+	if l.latencies != nil {
+		l.latencies.record(workerNum, took)
+	}
+	if l.profile != nil {
+		l.profile.record(took)
+	}
+}
+
+// keepTotals keeps the counters of the processor of a worker, if it has any
+func (l *CommonBenchmarkRunner) keepTotals(proc targets.Processor, workerNum uint) {
+	if pt, ok := proc.(targets.ProcessorTotals); ok {
+		l.workerTotals[workerNum] = pt.Totals()
+	}
+}
+
+// sumTotals adds up the counters the processors of all workers kept
+func (l *CommonBenchmarkRunner) sumTotals() {
+	for _, wt := range l.workerTotals {
+		for name, v := range wt {
+			if l.totals == nil {
+				l.totals = make(map[string]uint64)
+			}
+			l.totals[name] += v
+		}
+	}
+}
+
 func (l *CommonBenchmarkRunner) timeToSleep(workerNum uint, startedWorkAt time.Time) {
 	if l.sleepRegulator != nil {
 		l.sleepRegulator.Sleep(int(workerNum), startedWorkAt)
@@ -314,17 +536,61 @@ func (l *CommonBenchmarkRunner) summary(took time.Duration) {
 		rowRate := float64(l.rowCnt) / float64(took.Seconds())
 		printFn("loaded %d rows in %0.3fsec with %d workers (mean rate %0.2f rows/sec)\n", l.rowCnt, took.Seconds(), l.Workers, rowRate)
 	}
+	if l.batchLatency != nil && l.batchLatency.TotalCount() > 0 {
+		q := latencyQuantiles(l.batchLatency)
+		printFn("batch latency of %d batches: min: %0.2fms, med: %0.2fms, mean: %0.2fms, p95: %0.2fms, p99: %0.2fms, p99.9: %0.2fms, max: %0.2fms\n",
+			l.batchLatency.TotalCount(), q["q0"], q["q50"], l.batchLatency.Mean()/hdrScaleFactor, q["q95"], q["q99"], q["q999"], q["q100"])
+	}
+	if l.schedule != nil {
+		printFn("target rate %0.2f batches/sec: %d of %d batches missed their deadline\n", l.batchRate(), l.schedule.Missed(), l.schedule.Taken())
+	}
+	names := make([]string, 0, len(l.totals))
+	for name := range l.totals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		printFn("%s: %d\n", name, l.totals[name])
+	}
 }
 
-// report handles periodic reporting of loading stats
+// report handles periodic reporting of loading stats, until reportDone is
+// closed. Each period is also written to the report file, if any.
 func (l *CommonBenchmarkRunner) report(period time.Duration) {
 	start := time.Now()
 	prevTime := start
 	prevColCount := uint64(0)
 	prevRowCount := uint64(0)
 
-	printFn("time,per. metric/s,metric total,overall metric/s,per. row/s,row total,overall row/s\n")
-	for now := range time.NewTicker(period).C {
+	var rw reportWriter
+	if l.ReportFile != "" {
+		var err error
+		rw, err = newReportWriter(l.ReportFile, l.ReportFormat)
+		if err != nil {
+			fatal("could not create report file: %v", err)
+			return
+		}
+		defer func() {
+			if err := rw.close(); err != nil {
+				log.Printf("could not close report file: %v", err)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	if l.schedule != nil {
+		printFn("time,per. metric/s,metric total,overall metric/s,per. row/s,row total,overall row/s,backlog,missed\n")
+	} else {
+		printFn("time,per. metric/s,metric total,overall metric/s,per. row/s,row total,overall row/s\n")
+	}
+	for {
+		var now time.Time
+		select {
+		case now = <-ticker.C:
+		case <-l.reportDone:
+			return
+		}
 		cCount := atomic.LoadUint64(&l.metricCnt)
 		rCount := atomic.LoadUint64(&l.rowCnt)
 
@@ -332,12 +598,35 @@ func (l *CommonBenchmarkRunner) report(period time.Duration) {
 		took := now.Sub(prevTime)
 		colrate := float64(cCount-prevColCount) / float64(took.Seconds())
 		overallColRate := float64(cCount) / float64(sinceStart.Seconds())
+		rowrate := float64(rCount-prevRowCount) / float64(took.Seconds())
+		overallRowRate := float64(rCount) / float64(sinceStart.Seconds())
+		var backlog, missed uint64
+		var scheduleStats string
+		if l.schedule != nil {
+			backlog, missed = l.schedule.Backlog(now), l.schedule.Missed()
+			scheduleStats = fmt.Sprintf(",%d,%d", backlog, missed)
+		}
 		if rCount > 0 {
-			rowrate := float64(rCount-prevRowCount) / float64(took.Seconds())
-			overallRowRate := float64(rCount) / float64(sinceStart.Seconds())
-			printFn("%d,%0.2f,%E,%0.2f,%0.2f,%E,%0.2f\n", now.Unix(), colrate, float64(cCount), overallColRate, rowrate, float64(rCount), overallRowRate)
+			printFn("%d,%0.2f,%E,%0.2f,%0.2f,%E,%0.2f%s\n", now.Unix(), colrate, float64(cCount), overallColRate, rowrate, float64(rCount), overallRowRate, scheduleStats)
 		} else {
-			printFn("%d,%0.2f,%E,%0.2f,-,-,-\n", now.Unix(), colrate, float64(cCount), overallColRate)
+			printFn("%d,%0.2f,%E,%0.2f,-,-,-%s\n", now.Unix(), colrate, float64(cCount), overallColRate, scheduleStats)
+		}
+		if rw != nil {
+			err := rw.write(&reportRecord{
+				Timestamp:          now.UnixNano() / int64(time.Millisecond),
+				IntervalMetricRate: colrate,
+				MetricTotal:        cCount,
+				OverallMetricRate:  overallColRate,
+				IntervalRowRate:    rowrate,
+				RowTotal:           rCount,
+				OverallRowRate:     overallRowRate,
+				Backlog:            backlog,
+				MissedDeadlines:    missed,
+				WorkerBatches:      l.loadWorkerBatches(),
+			})
+			if err != nil {
+				log.Printf("could not write report file: %v", err)
+			}
 		}
 
 		prevColCount = cCount
@@ -345,3 +634,12 @@ func (l *CommonBenchmarkRunner) report(period time.Duration) {
 		prevTime = now
 	}
 }
+
+// loadWorkerBatches returns the number of batches each worker processed so far
+func (l *CommonBenchmarkRunner) loadWorkerBatches() []uint64 {
+	batches := make([]uint64, len(l.workerBatches))
+	for i := range l.workerBatches {
+		batches[i] = atomic.LoadUint64(&l.workerBatches[i])
+	}
+	return batches
+}
@@ -383,6 +383,7 @@ func TestSummary(t *testing.T) {
 		desc    string
 		metrics uint64
 		rows    uint64
+		totals  map[string]uint64
 		took    time.Duration
 		want    string
 	}{
@@ -414,12 +415,21 @@ func TestSummary(t *testing.T) {
 			took:    time.Second,
 			want:    "\nSummary:\nloaded 10 metrics in 1.000sec with 0 workers (mean rate 10.00 metrics/sec)\nloaded 1 rows in 1.000sec with 0 workers (mean rate 1.00 rows/sec)\n",
 		},
+		{
+			desc:    "include processor totals: 10 metrics, 0 rows, 1 second",
+			metrics: 10,
+			rows:    0,
+			totals:  map[string]uint64{"retries": 3, "failedBatches": 1},
+			took:    time.Second,
+			want:    "\nSummary:\nloaded 10 metrics in 1.000sec with 0 workers (mean rate 10.00 metrics/sec)\nfailedBatches: 1\nretries: 3\n",
+		},
 	}
 
 	for _, c := range cases {
 		br := &CommonBenchmarkRunner{}
 		br.metricCnt = c.metrics
 		br.rowCnt = c.rows
+		br.totals = c.totals
 		var b bytes.Buffer
 		printFn = func(s string, args ...interface{}) (n int, err error) {
 			return fmt.Fprintf(&b, s, args...)
@@ -441,9 +451,18 @@ func TestReport(t *testing.T) {
 		defer m.Unlock()
 		return fmt.Fprintf(&b, s, args...)
 	}
-	br := &CommonBenchmarkRunner{}
+	br := &CommonBenchmarkRunner{reportDone: make(chan struct{})}
 	duration := 200 * time.Millisecond
-	go br.report(duration)
+	reported := make(chan struct{})
+	go func() {
+		br.report(duration)
+		close(reported)
+	}()
+	// stop reporting before another test swaps printFn
+	defer func() {
+		close(br.reportDone)
+		<-reported
+	}()
 
 	time.Sleep(25 * time.Millisecond)
 	if got := atomic.LoadInt64(&counter); got != 1 {
@@ -479,3 +498,31 @@ func TestReport(t *testing.T) {
 		t.Errorf("TestReport: row report ends in -")
 	}
 }
+
+func TestTargetRate(t *testing.T) {
+	cases := []struct {
+		desc      string
+		conf      BenchmarkRunnerConfig
+		wantErr   bool
+		wantBatch float64
+	}{
+		{desc: "closed loop", conf: BenchmarkRunnerConfig{InsertIntervals: "1"}},
+		{desc: "rows", conf: BenchmarkRunnerConfig{TargetRate: 1000, BatchSize: 100}, wantBatch: 10},
+		{desc: "batches", conf: BenchmarkRunnerConfig{TargetRate: 5, TargetRateUnit: TargetRateBatches, BatchSize: 100}, wantBatch: 5},
+		{desc: "negative", conf: BenchmarkRunnerConfig{TargetRate: -1}, wantErr: true},
+		{desc: "with insert intervals", conf: BenchmarkRunnerConfig{TargetRate: 1, InsertIntervals: "1"}, wantErr: true},
+		{desc: "invalid unit", conf: BenchmarkRunnerConfig{TargetRate: 1, TargetRateUnit: "points"}, wantErr: true},
+	}
+	for _, c := range cases {
+		err := c.conf.validateTargetRate()
+		if gotErr := err != nil; gotErr != c.wantErr {
+			t.Errorf("%s: got error %v, want error %v", c.desc, err, c.wantErr)
+			continue
+		}
+		if c.wantBatch != 0 {
+			if got := c.conf.batchRate(); got != c.wantBatch {
+				t.Errorf("%s: incorrect batch rate: got %g want %g", c.desc, got, c.wantBatch)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package load
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+// MixedRunner runs a load benchmark and a query benchmark at the same time,
+// to measure how writing data affects the latency of the queries. Each keeps
+// its own workers and rate limit (insert-intervals for the load, max-rps for
+// the queries) and prints its own summary. Both are started together and
+// their progress is reported over the same time windows.
+type MixedRunner struct {
+	loader BenchmarkRunner
+	// counters is the runner keeping the write counters of loader
+	counters *CommonBenchmarkRunner
+	queries  *query.BenchmarkRunner
+	window   time.Duration
+}
+
+// NewMixedRunner returns a MixedRunner reporting every window, 0 to only
+// print the summaries. loader must be a BenchmarkRunner from
+// GetBenchmarkRunner.
+func NewMixedRunner(loader BenchmarkRunner, queries *query.BenchmarkRunner, window time.Duration) *MixedRunner {
+	var counters *CommonBenchmarkRunner
+	switch l := loader.(type) {
+	case *CommonBenchmarkRunner:
+		counters = l
+	case *noFlowBenchmarkRunner:
+		counters = &l.CommonBenchmarkRunner
+	default:
+		panic(fmt.Sprintf("mixed runs need a loader from GetBenchmarkRunner, got %T", loader))
+	}
+	return &MixedRunner{loader: loader, counters: counters, queries: queries, window: window}
+}
+
+// Run loads b while running the queries read by the query benchmark, and
+// returns once both are done.
+func (m *MixedRunner) Run(b targets.Benchmark, queryPool *sync.Pool, processorCreateFn query.ProcessorCreate) {
+	latencies := query.NewLatencyWindow()
+	m.queries.SetLatencyWindow(latencies)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	start := time.Now()
+	go func() {
+		m.loader.RunBenchmark(b)
+		wg.Done()
+	}()
+	go func() {
+		m.queries.Run(queryPool, processorCreateFn)
+		wg.Done()
+	}()
+
+	done := make(chan struct{})
+	reported := make(chan struct{})
+	if m.window > 0 {
+		go func() {
+			m.report(latencies, start, done)
+			close(reported)
+		}()
+	} else {
+		close(reported)
+	}
+	wg.Wait()
+	close(done)
+	<-reported
+	printFn("mixed run complete in %0.3fsec\n", time.Since(start).Seconds())
+}
+
+// report prints, every window until done is closed, the rate at which rows
+// and metrics were written and the latencies of the queries completed in the
+// window.
+func (m *MixedRunner) report(latencies *query.LatencyWindow, start time.Time, done chan struct{}) {
+	prevTime := start
+	prevMetricCount := uint64(0)
+	prevRowCount := uint64(0)
+
+	ticker := time.NewTicker(m.window)
+	defer ticker.Stop()
+	printFn("time,per. row/s,per. metric/s,queries,errors,timeouts,q50 ms,q95 ms,q99 ms,max ms\n")
+	for {
+		var now time.Time
+		select {
+		case now = <-ticker.C:
+		case <-done:
+			return
+		}
+		metricCount := atomic.LoadUint64(&m.counters.metricCnt)
+		rowCount := atomic.LoadUint64(&m.counters.rowCnt)
+		took := now.Sub(prevTime).Seconds()
+		ws := latencies.Flush()
+		printFn("%d,%0.2f,%0.2f,%d,%d,%d,%0.2f,%0.2f,%0.2f,%0.2f\n", now.Unix(),
+			float64(rowCount-prevRowCount)/took, float64(metricCount-prevMetricCount)/took,
+			ws.Count, ws.Errors, ws.Timeouts,
+			ws.Quantiles["q50"], ws.Quantiles["q95"], ws.Quantiles["q99"], ws.Quantiles["q100"])
+
+		prevMetricCount = metricCount
+		prevRowCount = rowCount
+		prevTime = now
+	}
+}
@@ -0,0 +1,75 @@
+package load
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+type otherBenchmarkRunner struct {
+	BenchmarkRunner
+}
+
+func TestNewMixedRunner(t *testing.T) {
+	common := &CommonBenchmarkRunner{}
+	if m := NewMixedRunner(common, nil, 0); m.counters != common {
+		t.Errorf("incorrect counters for a flow control runner")
+	}
+	noFlow := &noFlowBenchmarkRunner{}
+	if m := NewMixedRunner(noFlow, nil, 0); m.counters != &noFlow.CommonBenchmarkRunner {
+		t.Errorf("incorrect counters for a no flow control runner")
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("unexpected lack of panic for a foreign runner")
+		}
+	}()
+	NewMixedRunner(&otherBenchmarkRunner{}, nil, 0)
+}
+
+func TestMixedRunnerReport(t *testing.T) {
+	var b bytes.Buffer
+	var m sync.Mutex
+	printFn = func(s string, args ...interface{}) (n int, err error) {
+		m.Lock()
+		defer m.Unlock()
+		return fmt.Fprintf(&b, s, args...)
+	}
+	br := &CommonBenchmarkRunner{}
+	mr := NewMixedRunner(br, nil, 100*time.Millisecond)
+	done := make(chan struct{})
+	reported := make(chan struct{})
+	atomic.StoreUint64(&br.rowCnt, 10)
+	atomic.StoreUint64(&br.metricCnt, 20)
+	go func() {
+		mr.report(query.NewLatencyWindow(), time.Now(), done)
+		close(reported)
+	}()
+	time.Sleep(150 * time.Millisecond)
+	close(done)
+	<-reported
+
+	m.Lock()
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	m.Unlock()
+	if len(lines) != 2 {
+		t.Fatalf("incorrect number of lines: got %d want 2\n%s", len(lines), b.String())
+	}
+	header, fields := strings.Split(lines[0], ","), strings.Split(lines[1], ",")
+	if len(fields) != len(header) {
+		t.Fatalf("incorrect number of fields: got %d want %d", len(fields), len(header))
+	}
+	if fields[1] == "0.00" || fields[2] == "0.00" {
+		t.Errorf("write rates not reported: %s", lines[1])
+	}
+	if fields[3] != "0" {
+		t.Errorf("incorrect query count: got %s want 0", fields[3])
+	}
+}
@@ -0,0 +1,289 @@
+package load
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/timescale/tsbs/load/insertstrategy"
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+// rampSteps is the number of equal steps a ramp of rates goes in, a ramp of
+// workers goes in a step per worker count
+const rampSteps = 10
+
+// loadStep is a step of a load profile: the load runs with workers workers,
+// or at rate target-rate-units per second, for duration.
+type loadStep struct {
+	duration time.Duration
+	workers  uint
+	rate     float64
+}
+
+func (s loadStep) String() string {
+	if s.workers > 0 {
+		return fmt.Sprintf("%v with %d workers", s.duration, s.workers)
+	}
+	return fmt.Sprintf("%v at rate %g", s.duration, s.rate)
+}
+
+// parseLoadProfile parses a load profile of comma-separated steps, each as
+// 'duration:workers=n' or 'duration:rate=r'. A range such as 'workers=1-8'
+// or 'rate=1000-5000' ramps linearly over the duration of the step. All
+// steps set either workers or rates.
+func parseLoadProfile(s string) ([]loadStep, error) {
+	var steps []loadStep
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		colon := strings.Index(part, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid load profile step '%s', expected duration:workers=n or duration:rate=r", part)
+		}
+		duration, err := time.ParseDuration(part[:colon])
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid duration of load profile step '%s'", part)
+		}
+		setting := strings.SplitN(part[colon+1:], "=", 2)
+		if len(setting) != 2 {
+			return nil, fmt.Errorf("invalid load profile step '%s', expected duration:workers=n or duration:rate=r", part)
+		}
+		from, to, err := parseProfileRange(setting[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid load profile step '%s': %v", part, err)
+		}
+
+		var ramp []loadStep
+		switch setting[0] {
+		case "workers":
+			if from < 1 || to < 1 || from != float64(uint(from)) || to != float64(uint(to)) {
+				return nil, fmt.Errorf("invalid load profile step '%s': workers must be positive integers", part)
+			}
+			ramp = rampWorkers(duration, uint(from), uint(to))
+		case "rate":
+			if from <= 0 || to <= 0 {
+				return nil, fmt.Errorf("invalid load profile step '%s': rates must be positive", part)
+			}
+			ramp = rampRate(duration, from, to)
+		default:
+			return nil, fmt.Errorf("invalid load profile step '%s': can set workers or rate, not '%s'", part, setting[0])
+		}
+		if len(steps) > 0 && (steps[0].workers > 0) != (ramp[0].workers > 0) {
+			return nil, fmt.Errorf("load profile steps must all set workers or all set rates")
+		}
+		steps = append(steps, ramp...)
+	}
+	return steps, nil
+}
+
+// newLoadProfile parses the load profile of the configuration and checks
+// that it goes with the rest of it.
+func (c BenchmarkRunnerConfig) newLoadProfile() (*loadProfile, error) {
+	steps, err := parseLoadProfile(c.LoadProfile)
+	if err != nil {
+		return nil, err
+	}
+	if steps[0].workers > 0 {
+		if c.HashWorkers {
+			return nil, fmt.Errorf("a load profile of workers can't be used with hash-workers")
+		}
+	} else if c.TargetRate > 0 || c.InsertIntervals != "" {
+		return nil, fmt.Errorf("a load profile of rates can't be used with target-rate or insert-intervals")
+	}
+	return newLoadProfile(steps), nil
+}
+
+// parseProfileRange parses a value or a 'from-to' range of values.
+func parseProfileRange(s string) (from, to float64, err error) {
+	values := strings.SplitN(s, "-", 2)
+	if from, err = strconv.ParseFloat(values[0], 64); err != nil {
+		return 0, 0, err
+	}
+	if len(values) == 1 {
+		return from, from, nil
+	}
+	to, err = strconv.ParseFloat(values[1], 64)
+	return from, to, err
+}
+
+func rampWorkers(duration time.Duration, from, to uint) []loadStep {
+	if from == to {
+		return []loadStep{{duration: duration, workers: from}}
+	}
+	n := int(to) - int(from)
+	if n < 0 {
+		n = -n
+	}
+	n++
+	steps := make([]loadStep, n)
+	for i := range steps {
+		workers := int(from) + i
+		if to < from {
+			workers = int(from) - i
+		}
+		steps[i] = loadStep{duration: duration / time.Duration(n), workers: uint(workers)}
+	}
+	return steps
+}
+
+func rampRate(duration time.Duration, from, to float64) []loadStep {
+	if from == to {
+		return []loadStep{{duration: duration, rate: from}}
+	}
+	steps := make([]loadStep, rampSteps)
+	for i := range steps {
+		rate := from + (to-from)*float64(i)/float64(rampSteps-1)
+		steps[i] = loadStep{duration: duration / rampSteps, rate: rate}
+	}
+	return steps
+}
+
+// stepResult is what a step of a load profile achieved, as saved in the
+// results file.
+type stepResult struct {
+	Step        int     `json:"step"`
+	DurationSec float64 `json:"durationSec"`
+	Workers     uint    `json:"workers,omitempty"`
+	TargetRate  float64 `json:"targetRate,omitempty"`
+	MetricRate  float64 `json:"metricRate"`
+	RowRate     float64 `json:"rowRate"`
+	// BatchLatencyQuantiles are those of the batches done during the step,
+	// in milliseconds
+	BatchLatencyQuantiles map[string]float64 `json:"batchLatencyQuantiles"`
+}
+
+// loadProfile runs the steps of a load profile, changing the number of
+// active workers or the rate of the schedule at the start of each. The load
+// ends with the last step.
+type loadProfile struct {
+	steps []loadStep
+	// active lets the workers of the current step insert, nil if the steps
+	// set rates
+	active *insertstrategy.ActiveWorkers
+
+	mu sync.Mutex
+	// latency times the batches done during the current step
+	latency *hdrhistogram.Histogram
+	results []stepResult
+
+	// over is closed once the last step is over, stopped once run returns
+	over    chan struct{}
+	stopped chan struct{}
+}
+
+func newLoadProfile(steps []loadStep) *loadProfile {
+	p := &loadProfile{
+		steps:   steps,
+		latency: newLatencyHistogram(),
+		over:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if steps[0].workers > 0 {
+		p.active = insertstrategy.NewActiveWorkers(int(steps[0].workers))
+	}
+	return p
+}
+
+// maxWorkers returns the most workers any step needs, 0 if the steps set
+// rates.
+func (p *loadProfile) maxWorkers() uint {
+	max := uint(0)
+	for _, s := range p.steps {
+		if s.workers > max {
+			max = s.workers
+		}
+	}
+	return max
+}
+
+// waitActive blocks until the worker is allowed to insert in the current
+// step.
+func (p *loadProfile) waitActive(workerNum uint) {
+	if p.active != nil {
+		p.active.Wait(int(workerNum))
+	}
+}
+
+// releaseWorkers lets all workers go on for good, to see that there are no
+// batches left.
+func (p *loadProfile) releaseWorkers() {
+	if p.active != nil {
+		p.active.Close()
+	}
+}
+
+func (p *loadProfile) record(took time.Duration) {
+	p.mu.Lock()
+	p.latency.RecordValue(took.Microseconds())
+	p.mu.Unlock()
+}
+
+// run goes through the steps until the last is over or loadDone is closed,
+// printing the results of each as it ends.
+func (p *loadProfile) run(l *CommonBenchmarkRunner, loadDone <-chan struct{}) {
+	defer close(p.stopped)
+	for i, step := range p.steps {
+		if p.active != nil {
+			p.active.Set(int(step.workers))
+		} else if err := l.schedule.SetRate(l.batchesPerSec(step.rate)); err != nil {
+			fatal("could not change the target rate: %v", err)
+			return
+		}
+		start := time.Now()
+		metrics, rows := atomic.LoadUint64(&l.metricCnt), atomic.LoadUint64(&l.rowCnt)
+		timer := time.NewTimer(step.duration)
+		stopped := false
+		select {
+		case <-timer.C:
+		case <-loadDone:
+			timer.Stop()
+			stopped = true
+		}
+		took := time.Since(start).Seconds()
+
+		p.mu.Lock()
+		r := stepResult{
+			Step:                  i + 1,
+			DurationSec:           took,
+			Workers:               step.workers,
+			TargetRate:            step.rate,
+			MetricRate:            float64(atomic.LoadUint64(&l.metricCnt)-metrics) / took,
+			RowRate:               float64(atomic.LoadUint64(&l.rowCnt)-rows) / took,
+			BatchLatencyQuantiles: latencyQuantiles(p.latency),
+		}
+		p.results = append(p.results, r)
+		p.latency.Reset()
+		p.mu.Unlock()
+		q := r.BatchLatencyQuantiles
+		printFn("step %d (%v): %0.2f metrics/sec, %0.2f rows/sec, batch latency med: %0.2fms, p95: %0.2fms, p99: %0.2fms, max: %0.2fms\n",
+			r.Step, step, r.MetricRate, r.RowRate, q["q50"], q["q95"], q["q99"], q["q100"])
+		if stopped {
+			return
+		}
+	}
+	close(p.over)
+}
+
+// dataSource ends the data of ds once the last step is over.
+func (p *loadProfile) dataSource(ds targets.DataSource) targets.DataSource {
+	return &profileDataSource{DataSource: ds, over: p.over}
+}
+
+type profileDataSource struct {
+	targets.DataSource
+	over <-chan struct{}
+}
+
+func (d *profileDataSource) NextItem() data.LoadedPoint {
+	select {
+	case <-d.over:
+		return data.LoadedPoint{}
+	default:
+		return d.DataSource.NextItem()
+	}
+}
@@ -0,0 +1,185 @@
+package load
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+func TestParseLoadProfile(t *testing.T) {
+	cases := []struct {
+		desc    string
+		profile string
+		want    []loadStep
+		wantErr bool
+	}{
+		{
+			desc:    "workers steps",
+			profile: "1m:workers=2, 30s:workers=4",
+			want:    []loadStep{{duration: time.Minute, workers: 2}, {duration: 30 * time.Second, workers: 4}},
+		},
+		{
+			desc:    "rate step",
+			profile: "10s:rate=1000",
+			want:    []loadStep{{duration: 10 * time.Second, rate: 1000}},
+		},
+		{
+			desc:    "workers ramp",
+			profile: "3s:workers=1-3",
+			want: []loadStep{{duration: time.Second, workers: 1}, {duration: time.Second, workers: 2},
+				{duration: time.Second, workers: 3}},
+		},
+		{
+			desc:    "workers ramp down",
+			profile: "2s:workers=2-1",
+			want:    []loadStep{{duration: time.Second, workers: 2}, {duration: time.Second, workers: 1}},
+		},
+		{desc: "no duration", profile: "workers=2", wantErr: true},
+		{desc: "invalid duration", profile: "1x:workers=2", wantErr: true},
+		{desc: "no setting", profile: "1s:2", wantErr: true},
+		{desc: "unknown setting", profile: "1s:threads=2", wantErr: true},
+		{desc: "zero workers", profile: "1s:workers=0", wantErr: true},
+		{desc: "fractional workers", profile: "1s:workers=1.5", wantErr: true},
+		{desc: "negative rate", profile: "1s:rate=0", wantErr: true},
+		{desc: "mixed steps", profile: "1s:workers=1,1s:rate=10", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := parseLoadProfile(c.profile)
+		if gotErr := err != nil; gotErr != c.wantErr {
+			t.Errorf("%s: got error %v, want error %v", c.desc, err, c.wantErr)
+			continue
+		}
+		if !c.wantErr && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v want %v", c.desc, got, c.want)
+		}
+	}
+
+	steps, err := parseLoadProfile("10s:rate=100-1000")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(steps) != rampSteps || steps[0].rate != 100 || steps[rampSteps-1].rate != 1000 || steps[0].duration != time.Second {
+		t.Errorf("incorrect rate ramp: got %v", steps)
+	}
+}
+
+func TestNewLoadProfile(t *testing.T) {
+	cases := []struct {
+		desc    string
+		conf    BenchmarkRunnerConfig
+		wantErr bool
+	}{
+		{desc: "workers", conf: BenchmarkRunnerConfig{LoadProfile: "1s:workers=2", InsertIntervals: "1"}},
+		{desc: "workers with hash workers", conf: BenchmarkRunnerConfig{LoadProfile: "1s:workers=2", HashWorkers: true}, wantErr: true},
+		{desc: "rates", conf: BenchmarkRunnerConfig{LoadProfile: "1s:rate=2"}},
+		{desc: "rates with target rate", conf: BenchmarkRunnerConfig{LoadProfile: "1s:rate=2", TargetRate: 1}, wantErr: true},
+		{desc: "rates with insert intervals", conf: BenchmarkRunnerConfig{LoadProfile: "1s:rate=2", InsertIntervals: "1"}, wantErr: true},
+	}
+	for _, c := range cases {
+		_, err := c.conf.newLoadProfile()
+		if gotErr := err != nil; gotErr != c.wantErr {
+			t.Errorf("%s: got error %v, want error %v", c.desc, err, c.wantErr)
+		}
+	}
+}
+
+type endlessDataSource struct{}
+
+func (d *endlessDataSource) NextItem() data.LoadedPoint {
+	return data.NewLoadedPoint(byte(1))
+}
+
+func (d *endlessDataSource) Headers() *common.GeneratedDataHeaders {
+	return nil
+}
+
+// workerLog keeps which workers processed batches
+type workerLog struct {
+	mu      sync.Mutex
+	workers map[int]int
+}
+
+type loggingProcessor struct {
+	log    *workerLog
+	worker int
+}
+
+func (p *loggingProcessor) Init(workerNum int, _, _ bool) {
+	p.worker = workerNum
+}
+
+func (p *loggingProcessor) ProcessBatch(targets.Batch, bool) (metricCount, rowCount uint64) {
+	time.Sleep(time.Millisecond)
+	p.log.mu.Lock()
+	p.log.workers[p.worker]++
+	p.log.mu.Unlock()
+	return 1, 1
+}
+
+type profileBenchmark struct {
+	log *workerLog
+}
+
+func (b *profileBenchmark) GetDataSource() targets.DataSource     { return &endlessDataSource{} }
+func (b *profileBenchmark) GetBatchFactory() targets.BatchFactory { return &testFactory{} }
+func (b *profileBenchmark) GetPointIndexer(uint) targets.PointIndexer {
+	return &targets.ConstantIndexer{}
+}
+func (b *profileBenchmark) GetProcessor() targets.Processor {
+	return &loggingProcessor{log: b.log}
+}
+func (b *profileBenchmark) GetDBCreator() targets.DBCreator { return nil }
+
+func TestRunBenchmarkWithLoadProfile(t *testing.T) {
+	// every goroutine reading printFn has stopped once RunBenchmark returns
+	oldPrintFn := printFn
+	printFn = func(string, ...interface{}) (int, error) { return 0, nil }
+	defer func() { printFn = oldPrintFn }()
+	for _, noFlowControl := range []bool{false, true} {
+		b := &profileBenchmark{log: &workerLog{workers: map[int]int{}}}
+		runner := GetBenchmarkRunner(BenchmarkRunnerConfig{
+			BatchSize:     1,
+			Workers:       1,
+			DoLoad:        true,
+			NoFlowControl: noFlowControl,
+			LoadProfile:   "100ms:workers=1,100ms:workers=3",
+		})
+		done := make(chan struct{})
+		go func() {
+			runner.RunBenchmark(b)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no flow control %v: load did not end with the profile", noFlowControl)
+		}
+
+		var l *CommonBenchmarkRunner
+		switch r := runner.(type) {
+		case *CommonBenchmarkRunner:
+			l = r
+		case *noFlowBenchmarkRunner:
+			l = &r.CommonBenchmarkRunner
+		}
+		if l.Workers != 3 {
+			t.Errorf("no flow control %v: incorrect number of workers: got %d want 3", noFlowControl, l.Workers)
+		}
+		if got := len(l.profile.results); got != 2 {
+			t.Fatalf("no flow control %v: incorrect number of step results: got %d want 2", noFlowControl, got)
+		}
+		for _, r := range l.profile.results {
+			if r.RowRate <= 0 || r.BatchLatencyQuantiles["q50"] <= 0 {
+				t.Errorf("no flow control %v: step %d reported no load: %+v", noFlowControl, r.Step, r)
+			}
+		}
+		if len(b.log.workers) != 3 {
+			t.Errorf("no flow control %v: not all workers took part: %v", noFlowControl, b.log.workers)
+		}
+	}
+}
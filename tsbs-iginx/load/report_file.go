@@ -0,0 +1,132 @@
+package load
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Formats of the report file.
+const (
+	// ReportFormatCSV writes a header line followed by a line per period
+	ReportFormatCSV = "csv"
+	// ReportFormatJSON writes a JSON object per line and period
+	ReportFormatJSON = "json"
+)
+
+// reportRecord is one reporting period of a load, as written to the report
+// file. Rates are per second, counts are since the start of the load.
+type reportRecord struct {
+	// Timestamp is the end of the period in Unix milliseconds
+	Timestamp          int64   `json:"timestamp"`
+	IntervalMetricRate float64 `json:"intervalMetricRate"`
+	MetricTotal        uint64  `json:"metricTotal"`
+	OverallMetricRate  float64 `json:"overallMetricRate"`
+	IntervalRowRate    float64 `json:"intervalRowRate"`
+	RowTotal           uint64  `json:"rowTotal"`
+	OverallRowRate     float64 `json:"overallRowRate"`
+	// Backlog and MissedDeadlines are those of the schedule of an open-loop
+	// load, 0 otherwise
+	Backlog         uint64 `json:"backlog"`
+	MissedDeadlines uint64 `json:"missedDeadlines"`
+	// WorkerBatches is the number of batches each worker processed
+	WorkerBatches []uint64 `json:"workerBatches"`
+}
+
+// reportWriter writes the records of the report file.
+type reportWriter interface {
+	write(r *reportRecord) error
+	close() error
+}
+
+func validateReportFormat(format string) error {
+	switch format {
+	case "", ReportFormatCSV, ReportFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("invalid report format '%s', supported: %s, %s", format, ReportFormatCSV, ReportFormatJSON)
+}
+
+// newReportWriter creates the report file at path, in the given format, CSV
+// if empty.
+func newReportWriter(path, format string) (reportWriter, error) {
+	if err := validateReportFormat(format); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == ReportFormatJSON {
+		w := bufio.NewWriter(f)
+		return &jsonReportWriter{f: f, w: w, enc: json.NewEncoder(w)}, nil
+	}
+	return &csvReportWriter{f: f, w: csv.NewWriter(f)}, nil
+}
+
+// csvReportWriter writes a column per worker with its batch count, the
+// header is written along with the first record.
+type csvReportWriter struct {
+	f             *os.File
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvReportWriter) write(r *reportRecord) error {
+	if !c.headerWritten {
+		header := []string{"timestamp", "interval_metric_rate", "metric_total", "overall_metric_rate",
+			"interval_row_rate", "row_total", "overall_row_rate", "backlog", "missed_deadlines"}
+		for i := range r.WorkerBatches {
+			header = append(header, fmt.Sprintf("worker_%d_batches", i))
+		}
+		if err := c.w.Write(header); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+	line := []string{
+		strconv.FormatInt(r.Timestamp, 10),
+		strconv.FormatFloat(r.IntervalMetricRate, 'f', 2, 64),
+		strconv.FormatUint(r.MetricTotal, 10),
+		strconv.FormatFloat(r.OverallMetricRate, 'f', 2, 64),
+		strconv.FormatFloat(r.IntervalRowRate, 'f', 2, 64),
+		strconv.FormatUint(r.RowTotal, 10),
+		strconv.FormatFloat(r.OverallRowRate, 'f', 2, 64),
+		strconv.FormatUint(r.Backlog, 10),
+		strconv.FormatUint(r.MissedDeadlines, 10),
+	}
+	for _, n := range r.WorkerBatches {
+		line = append(line, strconv.FormatUint(n, 10))
+	}
+	if err := c.w.Write(line); err != nil {
+		return err
+	}
+	// Flushed on every record so that the file can be followed while the
+	// load runs.
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvReportWriter) close() error {
+	return c.f.Close()
+}
+
+type jsonReportWriter struct {
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (j *jsonReportWriter) write(r *reportRecord) error {
+	if err := j.enc.Encode(r); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
+
+func (j *jsonReportWriter) close() error {
+	return j.f.Close()
+}
@@ -0,0 +1,113 @@
+package load
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportWriter(t *testing.T) {
+	records := []*reportRecord{
+		{Timestamp: 1000, IntervalMetricRate: 10, MetricTotal: 10, OverallMetricRate: 10,
+			IntervalRowRate: 1, RowTotal: 1, OverallRowRate: 1, WorkerBatches: []uint64{1, 0}},
+		{Timestamp: 2000, IntervalMetricRate: 20, MetricTotal: 30, OverallMetricRate: 15,
+			IntervalRowRate: 2, RowTotal: 3, OverallRowRate: 1.5, Backlog: 4, MissedDeadlines: 1, WorkerBatches: []uint64{2, 1}},
+	}
+	dir, err := ioutil.TempDir("", "report_file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report.csv")
+	w, err := newReportWriter(path, ReportFormatCSV)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, r := range records {
+		if err := w.write(r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	w.close()
+	got, _ := ioutil.ReadFile(path)
+	want := "timestamp,interval_metric_rate,metric_total,overall_metric_rate,interval_row_rate,row_total,overall_row_rate," +
+		"backlog,missed_deadlines,worker_0_batches,worker_1_batches\n" +
+		"1000,10.00,10,10.00,1.00,1,1.00,0,0,1,0\n" +
+		"2000,20.00,30,15.00,2.00,3,1.50,4,1,2,1\n"
+	if string(got) != want {
+		t.Errorf("incorrect csv report\ngot\n%s\nwant\n%s", got, want)
+	}
+
+	path = filepath.Join(dir, "report.json")
+	w, err = newReportWriter(path, ReportFormatJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, r := range records {
+		if err := w.write(r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	w.close()
+	got, _ = ioutil.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(got)), "\n")
+	if len(lines) != len(records) {
+		t.Fatalf("incorrect number of json lines: got %d want %d", len(lines), len(records))
+	}
+	for i, line := range lines {
+		var r reportRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("line %d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(&r, records[i]) {
+			t.Errorf("line %d: got %+v want %+v", i, r, records[i])
+		}
+	}
+
+	if _, err := newReportWriter(filepath.Join(dir, "report.xml"), "xml"); err == nil {
+		t.Errorf("unexpected lack of error for an invalid format")
+	}
+}
+
+func TestReportToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report_file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	printFn = func(string, ...interface{}) (int, error) { return 0, nil }
+
+	path := filepath.Join(dir, "report.json")
+	br := &CommonBenchmarkRunner{
+		BenchmarkRunnerConfig: BenchmarkRunnerConfig{ReportFile: path, ReportFormat: ReportFormatJSON},
+		workerBatches:         []uint64{3, 4},
+		reportDone:            make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		br.report(50 * time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(120 * time.Millisecond)
+	close(br.reportDone)
+	<-done
+
+	got, _ := ioutil.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(got)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("incorrect number of records: got %d want 2\n%s", len(lines), got)
+	}
+	var r reportRecord
+	if err := json.Unmarshal([]byte(lines[1]), &r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(r.WorkerBatches, []uint64{3, 4}) {
+		t.Errorf("incorrect worker batches: got %v", r.WorkerBatches)
+	}
+}
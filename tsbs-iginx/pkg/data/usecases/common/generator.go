@@ -40,6 +40,9 @@ type DataGeneratorConfig struct {
 	InterleavedGroupID    uint          `yaml:"interleaved-generation-group-id" mapstructure:"interleaved-generation-group-id"`
 	InterleavedNumGroups  uint          `yaml:"interleaved-generation-groups" mapstructure:"interleaved-generation-groups"`
 	MaxMetricCountPerHost uint64        `yaml:"max-metric-count" mapstructure:"max-metric-count"`
+
+	IginxPathTemplate string `yaml:"iginx-path-template" mapstructure:"iginx-path-template"`
+	IginxTagMode      string `yaml:"iginx-tag-mode" mapstructure:"iginx-tag-mode"`
 }
 
 // Validate checks that the values of the DataGeneratorConfig are reasonable.
@@ -77,6 +80,9 @@ func (c *DataGeneratorConfig) AddToFlagSet(fs *pflag.FlagSet) {
 	fs.Uint("interleaved-generation-groups", 1,
 		"The number of round-robin serialization groups. Use this to scale up data generation to multiple processes.")
 	fs.Uint64("max-metric-count", 100, "Max number of metric fields to generate per host. Used only in devops-generic use-case")
+
+	fs.String("iginx-path-template", "", "IginX only: Layout of the series paths written to iginx-native data, e.g. '{measurement}.{hostname}.{field}'. Empty means the default layout")
+	fs.String("iginx-tag-mode", "path", "IginX only: Where the tags of iginx-native data go: 'path' or 'iginx' to send the tags iginx-path-template does not name as IginX tags")
 }
 
 const defaultTimeStart = "2016-01-01T00:00:00Z"
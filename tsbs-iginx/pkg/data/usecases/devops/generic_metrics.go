@@ -46,12 +46,26 @@ func generateHostMetricCount(hostCount uint64, maxMetricCount uint64) []uint64 {
 	return append(zipfMetricCountHosts, maxMetricCount)
 }
 
+// HostMetricCounts returns the number of generic metrics each host gets, by host
+// index. The counts only depend on the host count and the max metric count, so
+// query generators can tell which hosts have a metric.
+func HostMetricCounts(hostCount uint64, maxMetricCount uint64) []uint64 {
+	return generateHostMetricCount(hostCount, maxMetricCount)
+}
+
+// ShortLivedHostCount returns how many of hostCount hosts are short-lived. They
+// are the last ones, shortest-lived last.
+func ShortLivedHostCount(hostCount uint64) uint64 {
+	return hostCount / 2
+}
+
 // Generate host time/epochs to live using zipf distribution for a half of the hosts.
 // The other half of the hosts is living forever (0 means host lives forever). We sort the array
 // in descending order so the hosts with lognest life start first (host index defines an order of hosts)
 func generateHostEpochsToLive(hostCount uint64, epochs uint64) []uint64 {
-	longLivedHosts := make([]uint64, hostCount-hostCount/2, hostCount-hostCount/2)
-	shortLived := genZipfArray(hostCount/2, epochs)
+	shortLivedCount := ShortLivedHostCount(hostCount)
+	longLivedHosts := make([]uint64, hostCount-shortLivedCount, hostCount-shortLivedCount)
+	shortLived := genZipfArray(shortLivedCount, epochs)
 	sort.Slice(shortLived, func(i, j int) bool {
 		return shortLived[i] > shortLived[j]
 	})
@@ -2,12 +2,15 @@ package query
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"runtime/pprof"
+	"sort"
 	"sync"
 	"time"
 
@@ -23,6 +26,19 @@ const (
 	defaultReadSize = 4 << 20 // 4 MB
 )
 
+// Ways of handling a query that fails. Queries that time out, that is fail
+// with an error wrapping context.DeadlineExceeded, are counted as timeouts
+// whatever the policy.
+const (
+	// OnErrorAbort stops the run at the first failed query
+	OnErrorAbort = "abort"
+	// OnErrorSkip counts the failed query as an error and moves on
+	OnErrorSkip = "skip"
+	// OnErrorRetry runs the failed query again, up to query-retries times,
+	// before counting it as an error
+	OnErrorRetry = "retry"
+)
+
 // BenchmarkRunnerConfig is the configuration of the benchmark runner.
 type BenchmarkRunnerConfig struct {
 	DBName           string `mapstructure:"db-name"`
@@ -38,6 +54,8 @@ type BenchmarkRunnerConfig struct {
 	PrintInterval    uint64 `mapstructure:"print-interval"`
 	PrewarmQueries   bool   `mapstructure:"prewarm-queries"`
 	ResultsFile      string `mapstructure:"results-file"`
+	OnError          string `mapstructure:"on-error"`
+	QueryRetries     uint   `mapstructure:"query-retries"`
 }
 
 // AddToFlagSet adds command line flags needed by the BenchmarkRunnerConfig to the flag set.
@@ -55,6 +73,10 @@ func (c BenchmarkRunnerConfig) AddToFlagSet(fs *pflag.FlagSet) {
 	fs.Int("debug", 0, "Whether to print debug messages.")
 	fs.String("file", "/home/humanfy/tmp_query", "File name to read queries from")
 	fs.String("results-file", "", "Write the test results summary json to this file")
+	fs.String("on-error", OnErrorAbort,
+		"What to do when a query fails: 'abort' the run, 'skip' it, or 'retry' it up to query-retries times. "+
+			"Failed and timed out queries are counted per query type")
+	fs.Uint("query-retries", 3, "Number of times to retry a failed query with on-error=retry")
 }
 
 // BenchmarkRunner contains the common components for running a query benchmarking
@@ -89,6 +111,12 @@ func (b *BenchmarkRunner) SetLimit(limit uint64) {
 	b.Limit = limit
 }
 
+// SetLatencyWindow makes the runner also record the latencies of the queries
+// it completes, past the burn-in, into w. It must be called before Run.
+func (b *BenchmarkRunner) SetLatencyWindow(w *LatencyWindow) {
+	b.sp.getArgs().window = w
+}
+
 // DoPrintResponses indicates whether responses for queries should be printed
 func (b *BenchmarkRunner) DoPrintResponses() bool {
 	return b.PrintResponses
@@ -116,6 +144,15 @@ type Processor interface {
 	ProcessQuery(q Query, isWarm bool) ([]*Stat, error)
 }
 
+// ProcessorTotals is a Processor that also keeps target-specific counters
+// (e.g., failovers) which should be reported together with the query stats
+type ProcessorTotals interface {
+	Processor
+	// Totals returns the counters of the Processor by name. It is called
+	// once the Processor has finished all its queries
+	Totals() map[string]uint64
+}
+
 // GetBufferedReader returns the buffered Reader that should be used by the loader
 func (b *BenchmarkRunner) GetBufferedReader() *bufio.Reader {
 	if b.br == nil {
@@ -146,6 +183,11 @@ func (b *BenchmarkRunner) Run(queryPool *sync.Pool, processorCreateFn ProcessorC
 	if spArgs.burnIn > b.Limit {
 		panic("burn-in is larger than limit")
 	}
+	switch b.OnError {
+	case "", OnErrorAbort, OnErrorSkip, OnErrorRetry:
+	default:
+		panic(fmt.Sprintf("invalid on-error policy '%s', supported: %s, %s, %s", b.OnError, OnErrorAbort, OnErrorSkip, OnErrorRetry))
+	}
 	b.ch = make(chan Query, b.Workers)
 
 	// Launch the stats processor:
@@ -155,9 +197,11 @@ func (b *BenchmarkRunner) Run(queryPool *sync.Pool, processorCreateFn ProcessorC
 
 	// Launch query processors
 	var wg sync.WaitGroup
+	processors := make([]Processor, b.Workers)
 	for i := 0; i < int(b.Workers); i++ {
 		wg.Add(1)
-		go b.processorHandler(&wg, rateLimiter, queryPool, processorCreateFn(), i)
+		processors[i] = processorCreateFn()
+		go b.processorHandler(&wg, rateLimiter, processors[i], i)
 	}
 
 	// Read in jobs, closing the job channel when done:
@@ -178,6 +222,14 @@ func (b *BenchmarkRunner) Run(queryPool *sync.Pool, processorCreateFn ProcessorC
 		log.Fatal(err)
 	}
 
+	processorTotals := sumProcessorTotals(processors)
+	for _, name := range sortedKeys(processorTotals) {
+		_, err = fmt.Printf("%s: %d\n", name, processorTotals[name])
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// (Optional) create a memory profile:
 	if len(b.MemProfile) > 0 {
 		f, err := os.Create(b.MemProfile)
@@ -190,18 +242,44 @@ func (b *BenchmarkRunner) Run(queryPool *sync.Pool, processorCreateFn ProcessorC
 
 	// (Optional) save the results file:
 	if len(b.BenchmarkRunnerConfig.ResultsFile) > 0 {
-		b.saveTestResult(wallTook, wallStart, wallEnd)
+		b.saveTestResult(wallTook, wallStart, wallEnd, processorTotals)
+	}
+}
+
+// sumProcessorTotals adds up the counters of all Processors that keep any
+func sumProcessorTotals(processors []Processor) map[string]uint64 {
+	totals := make(map[string]uint64)
+	for _, p := range processors {
+		if pt, ok := p.(ProcessorTotals); ok {
+			for name, v := range pt.Totals() {
+				totals[name] += v
+			}
+		}
+	}
+	return totals
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+	return keys
 }
 
-func (b *BenchmarkRunner) saveTestResult(took time.Duration, start time.Time, end time.Time) {
+func (b *BenchmarkRunner) saveTestResult(took time.Duration, start time.Time, end time.Time, processorTotals map[string]uint64) {
+	totals := b.sp.GetTotalsMap()
+	for name, v := range processorTotals {
+		totals[name] = v
+	}
 	testResult := LoaderTestResult{
 		ResultFormatVersion: BenchmarkTestResultVersion,
 		RunnerConfig:        b.BenchmarkRunnerConfig,
 		StartTime:           start.UTC().Unix() * 1000,
 		EndTime:             end.UTC().Unix() * 1000,
 		DurationMillis:      took.Milliseconds(),
-		Totals:              b.sp.GetTotalsMap(),
+		Totals:              totals,
 	}
 
 	_, _ = fmt.Printf("Saving results json file to %s\n", b.BenchmarkRunnerConfig.ResultsFile)
@@ -216,17 +294,13 @@ func (b *BenchmarkRunner) saveTestResult(took time.Duration, start time.Time, en
 	}
 }
 
-func (b *BenchmarkRunner) processorHandler(wg *sync.WaitGroup, rateLimiter *rate.Limiter, queryPool *sync.Pool, processor Processor, workerNum int) {
+func (b *BenchmarkRunner) processorHandler(wg *sync.WaitGroup, rateLimiter *rate.Limiter, processor Processor, workerNum int) {
 	processor.Init(workerNum)
 	for query := range b.ch {
 		r := rateLimiter.Reserve()
 		time.Sleep(r.Delay())
 
-		stats, err := processor.ProcessQuery(query, false)
-		if err != nil {
-			panic(err)
-		}
-		b.sp.send(stats)
+		b.sp.send(b.processQuery(processor, query, false))
 
 		// If PrewarmQueries is set, we run the query as 'cold' first (see above),
 		// then we immediately run it a second time and report that as the 'warm' stat.
@@ -234,17 +308,42 @@ func (b *BenchmarkRunner) processorHandler(wg *sync.WaitGroup, rateLimiter *rate
 		spArgs := b.sp.getArgs()
 		if spArgs.prewarmQueries {
 			// Warm run
-			stats, err = processor.ProcessQuery(query, true)
-			if err != nil {
-				panic(err)
-			}
-			b.sp.sendWarm(stats)
+			b.sp.sendWarm(b.processQuery(processor, query, true))
 		}
-		queryPool.Put(query)
+		// The scanner decodes the next query into the pooled one, and gob
+		// leaves the fields absent from the stream as they are, so the query
+		// is reset by Release, which hands it back to its pool.
+		query.Release()
 	}
 	wg.Done()
 }
 
+// processQuery runs a query with the processor and returns the stats to
+// report for it. A query that fails is handled according to the on-error
+// policy, it is reported as an error or timeout Stat under its label if the
+// run goes on.
+func (b *BenchmarkRunner) processQuery(processor Processor, query Query, isWarm bool) []*Stat {
+	stats, err := processor.ProcessQuery(query, isWarm)
+	timedOut := errors.Is(err, context.DeadlineExceeded)
+	for retry := uint(0); err != nil && !timedOut && b.OnError == OnErrorRetry && retry < b.QueryRetries; retry++ {
+		log.Printf("query %d '%s' failed, retrying: %v", query.GetID(), query.HumanLabelName(), err)
+		stats, err = processor.ProcessQuery(query, isWarm)
+		timedOut = errors.Is(err, context.DeadlineExceeded)
+	}
+	switch {
+	case err == nil:
+		return stats
+	case timedOut:
+		log.Printf("query %d '%s' timed out: %v", query.GetID(), query.HumanLabelName(), err)
+		return []*Stat{GetTimeoutStat().Init(query.HumanLabelName(), 0)}
+	case b.OnError == OnErrorSkip || b.OnError == OnErrorRetry:
+		log.Printf("query %d '%s' failed: %v", query.GetID(), query.HumanLabelName(), err)
+		return []*Stat{GetErrorStat().Init(query.HumanLabelName(), 0)}
+	}
+	log.Fatalf("query %d '%s' failed: %v", query.GetID(), query.HumanLabelName(), err)
+	return nil
+}
+
 func getRateLimiter(limitRPS uint64, workers uint) *rate.Limiter {
 	var requestRate = rate.Inf
 	var requestBurst = 0
@@ -1,6 +1,9 @@
 package query
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"golang.org/x/time/rate"
 	"io/ioutil"
 	"math"
@@ -43,8 +46,8 @@ func TestProcessorHandler(t *testing.T) {
 	var requestBurst = 0
 	var rateLimiter *rate.Limiter = rate.NewLimiter(requestRate, requestBurst)
 
-	go b.processorHandler(&wg, rateLimiter, qPool, p1, 0)
-	go b.processorHandler(&wg, rateLimiter, qPool, p2, 5)
+	go b.processorHandler(&wg, rateLimiter, p1, 0)
+	go b.processorHandler(&wg, rateLimiter, p2, 5)
 	for i := 0; i < qLimit; i++ {
 		q := qPool.Get().(*testQuery)
 		b.ch <- q
@@ -63,6 +66,26 @@ func TestProcessorHandler(t *testing.T) {
 	}
 }
 
+func TestProcessorHandlerReleasesQueries(t *testing.T) {
+	b := NewBenchmarkRunner(BenchmarkRunnerConfig{})
+	b.ch = make(chan Query, 1)
+	rateLimiter := rate.NewLimiter(rate.Inf, 0)
+
+	q := NewIginx()
+	q.SqlQuery = []byte("SELECT a FROM *")
+	q.Statements = [][]byte{[]byte("SELECT b FROM *")}
+	q.Reduction = &IginxReduction{Kind: IginxReduceTopK}
+	b.ch <- q
+	close(b.ch)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.processorHandler(&wg, rateLimiter, &testProcessor{}, 0)
+	if len(q.SqlQuery) != 0 || len(q.Statements) != 0 || q.Reduction != nil {
+		t.Errorf("processed query was not released: %s", q)
+	}
+}
+
 func TestProcessorHandlerPreWarm(t *testing.T) {
 	qLimit := 17
 	p1Num := 0
@@ -85,8 +108,8 @@ func TestProcessorHandlerPreWarm(t *testing.T) {
 	var wg sync.WaitGroup
 	qPool := &testQueryPool
 	wg.Add(2)
-	go b.processorHandler(&wg, rateLimiter, qPool, p1, 0)
-	go b.processorHandler(&wg, rateLimiter, qPool, p2, 5)
+	go b.processorHandler(&wg, rateLimiter, p1, 0)
+	go b.processorHandler(&wg, rateLimiter, p2, 5)
 	for i := 0; i < qLimit; i++ {
 		q := qPool.Get().(*testQuery)
 		b.ch <- q
@@ -339,6 +362,31 @@ func (mp *mockProcessor) ProcessQuery(q Query, isWarm bool) ([]*Stat, error) {
 	return mp.processRes, mp.processErr
 }
 
+type totalsProcessor struct {
+	mockProcessor
+	totals map[string]uint64
+}
+
+func (tp *totalsProcessor) Totals() map[string]uint64 {
+	return tp.totals
+}
+
+func TestSumProcessorTotals(t *testing.T) {
+	processors := []Processor{
+		&totalsProcessor{totals: map[string]uint64{"failovers": 2}},
+		&mockProcessor{},
+		&totalsProcessor{totals: map[string]uint64{"failovers": 3, "retries": 1}},
+	}
+	got := sumProcessorTotals(processors)
+	want := map[string]uint64{"failovers": 5, "retries": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect totals: got %v want %v", got, want)
+	}
+	if keys := sortedKeys(got); !reflect.DeepEqual(keys, []string{"failovers", "retries"}) {
+		t.Errorf("incorrect key order: got %v", keys)
+	}
+}
+
 func TestGetRateLimiter(t *testing.T) {
 	type args struct {
 		limitRPS uint64
@@ -362,3 +410,58 @@ func TestGetRateLimiter(t *testing.T) {
 		})
 	}
 }
+
+// failingProcessor fails the first failures calls with err.
+type failingProcessor struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (p *failingProcessor) Init(_ int) {}
+
+func (p *failingProcessor) ProcessQuery(q Query, _ bool) ([]*Stat, error) {
+	p.calls++
+	if p.calls <= p.failures {
+		return nil, p.err
+	}
+	return []*Stat{GetStat().Init(q.HumanLabelName(), 1.0)}, nil
+}
+
+func TestBenchmarkRunnerProcessQuery(t *testing.T) {
+	errFailed := errors.New("unsupported query")
+	errTimedOut := fmt.Errorf("query gave up: %w", context.DeadlineExceeded)
+	cases := []struct {
+		desc        string
+		onError     string
+		retries     uint
+		failures    int
+		err         error
+		wantCalls   int
+		wantError   bool
+		wantTimeout bool
+	}{
+		{desc: "success", onError: OnErrorSkip, wantCalls: 1},
+		{desc: "skip", onError: OnErrorSkip, failures: 1, err: errFailed, wantCalls: 1, wantError: true},
+		{desc: "retry until success", onError: OnErrorRetry, retries: 3, failures: 2, err: errFailed, wantCalls: 3},
+		{desc: "retry gives up", onError: OnErrorRetry, retries: 2, failures: 5, err: errFailed, wantCalls: 3, wantError: true},
+		{desc: "timeout is not retried", onError: OnErrorRetry, retries: 3, failures: 1, err: errTimedOut, wantCalls: 1, wantTimeout: true},
+		{desc: "timeout does not abort", onError: OnErrorAbort, failures: 1, err: errTimedOut, wantCalls: 1, wantTimeout: true},
+	}
+	for _, c := range cases {
+		b := &BenchmarkRunner{BenchmarkRunnerConfig: BenchmarkRunnerConfig{OnError: c.onError, QueryRetries: c.retries}}
+		p := &failingProcessor{failures: c.failures, err: c.err}
+		stats := b.processQuery(p, &testQuery{HumanLabel: []byte("foo")}, false)
+		if p.calls != c.wantCalls {
+			t.Errorf("%s: incorrect number of calls: got %d want %d", c.desc, p.calls, c.wantCalls)
+		}
+		if len(stats) != 1 {
+			t.Errorf("%s: incorrect number of stats: got %d want 1", c.desc, len(stats))
+			continue
+		}
+		s := stats[0]
+		if s.isError != c.wantError || s.isTimeout != c.wantTimeout || string(s.label) != "foo" {
+			t.Errorf("%s: incorrect stat: got error %v, timeout %v, label %s", c.desc, s.isError, s.isTimeout, s.label)
+		}
+	}
+}
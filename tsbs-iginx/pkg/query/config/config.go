@@ -19,6 +19,9 @@ type QueryGeneratorConfig struct {
 	QueryType            string `mapstructure:"query-type"`
 	InterleavedGroupID   uint   `mapstructure:"interleaved-generation-group-id"`
 	InterleavedNumGroups uint   `mapstructure:"interleaved-generation-groups"`
+	// MaxMetricCountPerHost is the max-metric-count the devops-generic data
+	// was generated with
+	MaxMetricCountPerHost uint64 `mapstructure:"max-metric-count"`
 
 	// TODO - I think this needs some rethinking, but a simple, elegant solution escapes me right now
 	TimescaleUseJSON       bool `mapstructure:"timescale-use-json"`
@@ -29,6 +32,9 @@ type QueryGeneratorConfig struct {
 
 	MongoUseNaive bool   `mapstructure:"mongo-use-native"`
 	DbName        string `mapstructure:"db-name"`
+
+	IginxPathTemplate string `mapstructure:"iginx-path-template"`
+	IginxTagMode      string `mapstructure:"iginx-tag-mode"`
 }
 
 // Validate checks that the values of the QueryGeneratorConfig are reasonable.
@@ -55,6 +61,7 @@ func (c *QueryGeneratorConfig) AddToFlagSet(fs *pflag.FlagSet) {
 		"Group (0-indexed) to perform round-robin serialization within. Use this to scale up data generation to multiple processes.")
 	fs.Uint("interleaved-generation-groups", 1,
 		"The number of round-robin serialization groups. Use this to scale up data generation to multiple processes.")
+	fs.Uint64("max-metric-count", 100, "Max number of metric fields per host the data was generated with. Used only in devops-generic use-case")
 
 	fs.Bool("clickhouse-use-tags", true, "ClickHouse only: Use separate tags table when querying")
 	fs.Bool("mongo-use-naive", true, "MongoDB only: Generate queries for the 'naive' data storage format for Mongo")
@@ -63,4 +70,6 @@ func (c *QueryGeneratorConfig) AddToFlagSet(fs *pflag.FlagSet) {
 	fs.Bool("timescale-use-time-bucket", true, "TimescaleDB only: Use time bucket. Set to false to test on native PostgreSQL")
 
 	fs.String("db-name", "benchmark", "Specify database name. Timestream requires it in order to generate the queries")
+	fs.String("iginx-path-template", "", "IginX only: Layout of the series paths the data was loaded with, e.g. '{measurement}.{hostname}.{field}'. Empty means the default layout")
+	fs.String("iginx-tag-mode", "path", "IginX only: Tag mode the data was loaded with, 'iginx' filters on IginX tags with WITH clauses")
 }
@@ -40,6 +40,9 @@ func InitQueryFactories(config *config.QueryGeneratorConfig) map[string]interfac
 		DBName: config.DbName,
 	}
 	factories[constants.FormatQuestDB] = &questdb.BaseGenerator{}
-	factories[constants.FormatIginx] = &iginx.BaseGenerator{}
+	factories[constants.FormatIginx] = &iginx.BaseGenerator{
+		PathTemplate: config.IginxPathTemplate,
+		TagMode:      config.IginxTagMode,
+	}
 	return factories
 }
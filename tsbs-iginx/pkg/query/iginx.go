@@ -1,6 +1,7 @@
 package query
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
 )
@@ -11,8 +12,75 @@ type Iginx struct {
 	HumanLabel       []byte
 	HumanDescription []byte
 
-	SqlQuery   []byte
-	id         uint64
+	SqlQuery []byte
+	// Statements are the further statements of the query's plan, run in
+	// order after SqlQuery. Their results are joined on path.
+	Statements [][]byte
+	// RestQuery is the KairosDB-style JSON form of the query for the IginX
+	// REST API, empty if it has none
+	RestQuery []byte
+	// Reduction, if set, is run by the runner on the result of the plan
+	Reduction *IginxReduction
+	id        uint64
+}
+
+// Kinds of IginxReduction, each named after the query it completes.
+const (
+	// IginxReduceBelow keeps the series whose last value is below Threshold
+	IginxReduceBelow = "below"
+	// IginxReduceHighLoad keeps the trucks whose last current_load is over
+	// Threshold times their load_capacity
+	IginxReduceHighLoad = "high-load"
+	// IginxReduceLongSessions keeps the trucks with more than MinCount
+	// windows whose value is over Threshold
+	IginxReduceLongSessions = "long-sessions"
+	// IginxReduceFuelConsumption averages the fuel_consumption and
+	// nominal_fuel_consumption of each fleet over the windows whose average
+	// velocity is over Threshold, weighted by their count of readings
+	IginxReduceFuelConsumption = "fuel-consumption"
+	// IginxReduceDailyDrivingDuration averages the hours per day each truck
+	// spends in windows whose velocity is over Threshold
+	IginxReduceDailyDrivingDuration = "daily-driving-duration"
+	// IginxReduceDailyDrivingSession averages the length of the sessions
+	// each truck drives, windows whose velocity is over Threshold, per day
+	IginxReduceDailyDrivingSession = "daily-driving-session"
+	// IginxReduceAvgLoad averages the load percentage of the trucks of each
+	// fleet, model and load capacity
+	IginxReduceAvgLoad = "avg-load"
+	// IginxReduceDailyActivity sums, per fleet, model and day, the readings
+	// of the windows whose average status is below Threshold
+	IginxReduceDailyActivity = "daily-activity"
+	// IginxReduceFilter keeps the values of each series group, e.g. host, at
+	// the times its Field is over Threshold
+	IginxReduceFilter = "filter"
+	// IginxReduceTopK takes the max of each row across all series, e.g. of
+	// the windows of all hosts, and keeps the Limit latest rows with a value,
+	// newest first
+	IginxReduceTopK = "top-k"
+	// IginxReduceBreakdownFrequency counts, per model, how often a truck
+	// breaks down, that is a window whose average status is below Threshold
+	// follows one whose average is not
+	IginxReduceBreakdownFrequency = "breakdown-frequency"
+)
+
+// IginxReduction is a client-side step completing a query IginX SQL cannot
+// express on its own, typically grouping series by tag or filtering on an
+// aggregate. With the default path template, series missing a tag cannot be
+// told apart and are left out; naming the tags in the template or loading
+// them as IginX tags keeps them.
+type IginxReduction struct {
+	Kind string
+	// PathTemplate and TagMode are the layout the data was loaded with,
+	// used to read the tags of each series back from the result
+	PathTemplate string
+	TagMode      string
+	// TagKeys are the tags of the measurement in point order, the first one
+	// identifying the series group, e.g. a host or a truck
+	TagKeys   []string
+	Field     string
+	Threshold float64
+	MinCount  int
+	Limit     int
 }
 
 // IginxPool is a sync.Pool of Iginx Query types
@@ -41,9 +109,20 @@ func (q *Iginx) SetID(n uint64) {
 	q.id = n
 }
 
+// Plan returns the statements of the query in the order they are run.
+func (q *Iginx) Plan() [][]byte {
+	plan := make([][]byte, 0, 1+len(q.Statements))
+	plan = append(plan, q.SqlQuery)
+	return append(plan, q.Statements...)
+}
+
 // String produces a debug-ready description of a Query.
 func (q *Iginx) String() string {
-	return fmt.Sprintf("HumanLabel: %s, HumanDescription: %s, Query: %s", q.HumanLabel, q.HumanDescription, q.SqlQuery)
+	s := fmt.Sprintf("HumanLabel: %s, HumanDescription: %s, Query: %s", q.HumanLabel, q.HumanDescription, bytes.Join(q.Plan(), []byte("; ")))
+	if q.Reduction != nil {
+		s += fmt.Sprintf(", Reduction: %s", q.Reduction.Kind)
+	}
+	return s
 }
 
 // HumanLabelName returns the human readable name of this Query
@@ -62,7 +141,9 @@ func (q *Iginx) Release() {
 	q.HumanDescription = q.HumanDescription[:0]
 	q.id = 0
 	q.SqlQuery = q.SqlQuery[:0]
+	q.Statements = q.Statements[:0]
+	q.RestQuery = q.RestQuery[:0]
+	q.Reduction = nil
 
 	IginxPool.Put(q)
 }
-
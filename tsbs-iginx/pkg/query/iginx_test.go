@@ -0,0 +1,115 @@
+package query
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestNewIginx(t *testing.T) {
+	check := func(q *Iginx) {
+		testValidNewQuery(t, q)
+		if got := len(q.SqlQuery); got != 0 {
+			t.Errorf("new query has non-0 sql query: got %d", got)
+		}
+		if got := len(q.Statements); got != 0 {
+			t.Errorf("new query has non-0 statements: got %d", got)
+		}
+		if q.Reduction != nil {
+			t.Errorf("new query has a reduction: got %s", q.Reduction.Kind)
+		}
+	}
+	q := NewIginx()
+	check(q)
+	q.HumanLabel = []byte("foo")
+	q.HumanDescription = []byte("bar")
+	q.SqlQuery = []byte("SELECT a FROM *")
+	q.Statements = [][]byte{[]byte("SELECT b FROM *")}
+	q.Reduction = &IginxReduction{Kind: IginxReduceTopK, Limit: 5}
+	q.SetID(1)
+	if got := len(q.Plan()); got != 2 {
+		t.Errorf("incorrect plan length: got %d", got)
+	}
+	want := "HumanLabel: foo, HumanDescription: bar, Query: SELECT a FROM *; SELECT b FROM *, Reduction: top-k"
+	if got := q.String(); got != want {
+		t.Errorf("incorrect string:\ngot  %s\nwant %s", got, want)
+	}
+	q.Release()
+
+	// Since we use a pool, check that the next one is reset
+	q = NewIginx()
+	check(q)
+	q.Release()
+}
+
+func TestIginxSetAndGetID(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		q := NewIginx()
+		testSetAndGetID(t, q)
+		q.Release()
+	}
+}
+
+// decodeReleased gob-decodes the queries in turn into the same Iginx, released
+// in between as the runner does before the pool hands it to the scanner again.
+// It returns what each query decoded to.
+func decodeReleased(t *testing.T, queries ...*Iginx) []string {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	for _, q := range queries {
+		if err := enc.Encode(q); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	dec := gob.NewDecoder(&buf)
+	q := NewIginx()
+	got := make([]string, 0, len(queries))
+	for range queries {
+		if err := dec.Decode(q); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, q.String()+", RestQuery: "+string(q.RestQuery))
+		q.Release()
+	}
+	return got
+}
+
+func TestIginxDecodeAfterRelease(t *testing.T) {
+	plan := &Iginx{
+		HumanLabel:       []byte("plan"),
+		HumanDescription: []byte("plan"),
+		SqlQuery:         []byte("SELECT a FROM *"),
+		Statements:       [][]byte{[]byte("SELECT b FROM *")},
+		Reduction:        &IginxReduction{Kind: IginxReduceFilter, Field: "usage_user", Threshold: 90},
+	}
+	plain := &Iginx{
+		HumanLabel:       []byte("plain"),
+		HumanDescription: []byte("plain"),
+		SqlQuery:         []byte("SELECT c FROM *"),
+	}
+	got := decodeReleased(t, plan, plain)
+	want := "HumanLabel: plain, HumanDescription: plain, Query: SELECT c FROM *, RestQuery: "
+	if got[1] != want {
+		t.Errorf("plain query decoded after a plan is wrong:\ngot  %s\nwant %s", got[1], want)
+	}
+}
+
+func TestIginxDecodeRestAfterRelease(t *testing.T) {
+	rest := &Iginx{
+		HumanLabel:       []byte("rest"),
+		HumanDescription: []byte("rest"),
+		SqlQuery:         []byte("SELECT a FROM *"),
+		RestQuery:        []byte(`{"start_absolute":0,"end_absolute":1,"metrics":[{"name":"a"}]}`),
+	}
+	sqlOnly := &Iginx{
+		HumanLabel:       []byte("sql only"),
+		HumanDescription: []byte("sql only"),
+		SqlQuery:         []byte("SELECT b FROM *"),
+	}
+	got := decodeReleased(t, rest, sqlOnly)
+	want := "HumanLabel: sql only, HumanDescription: sql only, Query: SELECT b FROM *, RestQuery: "
+	if got[1] != want {
+		t.Errorf("query without a REST form decoded after a REST one is wrong:\ngot  %s\nwant %s", got[1], want)
+	}
+}
@@ -0,0 +1,49 @@
+package query
+
+import "sync"
+
+// LatencyWindow collects the latencies of the queries completed since it was
+// last flushed, so that they can be reported over time windows alongside
+// other measurements, e.g. the write throughput of a concurrent load. It is
+// safe for concurrent use.
+type LatencyWindow struct {
+	mu    sync.Mutex
+	group *statGroup
+}
+
+// WindowStats sums up the queries of one window of a LatencyWindow.
+type WindowStats struct {
+	// Count is the number of queries that completed
+	Count    int64
+	Errors   int64
+	Timeouts int64
+	// Quantiles are the latency quantiles in milliseconds, keyed as in the
+	// results file: q0, q50, q95, q99, q999 and q100
+	Quantiles map[string]float64
+}
+
+// NewLatencyWindow returns an empty LatencyWindow.
+func NewLatencyWindow() *LatencyWindow {
+	return &LatencyWindow{group: newStatGroup(0)}
+}
+
+func (w *LatencyWindow) record(stat *Stat) {
+	w.mu.Lock()
+	w.group.record(stat)
+	w.mu.Unlock()
+}
+
+// Flush returns the stats of the current window and starts a new one.
+func (w *LatencyWindow) Flush() WindowStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, quantiles := generateQuantileMap(w.group.latencyHDRHistogram)
+	ws := WindowStats{
+		Count:     w.group.count,
+		Errors:    w.group.errors,
+		Timeouts:  w.group.timeouts,
+		Quantiles: quantiles,
+	}
+	w.group.reset()
+	return ws
+}
@@ -0,0 +1,25 @@
+package query
+
+import "testing"
+
+func TestLatencyWindowFlush(t *testing.T) {
+	w := NewLatencyWindow()
+	for _, v := range []float64{1, 2, 3, 4} {
+		w.record(GetStat().Init([]byte("q"), v))
+	}
+	w.record(GetErrorStat().Init([]byte("q"), 0))
+	w.record(GetTimeoutStat().Init([]byte("q"), 0))
+
+	ws := w.Flush()
+	if ws.Count != 4 || ws.Errors != 1 || ws.Timeouts != 1 {
+		t.Errorf("incorrect counts: got %d/%d/%d want 4/1/1", ws.Count, ws.Errors, ws.Timeouts)
+	}
+	if ws.Quantiles["q50"] != 2 || ws.Quantiles["q100"] != 4 {
+		t.Errorf("incorrect quantiles: got %v", ws.Quantiles)
+	}
+
+	ws = w.Flush()
+	if ws.Count != 0 || ws.Errors != 0 || ws.Timeouts != 0 || ws.Quantiles["q100"] != 0 {
+		t.Errorf("window not emptied by flush: got %+v", ws)
+	}
+}
@@ -25,12 +25,12 @@ type statProcessor interface {
 }
 
 type statProcessorArgs struct {
-	prewarmQueries   bool    // PrewarmQueries tells the StatProcessor whether we're running each query twice to prewarm the cache
-	limit            *uint64 // limit is the number of statistics to analyze before stopping
-	burnIn           uint64  // burnIn is the number of statistics to ignore before analyzing
-	printInterval    uint64  // printInterval is how often print intermediate stats (number of queries)
-	hdrLatenciesFile string  // hdrLatenciesFile is the filename to Write the High Dynamic Range (HDR) Histogram of Response Latencies to
-
+	prewarmQueries   bool           // PrewarmQueries tells the StatProcessor whether we're running each query twice to prewarm the cache
+	limit            *uint64        // limit is the number of statistics to analyze before stopping
+	burnIn           uint64         // burnIn is the number of statistics to ignore before analyzing
+	printInterval    uint64         // printInterval is how often print intermediate stats (number of queries)
+	hdrLatenciesFile string         // hdrLatenciesFile is the filename to Write the High Dynamic Range (HDR) Histogram of Response Latencies to
+	window           *LatencyWindow // window, if set, also gets the stats of all complete queries past the burn-in
 }
 
 // statProcessor is used to collect, analyze, and print query execution statistics.
@@ -112,17 +112,20 @@ func (sp *defaultStatProcessor) process(workers uint) {
 			sp.statMapping[string(stat.label)] = newStatGroup(*sp.args.limit)
 		}
 
-		sp.statMapping[string(stat.label)].push(stat.value)
+		sp.statMapping[string(stat.label)].record(stat)
 
 		if !stat.isPartial {
-			sp.statMapping[allQueriesLabel].push(stat.value)
+			sp.statMapping[allQueriesLabel].record(stat)
+			if sp.args.window != nil {
+				sp.args.window.record(stat)
+			}
 
 			// Only needed when differentiating between cold & warm
 			if sp.args.prewarmQueries {
 				if stat.isWarm {
-					sp.statMapping[labelWarmQueries].push(stat.value)
+					sp.statMapping[labelWarmQueries].record(stat)
 				} else {
-					sp.statMapping[labelColdQueries].push(stat.value)
+					sp.statMapping[labelColdQueries].record(stat)
 				}
 			}
 
@@ -238,6 +241,15 @@ func (sp *defaultStatProcessor) GetTotalsMap() map[string]interface{} {
 		quantiles[stripRegex(label)] = all
 	}
 	totals["overallQuantiles"] = quantiles
+	// failed and timed out queries, which have no latency
+	errorCounts := make(map[string]interface{})
+	timeoutCounts := make(map[string]interface{})
+	for label, statGroup := range sp.statMapping {
+		errorCounts[stripRegex(label)] = statGroup.errors
+		timeoutCounts[stripRegex(label)] = statGroup.timeouts
+	}
+	totals["overallErrors"] = errorCounts
+	totals["overallTimeouts"] = timeoutCounts
 	return totals
 }
 
@@ -20,6 +20,8 @@ type Stat struct {
 	value     float64
 	isWarm    bool
 	isPartial bool
+	isError   bool
+	isTimeout bool
 }
 
 var statPool = &sync.Pool{
@@ -43,6 +45,22 @@ func GetPartialStat() *Stat {
 	return s
 }
 
+// GetErrorStat returns a Stat for use from a pool that records a failed
+// query instead of a latency
+func GetErrorStat() *Stat {
+	s := GetStat()
+	s.isError = true
+	return s
+}
+
+// GetTimeoutStat returns a Stat for use from a pool that records a query
+// that timed out instead of a latency
+func GetTimeoutStat() *Stat {
+	s := GetStat()
+	s.isTimeout = true
+	return s
+}
+
 // Init safely initializes a Stat while minimizing heap allocations.
 func (s *Stat) Init(label []byte, value float64) *Stat {
 	s.label = s.label[:0] // clear
@@ -57,6 +75,8 @@ func (s *Stat) reset() *Stat {
 	s.value = 0.0
 	s.isWarm = false
 	s.isPartial = false
+	s.isError = false
+	s.isTimeout = false
 	return s
 }
 
@@ -65,6 +85,8 @@ type statGroup struct {
 	latencyHDRHistogram *hdrhistogram.Histogram
 	sum                 float64
 	count               int64
+	errors              int64
+	timeouts            int64
 }
 
 // newStatGroup returns a new StatGroup with an initial size
@@ -90,9 +112,31 @@ func (s *statGroup) push(n float64) {
 	s.count++
 }
 
+// record adds a Stat to the StatGroup, counting failed and timed out queries
+// apart from the latencies.
+func (s *statGroup) record(stat *Stat) {
+	switch {
+	case stat.isError:
+		s.errors++
+	case stat.isTimeout:
+		s.timeouts++
+	default:
+		s.push(stat.value)
+	}
+}
+
+// reset empties the StatGroup.
+func (s *statGroup) reset() {
+	s.latencyHDRHistogram.Reset()
+	s.sum = 0
+	s.count = 0
+	s.errors = 0
+	s.timeouts = 0
+}
+
 // string makes a simple description of a statGroup.
 func (s *statGroup) string() string {
-	return fmt.Sprintf("min: %8.2fms, med: %8.2fms, mean: %8.2fms, max: %7.2fms, stddev: %8.2fms, sum: %5.1fsec, count: %d",
+	str := fmt.Sprintf("min: %8.2fms, med: %8.2fms, mean: %8.2fms, max: %7.2fms, stddev: %8.2fms, sum: %5.1fsec, count: %d",
 		s.Min(),
 		s.Median(),
 		s.Mean(),
@@ -100,6 +144,10 @@ func (s *statGroup) string() string {
 		s.StdDev(),
 		s.sum/hdrScaleFactor,
 		s.count)
+	if s.errors > 0 || s.timeouts > 0 {
+		str += fmt.Sprintf(", errors: %d, timeouts: %d", s.errors, s.timeouts)
+	}
+	return str
 }
 
 func (s *statGroup) write(w io.Writer) error {
@@ -405,3 +405,20 @@ func TestWriteStatGroupMap(t *testing.T) {
 		}
 	}
 }
+
+func TestStatGroupRecord(t *testing.T) {
+	sg := newStatGroup(0)
+	sg.record(GetStat().Init([]byte("foo"), 5.0))
+	sg.record(GetErrorStat().Init([]byte("foo"), 0))
+	sg.record(GetTimeoutStat().Init([]byte("foo"), 0))
+	sg.record(GetTimeoutStat().Init([]byte("foo"), 0))
+	if sg.count != 1 || sg.errors != 1 || sg.timeouts != 2 {
+		t.Errorf("incorrect counts: got count %d, errors %d, timeouts %d want 1, 1, 2", sg.count, sg.errors, sg.timeouts)
+	}
+	if s := sg.string(); !strings.HasSuffix(s, ", errors: 1, timeouts: 2") {
+		t.Errorf("summary lacks error counts: %s", s)
+	}
+	if s := newStatGroup(0).string(); strings.Contains(s, "errors") {
+		t.Errorf("summary without failures mentions errors: %s", s)
+	}
+}
@@ -0,0 +1,95 @@
+package iginx
+
+import (
+	"bufio"
+	"bytes"
+	"sync"
+
+	"github.com/timescale/tsbs/internal/inputs"
+	"github.com/timescale/tsbs/load"
+	"github.com/timescale/tsbs/pkg/data/source"
+	"github.com/timescale/tsbs/pkg/targets"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+// NewBenchmark returns the targets.Benchmark loading IginX either from a file
+// of serialized points or straight from a data simulator.
+func NewBenchmark(iginxSpecificConfig *SpecificConfig, dataSourceConfig *source.DataSourceConfig) (targets.Benchmark, error) {
+	if err := iginxSpecificConfig.Validate(); err != nil {
+		return nil, err
+	}
+	if err := validateRetryPolicy(iginxSpecificConfig); err != nil {
+		return nil, err
+	}
+	template, err := pathtemplate.ParseWithTagMode(iginxSpecificConfig.PathTemplate, iginxSpecificConfig.TagMode)
+	if err != nil {
+		return nil, err
+	}
+	format, err := getDataFormat(iginxSpecificConfig.DataFormat, template)
+	if err != nil {
+		return nil, err
+	}
+
+	var ds targets.DataSource
+	if dataSourceConfig.Type == source.FileDataSourceType {
+		br := load.GetBufferedReader(dataSourceConfig.File.Location)
+		ds = &fileDataSource{scanner: bufio.NewScanner(br)}
+	} else {
+		dataGenerator := &inputs.DataGenerator{}
+		simulator, err := dataGenerator.CreateSimulator(dataSourceConfig.Simulator)
+		if err != nil {
+			return nil, err
+		}
+		ds = newSimulationDataSource(simulator, format.serializer)
+	}
+
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return bytes.NewBuffer(make([]byte, 0, 4*1024*1024))
+		},
+	}
+
+	var deadLetter *deadLetterFile
+	if iginxSpecificConfig.DeadLetterFile != "" {
+		deadLetter = &deadLetterFile{path: iginxSpecificConfig.DeadLetterFile}
+	}
+
+	return &benchmark{
+		conf:       iginxSpecificConfig,
+		deadLetter: deadLetter,
+		template:   template,
+		format:     format,
+		dataSource: ds,
+		bufPool:    bufPool,
+	}, nil
+}
+
+// benchmark implements the targets.Benchmark interface
+type benchmark struct {
+	conf       *SpecificConfig
+	template   *pathtemplate.Template
+	deadLetter *deadLetterFile
+	format     *dataFormat
+	dataSource targets.DataSource
+	bufPool    *sync.Pool
+}
+
+func (b *benchmark) GetDataSource() targets.DataSource {
+	return b.dataSource
+}
+
+func (b *benchmark) GetBatchFactory() targets.BatchFactory {
+	return &factory{format: b.format, bufPool: b.bufPool}
+}
+
+func (b *benchmark) GetPointIndexer(_ uint) targets.PointIndexer {
+	return &targets.ConstantIndexer{}
+}
+
+func (b *benchmark) GetProcessor() targets.Processor {
+	return &processor{conf: b.conf, format: b.format, bufPool: b.bufPool, deadLetter: b.deadLetter}
+}
+
+func (b *benchmark) GetDBCreator() targets.DBCreator {
+	return &dbCreator{conf: b.conf, template: b.template, dataSource: b.dataSource}
+}
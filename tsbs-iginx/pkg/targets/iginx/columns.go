@@ -0,0 +1,217 @@
+package iginx
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/thulab/iginx-client-go/rpc"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+// columnBuilder collects the values of a batch and lays them out the way the
+// IginX insert API expects them: a list of distinct timestamps, a list of
+// distinct series and a sparse matrix holding, for every series, one value
+// per timestamp. Missing values are left nil, the client marks them in the
+// bitmap sent along with each column.
+//
+// A series is a path optionally followed by its IginX tags, written the way
+// pathtemplate.Template.AppendTags does: path{key1=value1,key2=value2}.
+type columnBuilder struct {
+	pathIndex map[string]int
+	timeIndex map[int64]int
+
+	series     []string
+	paths      []string
+	tags       []map[string]string
+	hasTags    bool
+	types      []rpc.DataType
+	timestamps []int64
+	cells      []cell
+}
+
+// cell is a single value of the batch, addressed by the indexes its path and
+// timestamp got when they were first seen.
+type cell struct {
+	path  int
+	time  int
+	value interface{}
+}
+
+func newColumnBuilder() *columnBuilder {
+	return &columnBuilder{
+		pathIndex: make(map[string]int),
+		timeIndex: make(map[int64]int),
+	}
+}
+
+// Append adds the value of series at timestamp to the batch. The data type of
+// a series is the one of the first value seen for it, except that a series
+// mixing LONG and DOUBLE values is sent as DOUBLE. Any other mix of types is
+// an error.
+func (c *columnBuilder) Append(series string, timestamp int64, value interface{}, dataType rpc.DataType) error {
+	p, ok := c.pathIndex[series]
+	if !ok {
+		var err error
+		if p, err = c.addSeries(series, dataType); err != nil {
+			return err
+		}
+	} else if err := c.mergeType(p, dataType); err != nil {
+		return err
+	}
+	c.appendCell(p, timestamp, value)
+	return nil
+}
+
+// AppendBytes is Append for a series that is still part of a larger buffer.
+// The series is only copied the first time it is seen.
+func (c *columnBuilder) AppendBytes(series []byte, timestamp int64, value interface{}, dataType rpc.DataType) error {
+	// the compiler does not allocate a string for map lookups like this one
+	p, ok := c.pathIndex[string(series)]
+	if !ok {
+		var err error
+		if p, err = c.addSeries(string(series), dataType); err != nil {
+			return err
+		}
+	} else if err := c.mergeType(p, dataType); err != nil {
+		return err
+	}
+	c.appendCell(p, timestamp, value)
+	return nil
+}
+
+func (c *columnBuilder) mergeType(p int, dataType rpc.DataType) error {
+	switch current := c.types[p]; {
+	case current == dataType:
+	case current == rpc.DataType_LONG && dataType == rpc.DataType_DOUBLE:
+		c.types[p] = rpc.DataType_DOUBLE
+	case current == rpc.DataType_DOUBLE && dataType == rpc.DataType_LONG:
+	default:
+		return fmt.Errorf("series %s has values of both type %v and %v", c.series[p], current, dataType)
+	}
+	return nil
+}
+
+func (c *columnBuilder) addSeries(series string, dataType rpc.DataType) (int, error) {
+	path, tags, err := pathtemplate.SplitSeries(series)
+	if err != nil {
+		return 0, err
+	}
+	p := len(c.paths)
+	c.pathIndex[series] = p
+	c.series = append(c.series, series)
+	c.paths = append(c.paths, path)
+	c.tags = append(c.tags, tags)
+	c.hasTags = c.hasTags || tags != nil
+	c.types = append(c.types, dataType)
+	return p, nil
+}
+
+func (c *columnBuilder) appendCell(p int, timestamp int64, value interface{}) {
+	t, ok := c.timeIndex[timestamp]
+	if !ok {
+		t = len(c.timestamps)
+		c.timeIndex[timestamp] = t
+		c.timestamps = append(c.timestamps, timestamp)
+	}
+	c.cells = append(c.cells, cell{path: p, time: t, value: value})
+}
+
+// Len returns the number of values appended so far.
+func (c *columnBuilder) Len() int {
+	return len(c.cells)
+}
+
+// columns is a batch in column-wise layout: values[i][j] is the value of
+// paths[i] with tags[i] at timestamps[j], or nil if there is none. tags is
+// nil if no series of the batch has IginX tags.
+type columns struct {
+	paths      []string
+	tags       []map[string]string
+	types      []rpc.DataType
+	timestamps []int64
+	values     [][]interface{}
+}
+
+// Build returns the batch with both paths and timestamps sorted. The IginX
+// client reorders unsorted input itself, but gets the permutation wrong, so
+// it must only ever see sorted input. Series sharing a path are ordered by
+// their tags. If a series has several values for the same timestamp the last
+// one appended wins.
+func (c *columnBuilder) Build() *columns {
+	pathOrder := sortedOrder(len(c.paths), func(i, j int) bool {
+		if c.paths[i] != c.paths[j] {
+			return c.paths[i] < c.paths[j]
+		}
+		return c.series[i] < c.series[j]
+	})
+	timeOrder := sortedOrder(len(c.timestamps), func(i, j int) bool { return c.timestamps[i] < c.timestamps[j] })
+
+	cols := &columns{
+		paths:      make([]string, len(c.paths)),
+		types:      make([]rpc.DataType, len(c.paths)),
+		timestamps: make([]int64, len(c.timestamps)),
+		values:     make([][]interface{}, len(c.paths)),
+	}
+	if c.hasTags {
+		cols.tags = make([]map[string]string, len(c.paths))
+	}
+	for newPos, oldPos := range pathOrder {
+		cols.paths[newPos] = c.paths[oldPos]
+		if c.hasTags {
+			// the insert API wants a map for every path, even an empty one
+			cols.tags[newPos] = c.tags[oldPos]
+			if cols.tags[newPos] == nil {
+				cols.tags[newPos] = map[string]string{}
+			}
+		}
+		cols.types[newPos] = c.types[oldPos]
+		cols.values[newPos] = make([]interface{}, len(c.timestamps))
+	}
+	for newPos, oldPos := range timeOrder {
+		cols.timestamps[newPos] = c.timestamps[oldPos]
+	}
+
+	// map the indexes assigned in Append to the sorted positions
+	pathPos := inverse(pathOrder)
+	timePos := inverse(timeOrder)
+	for _, v := range c.cells {
+		value := v.value
+		// integers of a path widened to DOUBLE by mergeType
+		if i, ok := value.(int64); ok && c.types[v.path] == rpc.DataType_DOUBLE {
+			value = float64(i)
+		}
+		cols.values[pathPos[v.path]][timePos[v.time]] = value
+	}
+	return cols
+}
+
+// rows returns the values of the batch in row-wise layout: rows()[j][i] is
+// the value of paths[i] at timestamps[j], or nil if there is none.
+func (c *columns) rows() [][]interface{} {
+	rows := make([][]interface{}, len(c.timestamps))
+	for j := range rows {
+		rows[j] = make([]interface{}, len(c.paths))
+		for i := range c.paths {
+			rows[j][i] = c.values[i][j]
+		}
+	}
+	return rows
+}
+
+// sortedOrder returns the indexes 0..n-1 ordered by less.
+func sortedOrder(n int, less func(i, j int) bool) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return less(order[i], order[j]) })
+	return order
+}
+
+func inverse(order []int) []int {
+	inv := make([]int, len(order))
+	for newPos, oldPos := range order {
+		inv[oldPos] = newPos
+	}
+	return inv
+}
@@ -0,0 +1,88 @@
+package iginx
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thulab/iginx-client-go/rpc"
+)
+
+func TestColumnBuilder(t *testing.T) {
+	c := newColumnBuilder()
+	c.Append("cpu.host_1.usage_user", 20, 1.0, rpc.DataType_DOUBLE)
+	c.Append("cpu.host_0.usage_user", 10, 2.0, rpc.DataType_DOUBLE)
+	c.Append("cpu.host_0.usage_user", 20, 3.0, rpc.DataType_DOUBLE)
+	c.Append("cpu.host_0.usage_system", 10, int64(4), rpc.DataType_LONG)
+	// duplicate value for the same path and timestamp, last one wins
+	c.Append("cpu.host_0.usage_user", 10, 5.0, rpc.DataType_DOUBLE)
+	if c.Len() != 5 {
+		t.Errorf("incorrect number of values: got %d want 5", c.Len())
+	}
+
+	got := c.Build()
+	want := &columns{
+		paths:      []string{"cpu.host_0.usage_system", "cpu.host_0.usage_user", "cpu.host_1.usage_user"},
+		types:      []rpc.DataType{rpc.DataType_LONG, rpc.DataType_DOUBLE, rpc.DataType_DOUBLE},
+		timestamps: []int64{10, 20},
+		values: [][]interface{}{
+			{int64(4), nil},
+			{5.0, 3.0},
+			{nil, 1.0},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect columns:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestColumnBuilderEmpty(t *testing.T) {
+	got := newColumnBuilder().Build()
+	if len(got.paths) != 0 || len(got.timestamps) != 0 || len(got.values) != 0 {
+		t.Errorf("expected empty columns, got %+v", got)
+	}
+}
+
+func TestColumnBuilderTags(t *testing.T) {
+	c := newColumnBuilder()
+	c.Append("cpu.usage_user{hostname=host_1}", 10, 1.0, rpc.DataType_DOUBLE)
+	c.Append("cpu.usage_user{hostname=host_0}", 10, 2.0, rpc.DataType_DOUBLE)
+	c.Append("cpu.usage_system", 10, 3.0, rpc.DataType_DOUBLE)
+	if err := c.Append("cpu.usage_idle{hostname", 10, 4.0, rpc.DataType_DOUBLE); err == nil {
+		t.Errorf("expected error for malformed tags")
+	}
+
+	got := c.Build()
+	want := &columns{
+		paths: []string{"cpu.usage_system", "cpu.usage_user", "cpu.usage_user"},
+		tags: []map[string]string{
+			{},
+			{"hostname": "host_0"},
+			{"hostname": "host_1"},
+		},
+		types:      []rpc.DataType{rpc.DataType_DOUBLE, rpc.DataType_DOUBLE, rpc.DataType_DOUBLE},
+		timestamps: []int64{10},
+		values:     [][]interface{}{{3.0}, {2.0}, {1.0}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect columns:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestColumnsRows(t *testing.T) {
+	cols := &columns{
+		paths:      []string{"cpu.host_0.usage_system", "cpu.host_0.usage_user"},
+		timestamps: []int64{10, 20, 30},
+		values: [][]interface{}{
+			{int64(4), nil, int64(6)},
+			{5.0, 3.0, nil},
+		},
+	}
+	want := [][]interface{}{
+		{int64(4), 5.0},
+		{nil, 3.0},
+		{int64(6), nil},
+	}
+	if got := cols.rows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect rows:\ngot  %v\nwant %v", got, want)
+	}
+}
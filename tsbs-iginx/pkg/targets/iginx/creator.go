@@ -0,0 +1,247 @@
+package iginx
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/thulab/iginx-client-go/client"
+	"github.com/timescale/tsbs/pkg/targets"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+// Ways of removing the data of a previous run.
+const (
+	// ClearModeDelete deletes the time series of the benchmark measurements
+	// only, leaving any other data in the cluster alone
+	ClearModeDelete = "delete"
+	// ClearModeClearData runs CLEAR DATA, wiping the whole cluster
+	ClearModeClearData = "clear-data"
+)
+
+var clearModes = []string{ClearModeDelete, ClearModeClearData}
+
+// benchmarkMeasurements are the measurements of all the TSBS use cases. They
+// are used to find the series of a previous run when the data source cannot
+// tell which measurements it holds, as is the case for files.
+var benchmarkMeasurements = []string{
+	"cpu", "disk", "diskio", "kernel", "mem", "net", "nginx", "postgresl", "redis",
+	"generic_metrics", "readings", "diagnostics",
+}
+
+// adminSession is the part of client.Session the dbCreator uses.
+type adminSession interface {
+	ListTimeSeries() ([]client.TimeSeries, error)
+	BatchDeleteTimeSeries(paths []string) error
+	ExecuteSQL(sql string) (*client.SQLDataSet, error)
+	AddStorageEngine(ip, port, engineType string, extra map[string]string) error
+}
+
+// dbCreator sets up IginX for a benchmark. IginX has no databases, so the
+// database of a benchmark is the set of series its measurements are loaded
+// into, found through the path template: it exists if any of these series
+// does, and removing it deletes them.
+type dbCreator struct {
+	conf       *SpecificConfig
+	template   *pathtemplate.Template
+	dataSource targets.DataSource
+	sessions   *SessionGroup
+	session    adminSession
+	// found are the paths of a previous run DBExists listed, so that
+	// RemoveOldDB does not list the series of the whole cluster again
+	found []string
+}
+
+func (d *dbCreator) Init() {
+	sessions, err := d.conf.NewSessionGroup(0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	session, err := sessions.Session()
+	if err != nil {
+		log.Fatal(err)
+	}
+	d.sessions = sessions
+	d.session = session
+}
+
+func (d *dbCreator) Close() {
+	if d.sessions != nil {
+		d.sessions.Close()
+	}
+}
+
+// patterns returns the path prefixes under which the series of each
+// measurement of the benchmark are stored.
+func (d *dbCreator) patterns() []string {
+	measurements := benchmarkMeasurements
+	if d.dataSource != nil {
+		if headers := d.dataSource.Headers(); headers != nil && len(headers.FieldKeys) > 0 {
+			measurements = make([]string, 0, len(headers.FieldKeys))
+			for m := range headers.FieldKeys {
+				measurements = append(measurements, m)
+			}
+			sort.Strings(measurements)
+		}
+	}
+	patterns := make([]string, 0, len(measurements))
+	for _, m := range measurements {
+		patterns = append(patterns, d.template.Pattern(m, nil))
+	}
+	return patterns
+}
+
+// existingPaths lists the paths of the series left by a previous run.
+func (d *dbCreator) existingPaths() ([]string, error) {
+	series, err := d.session.ListTimeSeries()
+	if err != nil {
+		return nil, err
+	}
+	patterns := d.patterns()
+	seen := map[string]bool{}
+	var paths []string
+	for _, s := range series {
+		path, _, err := pathtemplate.SplitSeries(s.GetPath())
+		if err != nil || seen[path] {
+			continue
+		}
+		for _, p := range patterns {
+			if matchPath(p, path) {
+				seen[path] = true
+				paths = append(paths, path)
+				break
+			}
+		}
+	}
+	return paths, nil
+}
+
+// DBExists reports whether any series of the benchmark measurements exists.
+// The database name is not part of the series paths and is ignored.
+func (d *dbCreator) DBExists(_ string) bool {
+	paths, err := d.existingPaths()
+	if err != nil {
+		log.Fatalf("could not list IginX time series: %v", err)
+	}
+	d.found = paths
+	return len(paths) > 0
+}
+
+// RemoveOldDB deletes the series of the benchmark measurements, those found by
+// the last DBExists if any, or, with ClearModeClearData, all the data in the
+// cluster.
+func (d *dbCreator) RemoveOldDB(_ string) error {
+	if d.conf.ClearMode == ClearModeClearData {
+		_, err := d.session.ExecuteSQL("CLEAR DATA")
+		return err
+	}
+	paths := d.found
+	if paths == nil {
+		var err error
+		if paths, err = d.existingPaths(); err != nil {
+			return err
+		}
+	}
+	d.found = nil
+	if len(paths) == 0 {
+		return nil
+	}
+	return d.session.BatchDeleteTimeSeries(paths)
+}
+
+// CreateDB registers the configured storage engines and runs the configured
+// statements, e.g. to set up the initial fragments. Series are created by the
+// first insert, so there is nothing else to do.
+func (d *dbCreator) CreateDB(_ string) error {
+	engines, err := ParseStorageEngines(d.conf.StorageEngines)
+	if err != nil {
+		return err
+	}
+	for _, e := range engines {
+		if err := d.session.AddStorageEngine(e.IP, e.Port, e.Type, e.Extra); err != nil {
+			return fmt.Errorf("could not add storage engine %s: %v", e, err)
+		}
+	}
+	for _, stmt := range splitStatements(d.conf.CreateStatements) {
+		if _, err := d.session.ExecuteSQL(stmt); err != nil {
+			return fmt.Errorf("could not execute '%s': %v", stmt, err)
+		}
+	}
+	return nil
+}
+
+// matchPath reports whether path lies under pattern, where a '*' segment
+// matches one or more levels, the way IginX matches paths.
+func matchPath(pattern, path string) bool {
+	return matchSegments(strings.Split(pattern, "."), strings.Split(path, "."))
+}
+
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		// a prefix matches the series below it, not itself
+		return len(path) > 0
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if pattern[0] != "*" {
+		return pattern[0] == path[0] && matchSegments(pattern[1:], path[1:])
+	}
+	for i := 1; i <= len(path); i++ {
+		if matchSegments(pattern[1:], path[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// StorageEngine is a storage engine to register with IginX.
+type StorageEngine struct {
+	IP    string
+	Port  string
+	Type  string
+	Extra map[string]string
+}
+
+func (e StorageEngine) String() string {
+	return fmt.Sprintf("%s:%s (%s)", e.IP, e.Port, e.Type)
+}
+
+// ParseStorageEngines parses a comma-separated list of storage engines written
+// the way the IginX configuration does: ip#port#type[#key=value...], e.g.
+// '127.0.0.1#6667#iotdb12#username=root#password=root'.
+func ParseStorageEngines(s string) ([]StorageEngine, error) {
+	var engines []StorageEngine
+	for _, def := range strings.Split(s, ",") {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		parts := strings.Split(def, "#")
+		if len(parts) < 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid storage engine '%s', expected ip#port#type[#key=value...]", def)
+		}
+		e := StorageEngine{IP: parts[0], Port: parts[1], Type: parts[2], Extra: map[string]string{}}
+		for _, kv := range parts[3:] {
+			i := strings.IndexByte(kv, '=')
+			if i <= 0 {
+				return nil, fmt.Errorf("invalid parameter '%s' of storage engine '%s'", kv, def)
+			}
+			e.Extra[kv[:i]] = kv[i+1:]
+		}
+		engines = append(engines, e)
+	}
+	return engines, nil
+}
+
+// splitStatements splits semicolon-separated statements, dropping empty ones.
+func splitStatements(s string) []string {
+	var stmts []string
+	for _, stmt := range strings.Split(s, ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
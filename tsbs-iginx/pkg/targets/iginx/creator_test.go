@@ -0,0 +1,153 @@
+package iginx
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thulab/iginx-client-go/client"
+	"github.com/thulab/iginx-client-go/rpc"
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+type fakeAdminSession struct {
+	series  []string
+	deleted []string
+	sql     []string
+	engines []StorageEngine
+	lists   int
+}
+
+func (f *fakeAdminSession) ListTimeSeries() ([]client.TimeSeries, error) {
+	f.lists++
+	var ts []client.TimeSeries
+	for _, s := range f.series {
+		ts = append(ts, client.NewTimeSeries(s, rpc.DataType_DOUBLE))
+	}
+	return ts, nil
+}
+
+func (f *fakeAdminSession) BatchDeleteTimeSeries(paths []string) error {
+	f.deleted = append(f.deleted, paths...)
+	return nil
+}
+
+func (f *fakeAdminSession) ExecuteSQL(sql string) (*client.SQLDataSet, error) {
+	f.sql = append(f.sql, sql)
+	return nil, nil
+}
+
+func (f *fakeAdminSession) AddStorageEngine(ip, port, engineType string, extra map[string]string) error {
+	f.engines = append(f.engines, StorageEngine{IP: ip, Port: port, Type: engineType, Extra: extra})
+	return nil
+}
+
+type headersDataSource struct {
+	headers *common.GeneratedDataHeaders
+}
+
+func (d *headersDataSource) NextItem() data.LoadedPoint { return data.LoadedPoint{} }
+
+func (d *headersDataSource) Headers() *common.GeneratedDataHeaders { return d.headers }
+
+func TestMatchPath(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{pattern: "cpu", path: "cpu.host_0.usage_user", want: true},
+		{pattern: "cpu", path: "cpu", want: false},
+		{pattern: "cpu", path: "cpus.host_0.usage_user", want: false},
+		{pattern: "cpu", path: "mem.host_0.used", want: false},
+		{pattern: "*.cpu", path: "eu_west_1.host_0.cpu.usage_user", want: true},
+		{pattern: "*.cpu", path: "cpu.usage_user", want: false},
+		{pattern: "tsbs.*.cpu", path: "tsbs.host_0.cpu.usage_user", want: true},
+		{pattern: "tsbs.*.cpu", path: "other.host_0.cpu.usage_user", want: false},
+	}
+	for _, c := range cases {
+		if got := matchPath(c.pattern, c.path); got != c.want {
+			t.Errorf("matchPath(%s, %s): got %v want %v", c.pattern, c.path, got, c.want)
+		}
+	}
+}
+
+func TestDBCreatorRemoveOldDB(t *testing.T) {
+	session := &fakeAdminSession{series: []string{
+		"cpu.host_0.usage_user",
+		"cpu.host_0.usage_user{team=SF}",
+		"cpu.host_1.usage_user",
+		"mem.host_0.used",
+		"other.value",
+	}}
+	d := &dbCreator{
+		conf:     &SpecificConfig{ClearMode: ClearModeDelete},
+		template: pathtemplate.MustParse(pathtemplate.Default),
+		dataSource: &headersDataSource{headers: &common.GeneratedDataHeaders{
+			FieldKeys: map[string][]string{"cpu": {"usage_user"}},
+		}},
+		session: session,
+	}
+	if !d.DBExists("benchmark") {
+		t.Errorf("expected the cpu series to be found")
+	}
+	if err := d.RemoveOldDB("benchmark"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"cpu.host_0.usage_user", "cpu.host_1.usage_user"}
+	if !reflect.DeepEqual(session.deleted, want) {
+		t.Errorf("incorrect deleted series: got %v want %v", session.deleted, want)
+	}
+	if session.lists != 1 {
+		t.Errorf("RemoveOldDB should delete the series DBExists found, got %d listings", session.lists)
+	}
+
+	d.dataSource = &headersDataSource{}
+	session.series = []string{"other.value"}
+	if d.DBExists("benchmark") {
+		t.Errorf("expected no benchmark series to be found")
+	}
+
+	d.conf.ClearMode = ClearModeClearData
+	if err := d.RemoveOldDB("benchmark"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(session.sql, []string{"CLEAR DATA"}) {
+		t.Errorf("incorrect statements: got %v", session.sql)
+	}
+}
+
+func TestDBCreatorCreateDB(t *testing.T) {
+	session := &fakeAdminSession{}
+	d := &dbCreator{
+		conf: &SpecificConfig{
+			StorageEngines:   "127.0.0.1#6667#iotdb12#username=root#password=root, 10.0.0.2#5432#postgresql",
+			CreateStatements: "ADD STORAGEENGINE (\"10.0.0.3\", 6667, \"iotdb12\", \"\"); ; SHOW CLUSTER INFO;",
+		},
+		template: pathtemplate.MustParse(pathtemplate.Default),
+		session:  session,
+	}
+	if err := d.CreateDB("benchmark"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantEngines := []StorageEngine{
+		{IP: "127.0.0.1", Port: "6667", Type: "iotdb12", Extra: map[string]string{"username": "root", "password": "root"}},
+		{IP: "10.0.0.2", Port: "5432", Type: "postgresql", Extra: map[string]string{}},
+	}
+	if !reflect.DeepEqual(session.engines, wantEngines) {
+		t.Errorf("incorrect storage engines: got %v want %v", session.engines, wantEngines)
+	}
+	wantSQL := []string{"ADD STORAGEENGINE (\"10.0.0.3\", 6667, \"iotdb12\", \"\")", "SHOW CLUSTER INFO"}
+	if !reflect.DeepEqual(session.sql, wantSQL) {
+		t.Errorf("incorrect statements: got %v want %v", session.sql, wantSQL)
+	}
+}
+
+func TestParseStorageEnginesErrors(t *testing.T) {
+	for _, s := range []string{"127.0.0.1#6667", "127.0.0.1##iotdb12", "127.0.0.1#6667#iotdb12#username"} {
+		if _, err := ParseStorageEngines(s); err == nil {
+			t.Errorf("expected error for '%s'", s)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package iginx
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/timescale/tsbs/pkg/data/serialize"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+// Formats of the data files the loader can read.
+const (
+	// DataFormatInflux is Influx line protocol, as written by the iginx format
+	DataFormatInflux = "influx"
+	// DataFormatNative is the typed, tab separated format written by the
+	// iginx-native format, see NativeSerializer
+	DataFormatNative = "native"
+)
+
+var dataFormats = []string{DataFormatInflux, DataFormatNative}
+
+// dataFormat ties together how points of a format are serialized, how the
+// metrics of a line are counted when batching and how a line is decoded into
+// the columns of an insert.
+type dataFormat struct {
+	serializer   serialize.PointSerializer
+	countMetrics func(line []byte) (uint64, error)
+	decode       func(cols *columnBuilder, line []byte) error
+}
+
+// getDataFormat returns the named data format. The path template decides the
+// paths influx lines are loaded to and the paths simulated native points are
+// serialized with; native files already carry their paths.
+func getDataFormat(name string, template *pathtemplate.Template) (*dataFormat, error) {
+	switch name {
+	case DataFormatInflux:
+		return &dataFormat{
+			serializer:   &Serializer{},
+			countMetrics: countInfluxMetrics,
+			decode: func(cols *columnBuilder, line []byte) error {
+				return appendInfluxLine(cols, template, string(line))
+			},
+		}, nil
+	case DataFormatNative:
+		return &dataFormat{
+			serializer:   &NativeSerializer{Template: template},
+			countMetrics: countNativeMetrics,
+			decode:       appendNativeLine,
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid data format '%s', supported: %s", name, strings.Join(dataFormats, ", "))
+}
+
+// countInfluxMetrics counts the fields of an influx line. Each line is format
+// "csv-tags csv-fields timestamp", so we split by space and then on the
+// middle element, we split by comma to count number of fields added.
+func countInfluxMetrics(line []byte) (uint64, error) {
+	args, err := splitInfluxLine(string(line))
+	if err != nil {
+		return 0, err
+	}
+	return uint64(len(splitInfluxFields(args[1]))), nil
+}
+
+// countNativeMetrics counts the path/value pairs following the timestamp of
+// a native line.
+func countNativeMetrics(line []byte) (uint64, error) {
+	seps := bytes.Count(line, []byte{nativeSep})
+	if seps == 0 || seps%2 != 0 {
+		return 0, fmt.Errorf(errNativeFieldsFmt, seps+1)
+	}
+	return uint64(seps / 2), nil
+}
@@ -0,0 +1,171 @@
+package iginx
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/blagojts/viper"
+	"github.com/thulab/iginx-client-go/client"
+)
+
+// Ways of assigning IginX sessions to workers.
+const (
+	// SessionAssignmentPerWorker pins each worker to a single host,
+	// worker i talks to host i % len(hosts)
+	SessionAssignmentPerWorker = "per-worker"
+	// SessionAssignmentRoundRobin opens a session to every host in each
+	// worker and rotates through them on every request
+	SessionAssignmentRoundRobin = "round-robin"
+	// SessionAssignmentRandom opens a session to every host in each worker
+	// and picks one of them at random for every request
+	SessionAssignmentRandom = "random"
+)
+
+var sessionAssignments = []string{
+	SessionAssignmentPerWorker,
+	SessionAssignmentRoundRobin,
+	SessionAssignmentRandom,
+}
+
+// SpecificConfig holds the IginX specific settings, the connection settings
+// are shared by the loader and the query runner.
+type SpecificConfig struct {
+	Hosts    string `yaml:"hosts" mapstructure:"hosts"`
+	Port     string `yaml:"port" mapstructure:"port"`
+	User     string `yaml:"user" mapstructure:"user"`
+	Password string `yaml:"password" mapstructure:"password"`
+	// SessionAssignment is one of the SessionAssignment policies, empty for
+	// SessionAssignmentPerWorker
+	SessionAssignment string `yaml:"session-assignment" mapstructure:"session-assignment"`
+	// ReconnectInterval is how long a node a session dropped from is left
+	// alone before it is tried again, zero for never
+	ReconnectInterval time.Duration `yaml:"reconnect-interval" mapstructure:"reconnect-interval"`
+	// URL is the IginX REST end point
+	URL string `yaml:"url" mapstructure:"url"`
+	// DataFormat is only used by the loader, see DataFormatInflux and DataFormatNative
+	DataFormat string `yaml:"data-format" mapstructure:"data-format"`
+	// PathTemplate lays out the series paths, see pathtemplate.Template
+	PathTemplate string `yaml:"path-template" mapstructure:"path-template"`
+	// TagMode is pathtemplate.TagModePath or pathtemplate.TagModeIginx
+	TagMode string `yaml:"tag-mode" mapstructure:"tag-mode"`
+	// ClearMode is how the loader removes a previous run, see ClearModeDelete
+	// and ClearModeClearData
+	ClearMode string `yaml:"clear-mode" mapstructure:"clear-mode"`
+	// StorageEngines are registered by the loader before loading, see
+	// ParseStorageEngines
+	StorageEngines string `yaml:"storage-engines" mapstructure:"storage-engines"`
+	// CreateStatements are semicolon-separated statements the loader
+	// executes before loading
+	CreateStatements string `yaml:"create-statements" mapstructure:"create-statements"`
+	// MaxAttempts, RetryBackoff, MaxRetryBackoff and RetryJitter make up the
+	// policy for retrying failed inserts, see retryPolicy
+	MaxAttempts     int           `yaml:"max-attempts" mapstructure:"max-attempts"`
+	RetryBackoff    time.Duration `yaml:"retry-backoff" mapstructure:"retry-backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max-retry-backoff" mapstructure:"max-retry-backoff"`
+	RetryJitter     float64       `yaml:"retry-jitter" mapstructure:"retry-jitter"`
+	// DeadLetterFile receives the batches the loader gave up on
+	DeadLetterFile string `yaml:"dead-letter-file" mapstructure:"dead-letter-file"`
+	// InsertAPI is the session insert method the loader writes batches
+	// with, see InsertAPIColumn and the others
+	InsertAPI string `yaml:"insert-api" mapstructure:"insert-api"`
+	// WriteProtocol is how the loader writes batches, see
+	// WriteProtocolSession and WriteProtocolHTTP
+	WriteProtocol string `yaml:"write-protocol" mapstructure:"write-protocol"`
+	// ILPBindTo and ILPPath locate the line protocol end point the loader
+	// posts to with WriteProtocolHTTP, WriteGzip compresses the posts
+	ILPBindTo string `yaml:"ilp-bind-to" mapstructure:"ilp-bind-to"`
+	ILPPath   string `yaml:"ilp-path" mapstructure:"ilp-path"`
+	WriteGzip bool   `yaml:"write-gzip" mapstructure:"write-gzip"`
+}
+
+func parseSpecificConfig(v *viper.Viper) (*SpecificConfig, error) {
+	var conf SpecificConfig
+	if err := v.Unmarshal(&conf); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// Endpoint is the address of a single IginX node.
+type Endpoint struct {
+	Host string
+	Port string
+}
+
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.Host, e.Port)
+}
+
+// ParseEndpoints splits a comma-separated list of hosts into endpoints. Each
+// host may carry its own port ('host:port'), otherwise defaultPort is used.
+func ParseEndpoints(hosts, defaultPort string) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	for _, h := range strings.Split(hosts, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if !strings.Contains(h, ":") {
+			endpoints = append(endpoints, Endpoint{Host: h, Port: defaultPort})
+			continue
+		}
+		host, port, err := net.SplitHostPort(h)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IginX host '%s': %v", h, err)
+		}
+		endpoints = append(endpoints, Endpoint{Host: host, Port: port})
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no IginX hosts specified")
+	}
+	return endpoints, nil
+}
+
+// Endpoints returns the parsed list of IginX nodes to connect to.
+func (c *SpecificConfig) Endpoints() ([]Endpoint, error) {
+	return ParseEndpoints(c.Hosts, c.Port)
+}
+
+// Validate checks that the hosts and storage engines can be parsed and that
+// the session assignment, clear mode, insert API and write protocol are
+// supported ones.
+func (c *SpecificConfig) Validate() error {
+	if _, err := c.Endpoints(); err != nil {
+		return err
+	}
+	if _, err := ParseStorageEngines(c.StorageEngines); err != nil {
+		return err
+	}
+	if c.ClearMode != "" && c.ClearMode != ClearModeDelete && c.ClearMode != ClearModeClearData {
+		return fmt.Errorf("invalid clear mode '%s', supported: %s", c.ClearMode, strings.Join(clearModes, ", "))
+	}
+	if _, err := getInsertAPI(c.InsertAPI); err != nil {
+		return err
+	}
+	switch c.WriteProtocol {
+	case "", WriteProtocolSession:
+	case WriteProtocolHTTP:
+		if c.DataFormat != DataFormatInflux {
+			return fmt.Errorf("write protocol '%s' needs data format '%s'", WriteProtocolHTTP, DataFormatInflux)
+		}
+	default:
+		return fmt.Errorf("invalid write protocol '%s', supported: %s", c.WriteProtocol, strings.Join(writeProtocols, ", "))
+	}
+	if c.SessionAssignment == "" {
+		return nil
+	}
+	for _, a := range sessionAssignments {
+		if c.SessionAssignment == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid session assignment '%s', supported: %s",
+		c.SessionAssignment, strings.Join(sessionAssignments, ", "))
+}
+
+// NewSession returns a new, not yet opened, session to the given endpoint.
+func (c *SpecificConfig) NewSession(e Endpoint) *client.Session {
+	return client.NewSession(e.Host, e.Port, c.User, c.Password)
+}
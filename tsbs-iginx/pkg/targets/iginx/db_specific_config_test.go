@@ -0,0 +1,99 @@
+package iginx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	cases := []struct {
+		desc        string
+		hosts       string
+		want        []Endpoint
+		shouldError bool
+	}{
+		{
+			desc:  "single host uses default port",
+			hosts: "127.0.0.1",
+			want:  []Endpoint{{Host: "127.0.0.1", Port: "6888"}},
+		},
+		{
+			desc:  "multiple hosts with port override",
+			hosts: "10.0.0.1, 10.0.0.2:6889,",
+			want: []Endpoint{
+				{Host: "10.0.0.1", Port: "6888"},
+				{Host: "10.0.0.2", Port: "6889"},
+			},
+		},
+		{
+			desc:        "empty host list",
+			hosts:       " , ",
+			shouldError: true,
+		},
+		{
+			desc:        "malformed host",
+			hosts:       "10.0.0.1:6888:1",
+			shouldError: true,
+		},
+	}
+	for _, c := range cases {
+		got, err := ParseEndpoints(c.hosts, "6888")
+		if c.shouldError {
+			if err == nil {
+				t.Errorf("%s: expected error, got none", c.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: incorrect endpoints: got %v want %v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestSpecificConfigValidate(t *testing.T) {
+	c := &SpecificConfig{Hosts: "127.0.0.1", Port: "6888", SessionAssignment: SessionAssignmentRandom}
+	if err := c.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	c.SessionAssignment = "bogus"
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected error for unknown session assignment")
+	}
+	c.SessionAssignment = ""
+	if err := c.Validate(); err != nil {
+		t.Errorf("empty session assignment should mean the default, got %v", err)
+	}
+	c.SessionAssignment = SessionAssignmentPerWorker
+	c.Hosts = ""
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected error for empty host list")
+	}
+	c.Hosts = "127.0.0.1"
+	c.ClearMode = "bogus"
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected error for unknown clear mode")
+	}
+	c.ClearMode = ClearModeClearData
+	c.StorageEngines = "127.0.0.1#6667"
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected error for malformed storage engine")
+	}
+	c.StorageEngines = ""
+	c.WriteProtocol = "bogus"
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected error for unknown write protocol")
+	}
+	c.WriteProtocol = WriteProtocolHTTP
+	c.DataFormat = DataFormatNative
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected error for posting native data")
+	}
+	c.DataFormat = DataFormatInflux
+	if err := c.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package iginx
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Protocols the loader can write batches with.
+const (
+	// WriteProtocolSession decodes the batches and inserts them over IginX
+	// sessions
+	WriteProtocolSession = "session"
+	// WriteProtocolHTTP posts the Influx lines of the batches as they are to
+	// the line protocol end point at ilp-bind-to
+	WriteProtocolHTTP = "http"
+)
+
+var writeProtocols = []string{WriteProtocolSession, WriteProtocolHTTP}
+
+const (
+	httpClientName        = "tsbs_load_iginx"
+	headerContentEncoding = "Content-Encoding"
+	headerGzip            = "gzip"
+)
+
+// httpWriter posts batches of Influx lines to IginX. IginX maps the lines to
+// paths itself, so the path template and tag mode do not apply.
+type httpWriter struct {
+	client     fasthttp.Client
+	url        string
+	gzip       bool
+	compressed *bytes.Buffer
+}
+
+func newHTTPWriter(conf *SpecificConfig) *httpWriter {
+	w := &httpWriter{
+		client: fasthttp.Client{Name: httpClientName},
+		url:    ilpURL(conf.ILPBindTo, conf.ILPPath),
+		gzip:   conf.WriteGzip,
+	}
+	if w.gzip {
+		w.compressed = bytes.NewBuffer(make([]byte, 0, 1024*1024))
+	}
+	return w
+}
+
+// ilpURL returns the url of the line protocol end point, bindTo being its
+// ip:port or a url without path.
+func ilpURL(bindTo, path string) string {
+	if !strings.Contains(bindTo, "://") {
+		bindTo = "http://" + bindTo
+	}
+	return strings.TrimSuffix(bindTo, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// httpStatusError is the answer of the line protocol end point to a write
+// that did not succeed.
+type httpStatusError struct {
+	status int
+	body   string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("invalid write response (status %d): %s", e.status, e.body)
+}
+
+// write posts the lines, gzipped if so configured. Any 2xx status counts as
+// success, others give an httpStatusError.
+func (w *httpWriter) write(lines []byte) error {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("text/plain")
+	req.SetRequestURI(w.url)
+	if w.gzip {
+		w.compressed.Reset()
+		if _, err := fasthttp.WriteGzip(w.compressed, lines); err != nil {
+			return err
+		}
+		req.Header.Set(headerContentEncoding, headerGzip)
+		lines = w.compressed.Bytes()
+	}
+	req.SetBody(lines)
+
+	if err := w.client.Do(req, resp); err != nil {
+		return err
+	}
+	if sc := resp.StatusCode(); sc < 200 || sc >= 300 {
+		return &httpStatusError{status: sc, body: string(resp.Body())}
+	}
+	return nil
+}
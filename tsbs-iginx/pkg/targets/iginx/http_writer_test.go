@@ -0,0 +1,63 @@
+package iginx
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestILPURL(t *testing.T) {
+	cases := []struct {
+		bindTo, path, want string
+	}{
+		{"127.0.0.1:6666", "/write", "http://127.0.0.1:6666/write"},
+		{"127.0.0.1:6666", "write", "http://127.0.0.1:6666/write"},
+		{"https://iginx:6666/", "/api/write", "https://iginx:6666/api/write"},
+	}
+	for _, c := range cases {
+		if got := ilpURL(c.bindTo, c.path); got != c.want {
+			t.Errorf("%s %s: got %s want %s", c.bindTo, c.path, got, c.want)
+		}
+	}
+}
+
+func TestHTTPWriterWrite(t *testing.T) {
+	lines := "cpu,hostname=host_0 usage_user=1 0\ncpu,hostname=host_1 usage_user=2 0\n"
+	var gotPath, gotBody string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get(headerContentEncoding) == headerGzip {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		b, _ := ioutil.ReadAll(body)
+		gotPath, gotBody = r.URL.Path, string(b)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	for _, gz := range []bool{false, true} {
+		w := newHTTPWriter(&SpecificConfig{ILPBindTo: strings.TrimPrefix(server.URL, "http://"), ILPPath: "/write", WriteGzip: gz})
+		if err := w.write([]byte(lines)); err != nil {
+			t.Errorf("gzip %v: unexpected error: %v", gz, err)
+		}
+		if gotPath != "/write" || gotBody != lines {
+			t.Errorf("gzip %v: incorrect request: got %s %q", gz, gotPath, gotBody)
+		}
+	}
+
+	status = http.StatusBadRequest
+	w := newHTTPWriter(&SpecificConfig{ILPBindTo: server.URL, ILPPath: "/write"})
+	if err := w.write([]byte(lines)); err == nil {
+		t.Errorf("unexpected lack of error for status %d", status)
+	}
+}
@@ -1,34 +1,119 @@
 package iginx
 
 import (
+	"time"
+
 	"github.com/blagojts/viper"
 	"github.com/spf13/pflag"
+	"github.com/thulab/iginx-client-go/client"
 	"github.com/timescale/tsbs/pkg/data/serialize"
 	"github.com/timescale/tsbs/pkg/data/source"
 	"github.com/timescale/tsbs/pkg/targets"
 	"github.com/timescale/tsbs/pkg/targets/constants"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
 )
 
 func NewTarget() targets.ImplementedTarget {
-	return &influxTarget{}
+	return &influxTarget{dataFormat: DataFormatInflux}
+}
+
+// NewNativeTarget returns the target generating and loading data in the
+// native IginX format, see NativeSerializer.
+func NewNativeTarget() targets.ImplementedTarget {
+	return &influxTarget{dataFormat: DataFormatNative}
 }
 
 type influxTarget struct {
+	dataFormat string
 }
 
 func (t *influxTarget) TargetSpecificFlags(flagPrefix string, flagSet *pflag.FlagSet) {
-	flagSet.String(flagPrefix+"url", "http://localhost:6666/", "Iginx REST end point")
+	AddLoadFlags(flagPrefix, flagSet, t.dataFormat)
+	AddConnectionFlags(flagPrefix, flagSet)
+}
+
+// AddLoadFlags adds the flags needed to load data into Iginx, on top of the
+// connection ones, with dataFormat as the default data format.
+func AddLoadFlags(flagPrefix string, flagSet *pflag.FlagSet, dataFormat string) {
+	flagSet.String(flagPrefix+"insert-api", InsertAPIColumn,
+		"Session insert method to write batches with: 'column', 'row', 'non-aligned-column' or 'non-aligned-row'. "+
+			"Row methods take the values of a batch per timestamp, column methods per series")
+	flagSet.String(flagPrefix+"write-protocol", WriteProtocolSession,
+		"How to write batches: 'session' inserts them over IginX sessions, "+
+			"'http' posts them as Influx lines to the line protocol end point, which needs influx data and ignores path-template and tag-mode")
 	flagSet.String(flagPrefix+"ilp-bind-to", "127.0.0.1:6666", "Iginx influx line protocol TCP ip:port")
+	flagSet.String(flagPrefix+"ilp-path", "/write", "Path of the Iginx influx line protocol end point")
+	flagSet.Bool(flagPrefix+"write-gzip", false, "Whether to gzip the batches posted with the 'http' write protocol")
+	flagSet.String(flagPrefix+"data-format", dataFormat,
+		"Format of the data to load: 'influx' for Influx line protocol, 'native' for the typed IginX format")
+	flagSet.String(flagPrefix+"path-template", pathtemplate.Default,
+		"Layout of the series paths, e.g. '{measurement}.{hostname}.{field}'. {tags} stands for all the tag values not named otherwise. "+
+			"Applies to influx data and to simulated native data, native files keep the paths they were generated with")
+	flagSet.String(flagPrefix+"tag-mode", pathtemplate.TagModePath,
+		"Where tags go: 'path' places them in the path as laid out by path-template, "+
+			"'iginx' keeps the tags named in path-template in the path and sends the others as IginX tags")
+	flagSet.String(flagPrefix+"clear-mode", ClearModeDelete,
+		"How do-create-db removes a previous run: 'delete' deletes the series of the benchmark measurements, "+
+			"'clear-data' runs CLEAR DATA and wipes the whole cluster")
+	flagSet.String(flagPrefix+"storage-engines", "",
+		"Storage engines do-create-db registers before loading, comma-separated, each as ip#port#type[#key=value...]")
+	flagSet.String(flagPrefix+"create-statements", "",
+		"Statements do-create-db executes before loading, semicolon-separated, e.g. to set up the initial fragments")
+	flagSet.Int(flagPrefix+"max-attempts", 5, "Number of times to try inserting a batch that fails on the connection, a timeout or a 5xx response before giving up on it")
+	flagSet.Duration(flagPrefix+"retry-backoff", 100*time.Millisecond, "Time to wait before retrying a failed insert, doubled on each further retry")
+	flagSet.Duration(flagPrefix+"max-retry-backoff", 10*time.Second, "Longest time to wait before retrying a failed insert")
+	flagSet.Float64(flagPrefix+"retry-jitter", 0.2, "Fraction of the wait before a retry to randomly add or remove, between 0 and 1")
+	flagSet.String(flagPrefix+"dead-letter-file", "", "File to write the batches that could not be inserted to, in the input format, so that they can be loaded later")
+}
+
+// AddConnectionFlags adds the flags needed to open sessions to Iginx, shared
+// by the loader and the query runner.
+func AddConnectionFlags(flagPrefix string, flagSet *pflag.FlagSet) {
+	flagSet.String(flagPrefix+"hosts", "127.0.0.1", "Iginx hosts, comma-separated. A host may override the port as 'host:port'")
+	flagSet.String(flagPrefix+"port", "6888", "Iginx session port")
+	flagSet.String(flagPrefix+"user", client.DefaultUsername, "User to connect to Iginx as")
+	flagSet.String(flagPrefix+"password", client.DefaultPassword, "Password for user connecting to Iginx")
+	flagSet.Duration(flagPrefix+"reconnect-interval", 10*time.Second,
+		"Time after which a node a session dropped from is tried again, 0 to never try it again")
+	flagSet.String(flagPrefix+"url", "http://localhost:6666/", "Iginx REST end point")
+	flagSet.String(flagPrefix+"session-assignment", SessionAssignmentPerWorker,
+		"How workers are assigned to hosts: 'per-worker' pins worker i to host i%len(hosts), "+
+			"'round-robin' opens a session to every host and rotates through them on each request, "+
+			"'random' opens a session to every host and picks one at random for each request")
 }
 
 func (t *influxTarget) TargetName() string {
+	if t.dataFormat == DataFormatNative {
+		return constants.FormatIginxNative
+	}
 	return constants.FormatIginx
 }
 
 func (t *influxTarget) Serializer() serialize.PointSerializer {
+	if t.dataFormat == DataFormatNative {
+		return &NativeSerializer{}
+	}
 	return &Serializer{}
 }
 
-func (t *influxTarget) Benchmark(string, *source.DataSourceConfig, *viper.Viper) (targets.Benchmark, error) {
-	panic("not implemented")
+// PathTemplateSerializer returns the serializer for data laid out with the
+// given path template and tag mode. Influx lines are mapped to paths by the
+// loader, so they only change native data, but they are checked either way.
+func (t *influxTarget) PathTemplateSerializer(template, tagMode string) (serialize.PointSerializer, error) {
+	parsed, err := pathtemplate.ParseWithTagMode(template, tagMode)
+	if err != nil {
+		return nil, err
+	}
+	if t.dataFormat == DataFormatNative {
+		return &NativeSerializer{Template: parsed}, nil
+	}
+	return &Serializer{}, nil
+}
+
+func (t *influxTarget) Benchmark(_ string, dataSourceConfig *source.DataSourceConfig, v *viper.Viper) (targets.Benchmark, error) {
+	iginxSpecificConfig, err := parseSpecificConfig(v)
+	if err != nil {
+		return nil, err
+	}
+	return NewBenchmark(iginxSpecificConfig, dataSourceConfig)
 }
@@ -0,0 +1,139 @@
+package iginx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/thulab/iginx-client-go/rpc"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+// appendInfluxLine parses a line of Influx line protocol and appends each of
+// its fields to the batch. The path of a field is made of the measurement,
+// the tag values and the field name, e.g.:
+//
+// cpu,hostname=host_0,region=eu-west-1 usage_user=58 1451606400000000000
+//
+// becomes cpu.host_0.eu_west_1.usage_user at 1451606400000 (ms) with the
+// default path template, or cpu.usage_user with IginX tags hostname=host_0
+// and region=eu_west_1 if the template uses IginX tags. The type of each field follows from how its value
+// is written, see parseInfluxValue.
+func appendInfluxLine(cols *columnBuilder, template *pathtemplate.Template, line string) error {
+	args, err := splitInfluxLine(line)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(args[0], ",")
+	tags := make([]pathtemplate.Tag, 0, len(parts)-1)
+	for _, tag := range parts[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("malformed tag %s in line: %s", tag, line)
+		}
+		tags = append(tags, pathtemplate.Tag{Key: []byte(kv[0]), Value: []byte(kv[1])})
+	}
+	prefix := template.AppendPrefix(nil, []byte(parts[0]), tags)
+	iginxTags := template.AppendTags(nil, tags)
+
+	timestamp, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed timestamp in line: %s", line)
+	}
+	timestamp /= 1000000
+
+	for _, field := range splitInfluxFields(args[1]) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("malformed field %s in line: %s", field, line)
+		}
+		series := template.AppendField(append([]byte(nil), prefix...), []byte(kv[0]))
+		series = append(series, iginxTags...)
+		v, dataType, err := parseInfluxValue(kv[1])
+		if err != nil {
+			return fmt.Errorf("malformed value of %s in line: %s: %v", kv[0], line, err)
+		}
+		if err := cols.Append(string(series), timestamp, v, dataType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitInfluxLine splits a line into its "csv-tags csv-fields timestamp"
+// parts. Tags never contain spaces, but string fields may, so the fields are
+// whatever lies between the first and the last space.
+func splitInfluxLine(line string) ([]string, error) {
+	first := strings.IndexByte(line, ' ')
+	last := strings.LastIndexByte(line, ' ')
+	if first < 0 || first == last {
+		return nil, fmt.Errorf(errNotThreeTuplesFmt, len(strings.Split(line, " ")))
+	}
+	return []string{line[:first], line[first+1 : last], line[last+1:]}, nil
+}
+
+// splitInfluxFields splits the fields of a line on the commas that are not
+// part of a string value.
+func splitInfluxFields(fields string) []string {
+	var parts []string
+	inString, escaped, start := false, false, 0
+	for i := 0; i < len(fields); i++ {
+		switch c := fields[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && inString:
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case c == ',' && !inString:
+			parts = append(parts, fields[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, fields[start:])
+}
+
+// parseInfluxValue infers the IginX data type of a field value the same way
+// InfluxDB does: integers carry an 'i' suffix, strings are double quoted,
+// booleans are one of t, true, f, false (in any case) and anything else is
+// a float.
+func parseInfluxValue(raw string) (interface{}, rpc.DataType, error) {
+	if raw == "" {
+		return nil, 0, fmt.Errorf("empty value")
+	}
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return true, rpc.DataType_BOOLEAN, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, rpc.DataType_BOOLEAN, nil
+	}
+	if raw[0] == '"' {
+		if len(raw) < 2 || raw[len(raw)-1] != '"' {
+			return nil, 0, fmt.Errorf("unterminated string %s", raw)
+		}
+		return unescapeInfluxString(raw[1 : len(raw)-1]), rpc.DataType_BINARY, nil
+	}
+	if raw[len(raw)-1] == 'i' {
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		return v, rpc.DataType_LONG, err
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	return v, rpc.DataType_DOUBLE, err
+}
+
+// unescapeInfluxString removes the backslashes escaping double quotes and
+// backslashes inside a string field.
+func unescapeInfluxString(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
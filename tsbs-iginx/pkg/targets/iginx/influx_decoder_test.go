@@ -0,0 +1,138 @@
+package iginx
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thulab/iginx-client-go/rpc"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+func TestAppendInfluxLine(t *testing.T) {
+	c := newColumnBuilder()
+	lines := []string{
+		"cpu,hostname=host_0,region=eu-west-1 usage_user=58,usage_system=2 1451606400000000000",
+		"cpu,hostname=host_0,region=eu-west-1 usage_user=59,usage_system=3 1451606410000000000",
+		"cpu,hostname=host_1,region=us.east usage_user=60 1451606400000000000",
+	}
+	for _, l := range lines {
+		if err := appendInfluxLine(c, defaultTemplate, l); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	got := c.Build()
+	wantPaths := []string{
+		"cpu.host_0.eu_west_1.usage_system",
+		"cpu.host_0.eu_west_1.usage_user",
+		"cpu.host_1.us_east.usage_user",
+	}
+	if !reflect.DeepEqual(got.paths, wantPaths) {
+		t.Errorf("incorrect paths: got %v want %v", got.paths, wantPaths)
+	}
+	wantTimestamps := []int64{1451606400000, 1451606410000}
+	if !reflect.DeepEqual(got.timestamps, wantTimestamps) {
+		t.Errorf("incorrect timestamps: got %v want %v", got.timestamps, wantTimestamps)
+	}
+	for i, typ := range got.types {
+		if typ != rpc.DataType_DOUBLE {
+			t.Errorf("incorrect type for %s: %v", got.paths[i], typ)
+		}
+	}
+	if got.values[2][1] != nil {
+		t.Errorf("expected no value for host_1 at second timestamp, got %v", got.values[2][1])
+	}
+}
+
+func TestAppendInfluxLineErrors(t *testing.T) {
+	cases := []struct {
+		desc string
+		line string
+	}{
+		{desc: "missing timestamp", line: "cpu,hostname=host_0 usage_user=58"},
+		{desc: "malformed tag", line: "cpu,hostname usage_user=58 1451606400000000000"},
+		{desc: "malformed field", line: "cpu,hostname=host_0 usage_user 1451606400000000000"},
+		{desc: "malformed timestamp", line: "cpu,hostname=host_0 usage_user=58 now"},
+		{desc: "malformed value", line: "cpu,hostname=host_0 usage_user=abc 1451606400000000000"},
+		{desc: "malformed integer", line: "cpu,hostname=host_0 usage_user=1.5i 1451606400000000000"},
+		{desc: "unterminated string", line: "cpu,hostname=host_0 os=\"Ubuntu 1451606400000000000"},
+		{desc: "conflicting types", line: "cpu,hostname=host_0 a=1i,a=t 1451606400000000000"},
+	}
+	for _, c := range cases {
+		if err := appendInfluxLine(newColumnBuilder(), defaultTemplate, c.line); err == nil {
+			t.Errorf("%s: expected error, got none", c.desc)
+		}
+	}
+}
+
+func TestAppendInfluxLineTypes(t *testing.T) {
+	c := newColumnBuilder()
+	lines := []string{
+		`diagnostics,name=truck_0 load=1500i,fuel=0.25,moving=t,driver="Trish \"T\", B",ratio=3i 1451606400000000000`,
+		`diagnostics,name=truck_0 load=1499i,moving=FALSE,ratio=2.5 1451606410000000000`,
+	}
+	for _, l := range lines {
+		if err := appendInfluxLine(c, defaultTemplate, l); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	got := c.Build()
+	want := &columns{
+		paths: []string{
+			"diagnostics.truck_0.driver",
+			"diagnostics.truck_0.fuel",
+			"diagnostics.truck_0.load",
+			"diagnostics.truck_0.moving",
+			"diagnostics.truck_0.ratio",
+		},
+		types: []rpc.DataType{
+			rpc.DataType_BINARY,
+			rpc.DataType_DOUBLE,
+			rpc.DataType_LONG,
+			rpc.DataType_BOOLEAN,
+			// mixing integers and floats widens the path to DOUBLE
+			rpc.DataType_DOUBLE,
+		},
+		timestamps: []int64{1451606400000, 1451606410000},
+		values: [][]interface{}{
+			{`Trish "T", B`, nil},
+			{0.25, nil},
+			{int64(1500), int64(1499)},
+			{true, false},
+			{3.0, 2.5},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect columns:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseInfluxValueKeepsPrecision(t *testing.T) {
+	v, typ, err := parseInfluxValue("38.24311829")
+	if err != nil || typ != rpc.DataType_DOUBLE || v != 38.24311829 {
+		t.Errorf("got %v %v %v, want 38.24311829 as DOUBLE", v, typ, err)
+	}
+	v, typ, err = parseInfluxValue("5000000000i")
+	if err != nil || typ != rpc.DataType_LONG || v != int64(5000000000) {
+		t.Errorf("got %v %v %v, want 5000000000 as LONG", v, typ, err)
+	}
+}
+
+func TestAppendInfluxLineIginxTags(t *testing.T) {
+	template, err := pathtemplate.ParseWithTagMode(pathtemplate.Default, pathtemplate.TagModeIginx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := newColumnBuilder()
+	line := "cpu,hostname=host_0,region=eu-west-1 usage_user=58 1451606400000000000"
+	if err := appendInfluxLine(c, template, line); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := c.Build()
+	if !reflect.DeepEqual(got.paths, []string{"cpu.usage_user"}) {
+		t.Errorf("incorrect paths: got %v", got.paths)
+	}
+	wantTags := []map[string]string{{"hostname": "host_0", "region": "eu_west_1"}}
+	if !reflect.DeepEqual(got.tags, wantTags) {
+		t.Errorf("incorrect tags: got %v want %v", got.tags, wantTags)
+	}
+}
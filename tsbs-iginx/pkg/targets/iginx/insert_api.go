@@ -0,0 +1,63 @@
+package iginx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thulab/iginx-client-go/client"
+	"github.com/thulab/iginx-client-go/rpc"
+)
+
+// Session insert methods the loader can write batches with.
+const (
+	// InsertAPIColumn sends the batch column by column, one list of values
+	// per series, with Session.InsertColumnRecords
+	InsertAPIColumn = "column"
+	// InsertAPIRow sends the batch row by row, one list of values per
+	// timestamp, with Session.InsertRowRecords
+	InsertAPIRow = "row"
+	// InsertAPINonAlignedColumn is InsertAPIColumn with
+	// Session.InsertNonAlignedColumnRecords
+	InsertAPINonAlignedColumn = "non-aligned-column"
+	// InsertAPINonAlignedRow is InsertAPIRow with
+	// Session.InsertNonAlignedRowRecords
+	InsertAPINonAlignedRow = "non-aligned-row"
+)
+
+var insertAPINames = []string{InsertAPIColumn, InsertAPIRow, InsertAPINonAlignedColumn, InsertAPINonAlignedRow}
+
+// insertAPI is a Session insert method along with the layout of the values
+// it takes.
+type insertAPI struct {
+	insert func(s *client.Session, paths []string, timestamps []int64, values [][]interface{},
+		types []rpc.DataType, tags []map[string]string) error
+	// rowWise is whether values are indexed by timestamp first
+	rowWise bool
+}
+
+var insertAPIs = map[string]insertAPI{
+	InsertAPIColumn:           {insert: (*client.Session).InsertColumnRecords},
+	InsertAPIRow:              {insert: (*client.Session).InsertRowRecords, rowWise: true},
+	InsertAPINonAlignedColumn: {insert: (*client.Session).InsertNonAlignedColumnRecords},
+	InsertAPINonAlignedRow:    {insert: (*client.Session).InsertNonAlignedRowRecords, rowWise: true},
+}
+
+// getInsertAPI returns the named insert API, InsertAPIColumn if name is empty.
+func getInsertAPI(name string) (insertAPI, error) {
+	if name == "" {
+		name = InsertAPIColumn
+	}
+	api, ok := insertAPIs[name]
+	if !ok {
+		return insertAPI{}, fmt.Errorf("invalid insert API '%s', supported: %s", name, strings.Join(insertAPINames, ", "))
+	}
+	return api, nil
+}
+
+// values returns the values of the batch in the layout the API takes.
+func (a insertAPI) values(cols *columns) [][]interface{} {
+	if a.rowWise {
+		return cols.rows()
+	}
+	return cols.values
+}
@@ -0,0 +1,40 @@
+package iginx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetInsertAPI(t *testing.T) {
+	cols := &columns{
+		paths:      []string{"cpu.host_0.usage_user", "cpu.host_1.usage_user"},
+		timestamps: []int64{10},
+		values:     [][]interface{}{{1.0}, {2.0}},
+	}
+	cases := []struct {
+		name       string
+		wantValues [][]interface{}
+	}{
+		{"", [][]interface{}{{1.0}, {2.0}}},
+		{InsertAPIColumn, [][]interface{}{{1.0}, {2.0}}},
+		{InsertAPINonAlignedColumn, [][]interface{}{{1.0}, {2.0}}},
+		{InsertAPIRow, [][]interface{}{{1.0, 2.0}}},
+		{InsertAPINonAlignedRow, [][]interface{}{{1.0, 2.0}}},
+	}
+	for _, c := range cases {
+		api, err := getInsertAPI(c.name)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if api.insert == nil {
+			t.Errorf("%s: no insert method", c.name)
+		}
+		if got := api.values(cols); !reflect.DeepEqual(got, c.wantValues) {
+			t.Errorf("%s: incorrect values: got %v want %v", c.name, got, c.wantValues)
+		}
+	}
+	if _, err := getInsertAPI("bogus"); err == nil {
+		t.Errorf("unexpected lack of error for unknown insert API")
+	}
+}
@@ -0,0 +1,112 @@
+package iginx
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/thulab/iginx-client-go/rpc"
+)
+
+const errNativeFieldsFmt = "parse error: native line must have a timestamp and path/value pairs, has %d elements"
+
+// appendNativeLine decodes a line written by NativeSerializer and appends
+// each of its values to the batch. Paths are looked up without copying the
+// line, so only new paths and string values allocate.
+func appendNativeLine(cols *columnBuilder, line []byte) error {
+	i := bytes.IndexByte(line, nativeSep)
+	if i < 0 {
+		return fmt.Errorf(errNativeFieldsFmt, 1)
+	}
+	timestamp, err := parseInt(line[:i])
+	if err != nil {
+		return fmt.Errorf("malformed timestamp in line: %s", line)
+	}
+
+	rest := line[i+1:]
+	for len(rest) > 0 {
+		i = bytes.IndexByte(rest, nativeSep)
+		if i < 0 {
+			return fmt.Errorf("path without value in line: %s", line)
+		}
+		path := rest[:i]
+		rest = rest[i+1:]
+
+		raw := rest
+		if i = bytes.IndexByte(rest, nativeSep); i >= 0 {
+			raw = rest[:i]
+			rest = rest[i+1:]
+		} else {
+			rest = nil
+		}
+
+		value, dataType, err := parseNativeValue(raw)
+		if err != nil {
+			return fmt.Errorf("malformed value of %s in line: %s: %v", path, line, err)
+		}
+		if err := cols.AppendBytes(path, timestamp, value, dataType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseNativeValue(raw []byte) (interface{}, rpc.DataType, error) {
+	if len(raw) < 2 {
+		return nil, 0, fmt.Errorf("empty value")
+	}
+	switch raw[0] {
+	case nativeTypeLong:
+		v, err := parseInt(raw[1:])
+		return v, rpc.DataType_LONG, err
+	case nativeTypeDouble:
+		v, err := strconv.ParseFloat(string(raw[1:]), 64)
+		return v, rpc.DataType_DOUBLE, err
+	case nativeTypeBoolean:
+		switch raw[1] {
+		case 't':
+			return true, rpc.DataType_BOOLEAN, nil
+		case 'f':
+			return false, rpc.DataType_BOOLEAN, nil
+		}
+		return nil, 0, fmt.Errorf("invalid boolean %s", raw[1:])
+	case nativeTypeBinary:
+		v, err := strconv.Unquote(string(raw[1:]))
+		return v, rpc.DataType_BINARY, err
+	}
+	return nil, 0, fmt.Errorf("unknown type '%c'", raw[0])
+}
+
+// parseInt parses a base 10 int64 without converting b to a string first.
+func parseInt(b []byte) (int64, error) {
+	if len(b) == 0 {
+		return 0, fmt.Errorf("empty integer")
+	}
+	neg := b[0] == '-'
+	if neg || b[0] == '+' {
+		b = b[1:]
+		if len(b) == 0 {
+			return 0, fmt.Errorf("invalid integer")
+		}
+	}
+	var n uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid integer")
+		}
+		if n > (1<<63)/10 {
+			return 0, fmt.Errorf("integer out of range")
+		}
+		n = n*10 + uint64(c-'0')
+		if n > 1<<63 {
+			return 0, fmt.Errorf("integer out of range")
+		}
+	}
+	if neg {
+		return -int64(n), nil
+	}
+	if n > 1<<63-1 {
+		return 0, fmt.Errorf("integer out of range")
+	}
+	return int64(n), nil
+}
@@ -0,0 +1,89 @@
+package iginx
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/thulab/iginx-client-go/rpc"
+)
+
+func TestAppendNativeLine(t *testing.T) {
+	cols := newColumnBuilder()
+	lines := []string{
+		"1451606410000\tcpu.host_0.usage_user\td58.5\tcpu.host_0.usage_idle\tl24",
+		"1451606400000\tcpu.host_0.usage_user\td1\tcpu.host_0.up\tbf\tcpu.host_0.os\ts\"Ubuntu16.04LTS\"",
+	}
+	for _, l := range lines {
+		if err := appendNativeLine(cols, []byte(l)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	got := cols.Build()
+	want := &columns{
+		paths:      []string{"cpu.host_0.os", "cpu.host_0.up", "cpu.host_0.usage_idle", "cpu.host_0.usage_user"},
+		types:      []rpc.DataType{rpc.DataType_BINARY, rpc.DataType_BOOLEAN, rpc.DataType_LONG, rpc.DataType_DOUBLE},
+		timestamps: []int64{1451606400000, 1451606410000},
+		values: [][]interface{}{
+			{"Ubuntu16.04LTS", nil},
+			{false, nil},
+			{nil, int64(24)},
+			{1.0, 58.5},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect columns:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestAppendNativeLineErrors(t *testing.T) {
+	cases := []struct {
+		desc string
+		line string
+	}{
+		{desc: "no values", line: "1451606400000"},
+		{desc: "bad timestamp", line: "14516x\tcpu.usage_user\td1"},
+		{desc: "path without value", line: "1451606400000\tcpu.usage_user"},
+		{desc: "empty value", line: "1451606400000\tcpu.usage_user\t"},
+		{desc: "unknown type", line: "1451606400000\tcpu.usage_user\tx1"},
+		{desc: "bad long", line: "1451606400000\tcpu.usage_user\tl1.5"},
+		{desc: "bad boolean", line: "1451606400000\tcpu.up\tbyes"},
+		{desc: "unquoted string", line: "1451606400000\tcpu.os\tsUbuntu"},
+	}
+	for _, c := range cases {
+		if err := appendNativeLine(newColumnBuilder(), []byte(c.line)); err == nil {
+			t.Errorf("%s: expected error, got none", c.desc)
+		}
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	cases := []struct {
+		in          string
+		want        int64
+		shouldError bool
+	}{
+		{in: "0", want: 0},
+		{in: "-42", want: -42},
+		{in: "+7", want: 7},
+		{in: "9223372036854775807", want: math.MaxInt64},
+		{in: "-9223372036854775808", want: math.MinInt64},
+		{in: "9223372036854775808", shouldError: true},
+		{in: "99999999999999999999", shouldError: true},
+		{in: "", shouldError: true},
+		{in: "-", shouldError: true},
+		{in: "1e3", shouldError: true},
+	}
+	for _, c := range cases {
+		got, err := parseInt([]byte(c.in))
+		if c.shouldError {
+			if err == nil {
+				t.Errorf("%q: expected error, got %d", c.in, got)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("%q: got %d, %v want %d", c.in, got, err, c.want)
+		}
+	}
+}
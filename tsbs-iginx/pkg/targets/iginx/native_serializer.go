@@ -0,0 +1,146 @@
+package iginx
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets/iginx/pathtemplate"
+)
+
+// Type markers prefixing each value in the native format.
+const (
+	nativeTypeLong    = 'l'
+	nativeTypeDouble  = 'd'
+	nativeTypeBoolean = 'b'
+	nativeTypeBinary  = 's'
+)
+
+const nativeSep = '\t'
+
+var defaultTemplate = pathtemplate.MustParse(pathtemplate.Default)
+
+// NativeSerializer writes a Point in the native IginX format, which keeps the
+// data type of every value and needs no further processing by the loader
+// beyond splitting the line.
+//
+// Each point is written as a single tab separated line:
+// <timestamp ms>\t<path>\t<type><value>\t<path>\t<type><value>...\n
+//
+// where path is the full dotted IginX path of the series, laid out by the
+// path template (measurement, tag values and field name by default) and
+// followed by its IginX tags as {key1=value1,...} if it has any, and type is one of 'l' (int64), 'd' (float64),
+// 'b' (bool, written as t or f) or 's' (string, written Go-quoted).
+//
+// For example:
+// 1451606400000	cpu.host_0.eu_west_1.usage_user	d58.2	cpu.host_0.eu_west_1.usage_idle	l24\n
+type NativeSerializer struct {
+	// Template lays out the paths, nil means pathtemplate.Default
+	Template *pathtemplate.Template
+}
+
+// Serialize writes Point data to the given writer in the native IginX format.
+func (s *NativeSerializer) Serialize(p *data.Point, w io.Writer) (err error) {
+	template := s.Template
+	if template == nil {
+		template = defaultTemplate
+	}
+
+	fakeTags := make([]int, 0)
+	tagKeys := p.TagKeys()
+	tagValues := p.TagValues()
+	tags := make([]pathtemplate.Tag, 0, len(tagKeys))
+	for i := 0; i < len(tagKeys); i++ {
+		switch v := tagValues[i].(type) {
+		case nil:
+			tags = append(tags, pathtemplate.Tag{Key: tagKeys[i]})
+		case string:
+			tags = append(tags, pathtemplate.Tag{Key: tagKeys[i], Value: []byte(v)})
+		default:
+			fakeTags = append(fakeTags, i)
+		}
+	}
+	prefix := template.AppendPrefix(make([]byte, 0, 256), p.MeasurementName(), tags)
+	iginxTags := template.AppendTags(nil, tags)
+
+	buf := make([]byte, 0, 1024)
+	buf = strconv.AppendInt(buf, p.Timestamp().UTC().UnixNano()/1e6, 10)
+	written := 0
+	for _, i := range fakeTags {
+		buf, err = appendNativeValue(buf, prefix, tagKeys[i], iginxTags, tagValues[i])
+		if err != nil {
+			return err
+		}
+		written++
+	}
+
+	fieldKeys := p.FieldKeys()
+	fieldValues := p.FieldValues()
+	for i := 0; i < len(fieldKeys); i++ {
+		if fieldValues[i] == nil {
+			continue
+		}
+		buf, err = appendNativeValue(buf, prefix, fieldKeys[i], iginxTags, fieldValues[i])
+		if err != nil {
+			return err
+		}
+		written++
+	}
+
+	// nothing to insert, all the fields were nil
+	if written == 0 {
+		return nil
+	}
+	buf = append(buf, '\n')
+	_, err = w.Write(buf)
+	return err
+}
+
+func appendNativeValue(buf, prefix, key, iginxTags []byte, v interface{}) ([]byte, error) {
+	buf = append(buf, nativeSep)
+	buf = append(buf, prefix...)
+	if len(prefix) > 0 {
+		buf = append(buf, '.')
+	}
+	buf = pathtemplate.AppendName(buf, key)
+	buf = append(buf, iginxTags...)
+	buf = append(buf, nativeSep)
+
+	// integers of any kind are loaded as LONG
+	if b, ok := appendInteger(append(buf, nativeTypeLong), v); ok {
+		return b, nil
+	}
+	switch x := v.(type) {
+	case uint:
+		// too large for a LONG, loaded as DOUBLE, the type a series of
+		// integers widens to, see columnBuilder.mergeType
+		buf = append(buf, nativeTypeDouble)
+		buf = strconv.AppendFloat(buf, float64(x), 'f', -1, 64)
+	case uint64:
+		buf = append(buf, nativeTypeDouble)
+		buf = strconv.AppendFloat(buf, float64(x), 'f', -1, 64)
+	case float32:
+		buf = append(buf, nativeTypeDouble)
+		buf = strconv.AppendFloat(buf, float64(x), 'f', -1, 32)
+	case float64:
+		buf = append(buf, nativeTypeDouble)
+		buf = strconv.AppendFloat(buf, x, 'f', -1, 64)
+	case bool:
+		buf = append(buf, nativeTypeBoolean)
+		if x {
+			buf = append(buf, 't')
+		} else {
+			buf = append(buf, 'f')
+		}
+	case string:
+		buf = append(buf, nativeTypeBinary)
+		buf = strconv.AppendQuote(buf, x)
+	case []byte:
+		buf = append(buf, nativeTypeBinary)
+		buf = strconv.AppendQuote(buf, string(x))
+	default:
+		return nil, fmt.Errorf("unknown field type for %#v", v)
+	}
+	return buf, nil
+}
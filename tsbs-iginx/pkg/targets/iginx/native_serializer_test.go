@@ -0,0 +1,97 @@
+package iginx
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/serialize"
+)
+
+func TestNativeSerializerSerialize(t *testing.T) {
+	cases := []serialize.SerializeCase{
+		{
+			Desc:       "a regular Point",
+			InputPoint: serialize.TestPointDefault(),
+			Output:     "1451606400000\tcpu.host_0.eu_west_1.eu_west_1b.usage_guest_nice\td38.24311829\n",
+		},
+		{
+			Desc:       "a regular Point using int as value",
+			InputPoint: serialize.TestPointInt(),
+			Output:     "1451606400000\tcpu.host_0.eu_west_1.eu_west_1b.usage_guest\tl38\n",
+		},
+		{
+			Desc:       "a regular Point with multiple fields",
+			InputPoint: serialize.TestPointMultiField(),
+			Output: "1451606400000\tcpu.host_0.eu_west_1.eu_west_1b.big_usage_guest\tl5000000000" +
+				"\tcpu.host_0.eu_west_1.eu_west_1b.usage_guest\tl38" +
+				"\tcpu.host_0.eu_west_1.eu_west_1b.usage_guest_nice\td38.24311829\n",
+		},
+		{
+			Desc:       "a Point with no tags",
+			InputPoint: serialize.TestPointNoTags(),
+			Output:     "1451606400000\tcpu.usage_guest_nice\td38.24311829\n",
+		}, {
+			Desc:       "a Point with a nil tag",
+			InputPoint: serialize.TestPointWithNilTag(),
+			Output:     "1451606400000\tcpu.usage_guest_nice\td38.24311829\n",
+		}, {
+			Desc:       "a Point with a nil field",
+			InputPoint: serialize.TestPointWithNilField(),
+			Output:     "1451606400000\tcpu.usage_guest_nice\td38.24311829\n",
+		},
+		{
+			Desc:       "a Point with a uint64 field above MaxInt64",
+			InputPoint: testPointWithBigUnsigned(),
+			Output:     "1451606400000\tdiagnostics.odometer\td9223372036854776000\n",
+		},
+	}
+
+	serialize.SerializerTest(t, cases, &NativeSerializer{})
+}
+
+func TestNativeSerializerRoundTrip(t *testing.T) {
+	now := time.Unix(1451606400, 0)
+	p := data.NewPoint()
+	p.SetMeasurementName([]byte("diagnostics"))
+	p.SetTimestamp(&now)
+	p.AppendTag([]byte("name"), "truck_0")
+	p.AppendTag([]byte("load_capacity"), 1500.0)
+	p.AppendField([]byte("fuel_state"), 0.25)
+	p.AppendField([]byte("status"), int64(-3))
+	p.AppendField([]byte("moving"), true)
+	p.AppendField([]byte("driver"), "Trish \"T\"\tB")
+	p.AppendField([]byte("odometer"), uint64(math.MaxUint64))
+
+	buf := &bytes.Buffer{}
+	if err := (&NativeSerializer{}).Serialize(p, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cols := newColumnBuilder()
+	if err := appendNativeLine(cols, bytes.TrimSuffix(buf.Bytes(), newLine)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"diagnostics.truck_0.load_capacity": 1500.0,
+		"diagnostics.truck_0.fuel_state":    0.25,
+		"diagnostics.truck_0.status":        int64(-3),
+		"diagnostics.truck_0.moving":        true,
+		"diagnostics.truck_0.driver":        "Trish \"T\"\tB",
+		"diagnostics.truck_0.odometer":      float64(math.MaxUint64),
+	}
+	records := cols.Build()
+	if len(records.paths) != len(want) {
+		t.Fatalf("incorrect number of paths: got %v", records.paths)
+	}
+	for i, path := range records.paths {
+		if got := records.values[i][0]; got != want[path] {
+			t.Errorf("incorrect value for %s: got %#v want %#v", path, got, want[path])
+		}
+	}
+	if records.timestamps[0] != 1451606400000 {
+		t.Errorf("incorrect timestamp: got %d", records.timestamps[0])
+	}
+}
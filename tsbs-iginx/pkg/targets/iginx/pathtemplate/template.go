@@ -0,0 +1,403 @@
+// Package pathtemplate maps the measurement, tags and fields of a point to an
+// IginX series path. It is shared by the IginX serializers, the loader and the
+// query generator, so that queries always address the layout the data was
+// loaded with.
+package pathtemplate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Default reproduces the historical layout: the measurement, then the value
+// of every tag in the order the point lists them, then the field.
+const Default = "{measurement}.{tags}.{field}"
+
+// Missing is written in place of a tag the template names but the point
+// lacks, so that all the series of a measurement keep the same depth.
+const Missing = "null"
+
+// Ways of storing the tags of a point.
+const (
+	// TagModePath places tag values in the series path, as laid out by the
+	// path template
+	TagModePath = "path"
+	// TagModeIginx keeps the tags the path template names in the path and
+	// sends all the others as IginX tags
+	TagModeIginx = "iginx"
+)
+
+var tagModes = []string{TagModePath, TagModeIginx}
+
+const (
+	segmentLiteral = iota
+	segmentMeasurement
+	segmentTags
+	segmentTag
+	segmentField
+)
+
+type segment struct {
+	kind int
+	// name is the tag key of a segmentTag or the text of a segmentLiteral
+	name string
+}
+
+// Template is a parsed path template such as
+// "{region}.{datacenter}.{hostname}.{measurement}.{field}". Each dot
+// separated segment is one of:
+//
+//	{measurement}  the measurement name
+//	{field}        the field name, must be the last segment
+//	{tags}         the values of all the tags not named elsewhere in the
+//	               template, in point order; nil tags are left out
+//	{<tag key>}    the value of that tag, or Missing
+//	anything else  copied as is
+//
+// A template without {tags} leaves the tags it does not name out of the path.
+// A template using IginX tags, see WithIginxTags, sends them as IginX tags
+// instead, {tags} then stands for nothing.
+type Template struct {
+	segments  []segment
+	named     map[string]bool
+	hasTags   bool
+	iginxTags bool
+}
+
+// Tag is a tag of a point as seen by a Template.
+type Tag struct {
+	Key   []byte
+	Value []byte
+}
+
+// Parse parses a path template, an empty string meaning Default.
+func Parse(s string) (*Template, error) {
+	if s == "" {
+		s = Default
+	}
+	t := &Template{named: map[string]bool{}}
+	parts := strings.Split(s, ".")
+	for i, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("path template '%s' has an empty segment", s)
+		}
+		seg := segment{kind: segmentLiteral, name: p}
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			switch name := p[1 : len(p)-1]; name {
+			case "measurement":
+				seg = segment{kind: segmentMeasurement}
+			case "field":
+				if i != len(parts)-1 {
+					return nil, fmt.Errorf("path template '%s' must end with {field}", s)
+				}
+				seg = segment{kind: segmentField}
+			case "tags":
+				if t.hasTags {
+					return nil, fmt.Errorf("path template '%s' has more than one {tags}", s)
+				}
+				t.hasTags = true
+				seg = segment{kind: segmentTags}
+			case "":
+				return nil, fmt.Errorf("path template '%s' has an empty placeholder", s)
+			default:
+				t.named[name] = true
+				seg = segment{kind: segmentTag, name: name}
+			}
+		} else if strings.ContainsAny(p, "{}") {
+			return nil, fmt.Errorf("malformed segment '%s' in path template '%s'", p, s)
+		}
+		t.segments = append(t.segments, seg)
+	}
+	if t.segments[len(t.segments)-1].kind != segmentField {
+		return nil, fmt.Errorf("path template '%s' must end with {field}", s)
+	}
+	return t, nil
+}
+
+// ParseWithTagMode parses a path template for the given tag mode, empty
+// values meaning Default and TagModePath.
+func ParseWithTagMode(s, tagMode string) (*Template, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch tagMode {
+	case "", TagModePath:
+		return t, nil
+	case TagModeIginx:
+		return t.WithIginxTags(), nil
+	}
+	return nil, fmt.Errorf("invalid tag mode '%s', supported: %s", tagMode, strings.Join(tagModes, ", "))
+}
+
+// MustParse is Parse for templates known to be valid.
+func MustParse(s string) *Template {
+	t, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// WithIginxTags returns a copy of the template that keeps the tags it names
+// in the path and sends all the others as IginX tags.
+func (t *Template) WithIginxTags() *Template {
+	c := *t
+	c.iginxTags = true
+	return &c
+}
+
+// AppendPrefix appends the path of a series up to, but excluding, the field:
+// pass the result to AppendField for each field of the point.
+func (t *Template) AppendPrefix(buf, measurement []byte, tags []Tag) []byte {
+	first := true
+	for _, s := range t.segments {
+		switch s.kind {
+		case segmentField:
+			continue
+		case segmentTags:
+			if t.iginxTags {
+				continue
+			}
+			for _, tag := range tags {
+				if tag.Value == nil || t.named[string(tag.Key)] {
+					continue
+				}
+				buf = appendSeparator(buf, &first)
+				buf = AppendTagValue(buf, tag.Value)
+			}
+			continue
+		}
+
+		buf = appendSeparator(buf, &first)
+		switch s.kind {
+		case segmentMeasurement:
+			buf = AppendName(buf, measurement)
+		case segmentTag:
+			buf = appendNamedTag(buf, s.name, tags)
+		default:
+			buf = append(buf, s.name...)
+		}
+	}
+	return buf
+}
+
+// AppendField appends the field to a prefix written by AppendPrefix.
+func (t *Template) AppendField(prefix, field []byte) []byte {
+	if len(prefix) > 0 {
+		prefix = append(prefix, '.')
+	}
+	return AppendName(prefix, field)
+}
+
+// Pattern returns the path prefix matching the series of measurement with
+// the given tag values, for use in the FROM clause of a query. tags lists the
+// tags of the measurement in point order, those with a nil Value, and those
+// not listed at all, are matched by '*'.
+func (t *Template) Pattern(measurement string, tags []Tag) string {
+	var segs []string
+	for _, s := range t.segments {
+		switch s.kind {
+		case segmentField:
+		case segmentMeasurement:
+			segs = append(segs, string(AppendName(nil, []byte(measurement))))
+		case segmentTag:
+			v := "*"
+			for _, tag := range tags {
+				if string(tag.Key) == s.name && tag.Value != nil {
+					v = string(AppendTagValue(nil, tag.Value))
+				}
+			}
+			segs = append(segs, v)
+		case segmentTags:
+			if t.iginxTags {
+				continue
+			}
+			for _, tag := range tags {
+				if t.named[string(tag.Key)] {
+					continue
+				}
+				v := "*"
+				if tag.Value != nil {
+					v = string(AppendTagValue(nil, tag.Value))
+				}
+				segs = append(segs, v)
+			}
+		default:
+			segs = append(segs, s.name)
+		}
+	}
+
+	// IginX matches any number of levels with a single '*'
+	collapsed := segs[:0]
+	for i, s := range segs {
+		if s == "*" && i > 0 && segs[i-1] == "*" {
+			continue
+		}
+		collapsed = append(collapsed, s)
+	}
+	return strings.Join(collapsed, ".")
+}
+
+// IginxTags returns the tags of a point to send as IginX tags, that is none
+// unless the template uses IginX tags, and otherwise the tags with a value
+// the template does not place in the path, sorted by key.
+func (t *Template) IginxTags(tags []Tag) []Tag {
+	if !t.iginxTags {
+		return nil
+	}
+	var out []Tag
+	for _, tag := range tags {
+		if tag.Value == nil || t.named[string(tag.Key)] {
+			continue
+		}
+		out = append(out, Tag{Key: tag.Key, Value: AppendTagValue(nil, tag.Value)})
+	}
+	sort.Slice(out, func(i, j int) bool { return string(out[i].Key) < string(out[j].Key) })
+	return out
+}
+
+// AppendTags appends the IginX tags of a point to a series path, the same
+// way IginX prints them: path{key1=value1,key2=value2}. Nothing is appended
+// if the point has no IginX tags.
+func (t *Template) AppendTags(buf []byte, tags []Tag) []byte {
+	iginxTags := t.IginxTags(tags)
+	if len(iginxTags) == 0 {
+		return buf
+	}
+	buf = append(buf, '{')
+	for i, tag := range iginxTags {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, tag.Key...)
+		buf = append(buf, '=')
+		buf = append(buf, tag.Value...)
+	}
+	return append(buf, '}')
+}
+
+// Match parses a series path laid out by the template back into its tags and
+// field. tagKeys lists the tags of the measurement in point order, like for
+// Pattern. Tags stored as IginX tags are read from the {k=v,...} suffix. ok
+// is false if the path does not follow the template, or if it cannot tell
+// which tags are missing: {tags} leaves nil tags out, so a path with fewer
+// levels than tags is ambiguous.
+func (t *Template) Match(series string, tagKeys []string) (map[string]string, string, bool) {
+	path, iginxTags, err := SplitSeries(series)
+	if err != nil {
+		return nil, "", false
+	}
+	parts := strings.Split(path, ".")
+
+	var unnamed []string
+	if t.hasTags && !t.iginxTags {
+		for _, k := range tagKeys {
+			if !t.named[k] {
+				unnamed = append(unnamed, k)
+			}
+		}
+	}
+	fixed := len(t.segments)
+	if t.hasTags {
+		fixed--
+	}
+	if len(parts) != fixed+len(unnamed) {
+		return nil, "", false
+	}
+
+	tags := map[string]string{}
+	for k, v := range iginxTags {
+		tags[k] = v
+	}
+	var field string
+	i := 0
+	for _, s := range t.segments {
+		switch s.kind {
+		case segmentTags:
+			for _, k := range unnamed {
+				tags[k] = parts[i]
+				i++
+			}
+			continue
+		case segmentTag:
+			if parts[i] != Missing {
+				tags[s.name] = parts[i]
+			}
+		case segmentField:
+			field = parts[i]
+		case segmentLiteral:
+			if parts[i] != s.name {
+				return nil, "", false
+			}
+		}
+		i++
+	}
+	return tags, field, true
+}
+
+// SplitSeries splits a series written as path{key1=value1,...} into its path
+// and its tags, which are nil if there are none.
+func SplitSeries(series string) (string, map[string]string, error) {
+	open := strings.IndexByte(series, '{')
+	if open < 0 {
+		return series, nil, nil
+	}
+	if series[len(series)-1] != '}' {
+		return "", nil, fmt.Errorf("malformed tags in series %s", series)
+	}
+	tags := map[string]string{}
+	for _, kv := range strings.Split(series[open+1:len(series)-1], ",") {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 {
+			return "", nil, fmt.Errorf("malformed tag %s in series %s", kv, series)
+		}
+		tags[kv[:i]] = kv[i+1:]
+	}
+	return series[:open], tags, nil
+}
+
+func appendSeparator(buf []byte, first *bool) []byte {
+	if !*first {
+		buf = append(buf, '.')
+	}
+	*first = false
+	return buf
+}
+
+func appendNamedTag(buf []byte, key string, tags []Tag) []byte {
+	for _, tag := range tags {
+		if string(tag.Key) == key {
+			if tag.Value == nil {
+				break
+			}
+			return AppendTagValue(buf, tag.Value)
+		}
+	}
+	return append(buf, Missing...)
+}
+
+// AppendName appends a measurement or field name to an IginX path,
+// replacing the dashes IginX does not accept in paths.
+func AppendName(buf []byte, name []byte) []byte {
+	for _, c := range name {
+		if c == '-' {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+// AppendTagValue appends a tag value to an IginX path. Dots are replaced
+// too, so that a tag value never spans more than one level of the path.
+func AppendTagValue(buf []byte, value []byte) []byte {
+	for _, c := range value {
+		if c == '-' || c == '.' {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
@@ -0,0 +1,185 @@
+package pathtemplate
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testTags = []Tag{
+	{Key: []byte("hostname"), Value: []byte("host_0")},
+	{Key: []byte("region"), Value: []byte("eu-west-1")},
+	{Key: []byte("os"), Value: nil},
+	{Key: []byte("arch"), Value: []byte("x86.64")},
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		desc        string
+		template    string
+		shouldError bool
+	}{
+		{desc: "empty means default", template: ""},
+		{desc: "default", template: Default},
+		{desc: "named tags and literal", template: "root.{region}.{hostname}.{measurement}.{field}"},
+		{desc: "field only", template: "{field}"},
+		{desc: "field not last", template: "{measurement}.{field}.{hostname}", shouldError: true},
+		{desc: "no field", template: "{measurement}.{hostname}", shouldError: true},
+		{desc: "empty segment", template: "{measurement}..{field}", shouldError: true},
+		{desc: "empty placeholder", template: "{measurement}.{}.{field}", shouldError: true},
+		{desc: "malformed placeholder", template: "{measurement}.{hostname.{field}", shouldError: true},
+		{desc: "two tags", template: "{tags}.{measurement}.{tags}.{field}", shouldError: true},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.template)
+		if c.shouldError && err == nil {
+			t.Errorf("%s: expected error, got none", c.desc)
+		} else if !c.shouldError && err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+		}
+	}
+}
+
+func TestAppendPrefix(t *testing.T) {
+	cases := []struct {
+		template string
+		want     string
+	}{
+		{template: Default, want: "cpu_x.host_0.eu_west_1.x86_64.usage_user"},
+		{template: "{measurement}.{hostname}.{field}", want: "cpu_x.host_0.usage_user"},
+		{template: "{region}.{hostname}.{measurement}.{field}", want: "eu_west_1.host_0.cpu_x.usage_user"},
+		{template: "root.{hostname}.{tags}.{field}", want: "root.host_0.eu_west_1.x86_64.usage_user"},
+		{template: "{measurement}.{os}.{field}", want: "cpu_x." + Missing + ".usage_user"},
+		{template: "{measurement}.{rack}.{field}", want: "cpu_x." + Missing + ".usage_user"},
+		{template: "{field}", want: "usage_user"},
+	}
+	for _, c := range cases {
+		tmpl := MustParse(c.template)
+		prefix := tmpl.AppendPrefix(nil, []byte("cpu-x"), testTags)
+		if got := string(tmpl.AppendField(prefix, []byte("usage_user"))); got != c.want {
+			t.Errorf("%s: got %s want %s", c.template, got, c.want)
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	tags := []Tag{
+		{Key: []byte("hostname"), Value: []byte("host_0")},
+		{Key: []byte("region")},
+		{Key: []byte("arch")},
+	}
+	cases := []struct {
+		template string
+		want     string
+	}{
+		{template: Default, want: "cpu.host_0.*"},
+		{template: "{measurement}.{hostname}.{field}", want: "cpu.host_0"},
+		{template: "{region}.{hostname}.{measurement}.{field}", want: "*.host_0.cpu"},
+		{template: "{measurement}.{region}.{tags}.{field}", want: "cpu.*.host_0.*"},
+		{template: "{measurement}.{rack}.{field}", want: "cpu.*"},
+	}
+	for _, c := range cases {
+		if got := MustParse(c.template).Pattern("cpu", tags); got != c.want {
+			t.Errorf("%s: got %s want %s", c.template, got, c.want)
+		}
+	}
+}
+
+func TestIginxTags(t *testing.T) {
+	tmpl, err := ParseWithTagMode("{measurement}.{hostname}.{tags}.{field}", TagModeIginx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prefix := tmpl.AppendPrefix(nil, []byte("cpu"), testTags)
+	series := tmpl.AppendTags(tmpl.AppendField(prefix, []byte("usage_user")), testTags)
+	want := "cpu.host_0.usage_user{arch=x86_64,region=eu_west_1}"
+	if string(series) != want {
+		t.Errorf("incorrect series: got %s want %s", series, want)
+	}
+
+	path, tags, err := SplitSeries(string(series))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != "cpu.host_0.usage_user" || len(tags) != 2 || tags["arch"] != "x86_64" || tags["region"] != "eu_west_1" {
+		t.Errorf("incorrect split: got %s %v", path, tags)
+	}
+
+	if got := tmpl.Pattern("cpu", testTags[:2]); got != "cpu.host_0" {
+		t.Errorf("incorrect pattern: got %s", got)
+	}
+
+	pathOnly := MustParse(Default)
+	if got := pathOnly.AppendTags(nil, testTags); len(got) != 0 {
+		t.Errorf("expected no IginX tags in path tag mode, got %s", got)
+	}
+	if _, err := ParseWithTagMode(Default, "bogus"); err == nil {
+		t.Errorf("expected error for unknown tag mode")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	keys := []string{"hostname", "region", "arch"}
+	cases := []struct {
+		template string
+		tagMode  string
+		series   string
+		wantTags map[string]string
+		wantOK   bool
+	}{
+		{
+			template: Default,
+			series:   "cpu.host_0.eu_west_1.x86_64.usage_user",
+			wantTags: map[string]string{"hostname": "host_0", "region": "eu_west_1", "arch": "x86_64"},
+			wantOK:   true,
+		},
+		{
+			template: Default,
+			series:   "cpu.host_0.x86_64.usage_user",
+		},
+		{
+			template: "tsbs.{region}.{measurement}.{tags}.{field}",
+			series:   "tsbs.null.cpu.host_0.x86_64.usage_user",
+			wantTags: map[string]string{"hostname": "host_0", "arch": "x86_64"},
+			wantOK:   true,
+		},
+		{
+			template: "tsbs.{region}.{measurement}.{tags}.{field}",
+			series:   "other.null.cpu.host_0.x86_64.usage_user",
+		},
+		{
+			template: "{measurement}.{hostname}.{field}",
+			tagMode:  TagModeIginx,
+			series:   "cpu.host_0.usage_user{arch=x86_64,region=eu_west_1}",
+			wantTags: map[string]string{"hostname": "host_0", "region": "eu_west_1", "arch": "x86_64"},
+			wantOK:   true,
+		},
+	}
+	for _, c := range cases {
+		tmpl, err := ParseWithTagMode(c.template, c.tagMode)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.template, err)
+		}
+		tags, field, ok := tmpl.Match(c.series, keys)
+		if ok != c.wantOK {
+			t.Errorf("%s: %s: got ok %v want %v", c.template, c.series, ok, c.wantOK)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if field != "usage_user" {
+			t.Errorf("%s: %s: incorrect field %s", c.template, c.series, field)
+		}
+		if !reflect.DeepEqual(tags, c.wantTags) {
+			t.Errorf("%s: %s: incorrect tags: got %v want %v", c.template, c.series, tags, c.wantTags)
+		}
+	}
+}
+
+func TestSplitSeriesErrors(t *testing.T) {
+	for _, s := range []string{"cpu.usage_user{hostname=host_0", "cpu.usage_user{hostname}", "cpu.usage_user{=host_0}"} {
+		if _, _, err := SplitSeries(s); err == nil {
+			t.Errorf("%s: expected error, got none", s)
+		}
+	}
+}
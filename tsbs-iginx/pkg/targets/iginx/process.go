@@ -0,0 +1,171 @@
+package iginx
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+// allows for testing
+var sleepFn = time.Sleep
+
+type processor struct {
+	conf       *SpecificConfig
+	format     *dataFormat
+	bufPool    *sync.Pool
+	deadLetter *deadLetterFile
+	sessions   *SessionGroup
+	http       *httpWriter
+	insertAPI  insertAPI
+	retry      *retryPolicy
+
+	retries       uint64
+	failedBatches uint64
+	failedMetrics uint64
+}
+
+func (p *processor) Init(numWorker int, _, _ bool) {
+	p.retry = newRetryPolicy(p.conf, numWorker)
+	if p.deadLetter != nil {
+		p.deadLetter.acquire()
+	}
+	if p.conf.WriteProtocol == WriteProtocolHTTP {
+		p.http = newHTTPWriter(p.conf)
+		return
+	}
+	api, err := getInsertAPI(p.conf.InsertAPI)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.insertAPI = api
+	sessions, err := p.conf.NewSessionGroup(numWorker)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.sessions = sessions
+}
+
+func (p *processor) Close(_ bool) {
+	if p.sessions != nil {
+		p.sessions.Close()
+	}
+	if p.deadLetter != nil {
+		if err := p.deadLetter.release(); err != nil {
+			log.Printf("could not close dead letter file %s: %v", p.deadLetter.path, err)
+		}
+	}
+}
+
+// Totals reports how often this worker retried an insert, how many batches
+// and metrics it gave up on and how many times it had to fail over.
+func (p *processor) Totals() map[string]uint64 {
+	var failovers uint64
+	if p.sessions != nil {
+		failovers = p.sessions.Failovers()
+	}
+	return map[string]uint64{
+		"retries":       p.retries,
+		"failedBatches": p.failedBatches,
+		"failedMetrics": p.failedMetrics,
+		"failovers":     failovers,
+	}
+}
+
+func (p *processor) ProcessBatch(b targets.Batch, doLoad bool) (uint64, uint64) {
+	batch := b.(*batch)
+
+	// Write the batch: try until backoff is not needed.
+	if !doLoad {
+		return 0, 0
+	}
+
+	metricCnt := batch.metrics
+	rowCnt := uint64(batch.rows)
+	var err error
+	if p.http != nil {
+		err = p.withRetries(func() error {
+			return p.http.write(batch.buf.Bytes())
+		})
+	} else {
+		err = p.insertBatch(batch)
+	}
+	if err != nil {
+		p.giveUp(batch, err)
+		metricCnt, rowCnt = 0, 0
+	}
+
+	// Return the batch buffer to the pool.
+	batch.buf.Reset()
+	p.bufPool.Put(batch.buf)
+	return metricCnt, rowCnt
+}
+
+// insertBatch decodes the lines of a batch, lays them out for the insert API
+// and inserts them over a session, failing over to another node if the
+// session drops. A line that cannot be decoded fails the whole batch.
+func (p *processor) insertBatch(batch *batch) error {
+	cols := newColumnBuilder()
+	buf := batch.buf.Bytes()
+	for len(buf) > 0 {
+		line := buf
+		if end := bytes.IndexByte(buf, '\n'); end >= 0 {
+			line, buf = buf[:end], buf[end+1:]
+		} else {
+			buf = nil
+		}
+		if err := p.format.decode(cols, line); err != nil {
+			return fmt.Errorf("could not decode line '%s': %v", line, err)
+		}
+	}
+	records := cols.Build()
+	values := p.insertAPI.values(records)
+
+	return p.withRetries(func() error {
+		session, err := p.sessions.Session()
+		if err != nil {
+			return err
+		}
+		err = p.insertAPI.insert(session, records.paths, records.timestamps, values, records.types, records.tags)
+		if err != nil && IsConnectionError(err) {
+			_, _ = p.sessions.Failover(session)
+		}
+		return err
+	})
+}
+
+// withRetries calls attempt until it succeeds, fails with an error that is
+// not worth retrying, see isRetryable, or the retry policy runs out of
+// attempts, in which case the last error is returned.
+func (p *processor) withRetries(attempt func() error) error {
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(err) || n >= p.retry.maxAttempts {
+			return err
+		}
+		p.retries++
+		delay := p.retry.delay(n)
+		log.Printf("insert failed (attempt %d of %d), retrying in %v: %v", n, p.retry.maxAttempts, delay, err)
+		sleepFn(delay)
+	}
+}
+
+// giveUp counts a batch that could not be inserted and writes it to the dead
+// letter file, if there is one.
+func (p *processor) giveUp(batch *batch, err error) {
+	p.failedBatches++
+	p.failedMetrics += batch.metrics
+	log.Printf("giving up on a batch of %d rows: %v", batch.rows, err)
+	if p.deadLetter == nil {
+		return
+	}
+	if err := p.deadLetter.write(batch.buf.Bytes()); err != nil {
+		log.Fatalf("could not write to dead letter file %s: %v", p.deadLetter.path, err)
+	}
+}
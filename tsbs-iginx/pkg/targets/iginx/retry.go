@@ -0,0 +1,139 @@
+package iginx
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// retryPolicy decides how often and after how long a failed insert is tried
+// again: the n-th retry waits backoff * 2^(n-1), capped at maxBackoff, give or
+// take a random fraction jitter of that. A zero maxBackoff means no cap.
+type retryPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	jitter      float64
+	rand        *rand.Rand
+}
+
+func newRetryPolicy(conf *SpecificConfig, workerNum int) *retryPolicy {
+	return &retryPolicy{
+		maxAttempts: conf.MaxAttempts,
+		backoff:     conf.RetryBackoff,
+		maxBackoff:  conf.MaxRetryBackoff,
+		jitter:      conf.RetryJitter,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano() + int64(workerNum))),
+	}
+}
+
+// validateRetryPolicy checks the retry settings of conf.
+func validateRetryPolicy(conf *SpecificConfig) error {
+	if conf.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be at least 1, got %d", conf.MaxAttempts)
+	}
+	if conf.RetryBackoff < 0 || conf.MaxRetryBackoff < 0 {
+		return fmt.Errorf("retry backoff cannot be negative")
+	}
+	if conf.RetryJitter < 0 || conf.RetryJitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1, got %g", conf.RetryJitter)
+	}
+	return nil
+}
+
+// delay returns how long to wait before the given retry, counting from 1.
+func (p *retryPolicy) delay(retry int) time.Duration {
+	d := p.backoff
+	for i := 1; i < retry && (p.maxBackoff == 0 || d < p.maxBackoff); i++ {
+		d *= 2
+	}
+	if p.maxBackoff > 0 && d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	if p.jitter > 0 {
+		d += time.Duration(float64(d) * p.jitter * (2*p.rand.Float64() - 1))
+	}
+	return d
+}
+
+// isRetryable reports whether a failed insert may succeed if tried again:
+// the connection to IginX failed or timed out, or the line protocol end point
+// answered with a 5xx status. IginX rejecting the data, with a status error
+// or a 4xx response, fails the same way every time.
+func isRetryable(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status >= 500
+	}
+	var timeoutErr interface{ Timeout() bool }
+	if errors.As(err, &timeoutErr) && timeoutErr.Timeout() {
+		return true
+	}
+	return IsConnectionError(err) ||
+		errors.Is(err, errNoHealthyEndpoint) ||
+		errors.Is(err, fasthttp.ErrConnectionClosed) ||
+		errors.Is(err, fasthttp.ErrNoFreeConns) ||
+		errors.Is(err, fasthttp.ErrDialTimeout)
+}
+
+// deadLetterFile collects the batches that could not be inserted, in the
+// format they were read in, so that they can be loaded again later. The
+// file is shared by all workers, only created once a batch fails and closed
+// once the last worker using it releases it.
+type deadLetterFile struct {
+	path    string
+	mu      sync.Mutex
+	file    *os.File
+	created bool
+	users   int
+}
+
+// acquire registers a worker that may write to the file.
+func (d *deadLetterFile) acquire() {
+	d.mu.Lock()
+	d.users++
+	d.mu.Unlock()
+}
+
+// release unregisters a worker, syncing and closing the file if it was the
+// last one. A later write opens the file again and appends to it.
+func (d *deadLetterFile) release() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.users > 0 {
+		d.users--
+	}
+	if d.users > 0 || d.file == nil {
+		return nil
+	}
+	err := d.file.Sync()
+	if closeErr := d.file.Close(); err == nil {
+		err = closeErr
+	}
+	d.file = nil
+	return err
+}
+
+func (d *deadLetterFile) write(batch []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.file == nil {
+		// the file of a previous run is truncated, not appended to
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if d.created {
+			flags = os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(d.path, flags, 0644)
+		if err != nil {
+			return err
+		}
+		d.file, d.created = f, true
+	}
+	_, err := d.file.Write(batch)
+	return err
+}
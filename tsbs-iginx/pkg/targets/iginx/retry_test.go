@@ -0,0 +1,203 @@
+package iginx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/valyala/fasthttp"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := &retryPolicy{backoff: 100 * time.Millisecond, maxBackoff: time.Second}
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, w := range want {
+		if got := p.delay(i + 1); got != w {
+			t.Errorf("incorrect delay of retry %d: got %v want %v", i+1, got, w)
+		}
+	}
+
+	p.jitter = 0.5
+	p.rand = rand.New(rand.NewSource(1))
+	for retry := 1; retry < 10; retry++ {
+		base := p.backoff << uint(retry-1)
+		if base > p.maxBackoff {
+			base = p.maxBackoff
+		}
+		if got := p.delay(retry); got < base/2 || got > base*3/2 {
+			t.Errorf("delay of retry %d out of jitter range: got %v base %v", retry, got, base)
+		}
+	}
+}
+
+func TestValidateRetryPolicy(t *testing.T) {
+	cases := []struct {
+		desc       string
+		conf       SpecificConfig
+		shouldFail bool
+	}{
+		{desc: "valid", conf: SpecificConfig{MaxAttempts: 3, RetryBackoff: time.Second, RetryJitter: 0.2}},
+		{desc: "no attempts", conf: SpecificConfig{MaxAttempts: 0}, shouldFail: true},
+		{desc: "negative backoff", conf: SpecificConfig{MaxAttempts: 1, RetryBackoff: -time.Second}, shouldFail: true},
+		{desc: "jitter above 1", conf: SpecificConfig{MaxAttempts: 1, RetryJitter: 1.5}, shouldFail: true},
+	}
+	for _, c := range cases {
+		err := validateRetryPolicy(&c.conf)
+		if c.shouldFail && err == nil {
+			t.Errorf("%s: expected error", c.desc)
+		} else if !c.shouldFail && err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+		}
+	}
+}
+
+func TestWithRetries(t *testing.T) {
+	oldSleep := sleepFn
+	defer func() { sleepFn = oldSleep }()
+	var slept []time.Duration
+	sleepFn = func(d time.Duration) { slept = append(slept, d) }
+
+	p := &processor{retry: &retryPolicy{maxAttempts: 3, backoff: time.Millisecond, maxBackoff: time.Second}}
+	calls := 0
+	err := p.withRetries(func() error {
+		calls++
+		if calls < 3 {
+			return &httpStatusError{status: 503, body: "busy"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if p.retries != 2 || len(slept) != 2 || slept[1] != 2*time.Millisecond {
+		t.Errorf("incorrect retries: got %d retries, slept %v", p.retries, slept)
+	}
+
+	calls = 0
+	err = p.withRetries(func() error {
+		calls++
+		return &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	})
+	if err == nil || calls != 3 {
+		t.Errorf("expected an error after 3 attempts, got %v after %d", err, calls)
+	}
+
+	calls = 0
+	err = p.withRetries(func() error {
+		calls++
+		return errors.New("error executing insert: status code 500")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("expected a permanent error after 1 attempt, got %v after %d", err, calls)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{desc: "connection refused", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: true},
+		{desc: "thrift transport", err: thrift.NewTTransportException(thrift.TIMED_OUT, "timed out"), want: true},
+		{desc: "no healthy endpoint", err: errNoHealthyEndpoint, want: true},
+		{desc: "http timeout", err: fasthttp.ErrTimeout, want: true},
+		{desc: "http connection closed", err: fasthttp.ErrConnectionClosed, want: true},
+		{desc: "wrapped", err: fmt.Errorf("insert: %w", fasthttp.ErrNoFreeConns), want: true},
+		{desc: "5xx", err: &httpStatusError{status: 503}, want: true},
+		{desc: "4xx", err: &httpStatusError{status: 400}, want: false},
+		{desc: "iginx status", err: errors.New("error executing insert: status code 500"), want: false},
+	}
+	for _, c := range cases {
+		if got := isRetryable(c.err); got != c.want {
+			t.Errorf("%s: got %v want %v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestDecodeErrorGivesUp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iginx-dead-letter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	format, err := getDataFormat(DataFormatInflux, defaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "failed.txt")
+	p := &processor{
+		format:     format,
+		bufPool:    &sync.Pool{New: func() interface{} { return new(bytes.Buffer) }},
+		retry:      &retryPolicy{maxAttempts: 3},
+		deadLetter: &deadLetterFile{path: path},
+	}
+	lines := "cpu,hostname=h0 usage_user=1 1451606400000000000\ncpu,hostname=h0 usage_user 1451606400000000000\n"
+	metrics, rows := p.ProcessBatch(&batch{buf: bytes.NewBufferString(lines), rows: 2, metrics: 2}, true)
+	if metrics != 0 || rows != 0 || p.failedBatches != 1 || p.retries != 0 {
+		t.Errorf("incorrect counts: got %d metrics, %d rows, %d failed batches, %d retries", metrics, rows, p.failedBatches, p.retries)
+	}
+	if got, _ := ioutil.ReadFile(path); string(got) != lines {
+		t.Errorf("incorrect dead letter file: got %q want %q", got, lines)
+	}
+}
+
+func TestGiveUpDeadLetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iginx-dead-letter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "failed.txt")
+	if err := ioutil.WriteFile(path, []byte("left by a previous run\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadLetter := &deadLetterFile{path: path}
+	p := &processor{
+		retry:      &retryPolicy{maxAttempts: 1},
+		deadLetter: deadLetter,
+	}
+	other := &processor{retry: p.retry, deadLetter: deadLetter}
+	deadLetter.acquire()
+	deadLetter.acquire()
+	lines := []string{"cpu,hostname=h0 usage_user=1 1451606400000000000\n", "cpu,hostname=h1 usage_user=2 1451606400000000000\n"}
+	for _, l := range lines {
+		p.giveUp(&batch{buf: bytes.NewBufferString(l), rows: 1, metrics: 1}, errors.New("down"))
+	}
+	if p.failedBatches != 2 || p.failedMetrics != 2 {
+		t.Errorf("incorrect counts: got %d batches, %d metrics", p.failedBatches, p.failedMetrics)
+	}
+	p.Close(true)
+	if deadLetter.file == nil {
+		t.Errorf("dead letter file closed while another worker uses it")
+	}
+	other.Close(true)
+	if deadLetter.file != nil {
+		t.Errorf("dead letter file not closed once all workers are done")
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := lines[0] + lines[1]; string(got) != want {
+		t.Errorf("incorrect dead letter file: got %q want %q", got, want)
+	}
+}
@@ -1,9 +1,10 @@
-package main
+package iginx
 
 import (
 	"bufio"
 	"bytes"
-	"strings"
+	"log"
+	"sync"
 
 	"github.com/timescale/tsbs/pkg/data"
 	"github.com/timescale/tsbs/pkg/data/usecases/common"
@@ -14,6 +15,9 @@ const errNotThreeTuplesFmt = "parse error: line does not have 3 tuples, has %d"
 
 var newLine = []byte("\n")
 
+// allows for testing
+var fatal = log.Fatalf
+
 type fileDataSource struct {
 	scanner *bufio.Scanner
 }
@@ -32,6 +36,7 @@ func (d *fileDataSource) NextItem() data.LoadedPoint {
 func (d *fileDataSource) Headers() *common.GeneratedDataHeaders { return nil }
 
 type batch struct {
+	format  *dataFormat
 	buf     *bytes.Buffer
 	rows    uint
 	metrics uint64
@@ -43,23 +48,23 @@ func (b *batch) Len() uint {
 
 func (b *batch) Append(item data.LoadedPoint) {
 	that := item.Data.([]byte)
-	thatStr := string(that)
 	b.rows++
-	// Each influx line is format "csv-tags csv-fields timestamp", so we split by space
-	// and then on the middle element, we split by comma to count number of fields added
-	args := strings.Split(thatStr, " ")
-	if len(args) != 3 {
-		fatal(errNotThreeTuplesFmt, len(args))
+	metrics, err := b.format.countMetrics(that)
+	if err != nil {
+		fatal("%v", err)
 		return
 	}
-	b.metrics += uint64(len(strings.Split(args[1], ",")))
+	b.metrics += metrics
 
 	b.buf.Write(that)
 	b.buf.Write(newLine)
 }
 
-type factory struct{}
+type factory struct {
+	format  *dataFormat
+	bufPool *sync.Pool
+}
 
 func (f *factory) New() targets.Batch {
-	return &batch{buf: bufPool.Get().(*bytes.Buffer)}
+	return &batch{format: f.format, buf: f.bufPool.Get().(*bytes.Buffer)}
 }
@@ -0,0 +1,101 @@
+package iginx
+
+import (
+	"bufio"
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/timescale/tsbs/pkg/data"
+)
+
+func newTestFactory(dataFormat string) *factory {
+	format, err := getDataFormat(dataFormat, defaultTemplate)
+	if err != nil {
+		panic(err)
+	}
+	return &factory{format: format, bufPool: &sync.Pool{
+		New: func() interface{} {
+			return bytes.NewBuffer(make([]byte, 0, 1024))
+		},
+	}}
+}
+
+func TestBatch(t *testing.T) {
+	b := newTestFactory(DataFormatInflux).New().(*batch)
+	if b.Len() != 0 {
+		t.Errorf("batch not initialized with count 0")
+	}
+	p := data.LoadedPoint{
+		Data: []byte("cpu,hostname=host_0 usage_user=1,usage_system=2 1451606400000000000"),
+	}
+	b.Append(p)
+	if b.Len() != 1 {
+		t.Errorf("batch count is not 1 after first append")
+	}
+	if b.metrics != 2 {
+		t.Errorf("batch metric count is not 2 after first append")
+	}
+
+	p = data.LoadedPoint{
+		Data: []byte("cpu,hostname=host_1 usage_user=3,usage_system=4,usage_idle=5 1451606400000000000"),
+	}
+	b.Append(p)
+	if b.Len() != 2 {
+		t.Errorf("batch count is not 2 after second append")
+	}
+	if b.metrics != 5 {
+		t.Errorf("batch metric count is not 5 after second append")
+	}
+}
+
+func TestBatchAppendFatal(t *testing.T) {
+	oldFatal := fatal
+	defer func() { fatal = oldFatal }()
+	isCalled := false
+	fatal = func(format string, args ...interface{}) {
+		isCalled = true
+	}
+
+	b := newTestFactory(DataFormatInflux).New().(*batch)
+	b.Append(data.LoadedPoint{Data: []byte("cpu,hostname=host_0 usage_user=1")})
+	if !isCalled {
+		t.Errorf("fatal was not called for line without timestamp")
+	}
+
+	isCalled = false
+	b = newTestFactory(DataFormatNative).New().(*batch)
+	b.Append(data.LoadedPoint{Data: []byte("1451606400000\tcpu.host_0.usage_user")})
+	if !isCalled {
+		t.Errorf("fatal was not called for native line without value")
+	}
+}
+
+func TestBatchNative(t *testing.T) {
+	b := newTestFactory(DataFormatNative).New().(*batch)
+	b.Append(data.LoadedPoint{Data: []byte("1451606400000\tcpu.host_0.usage_user\tl1\tcpu.host_0.usage_system\td2.5")})
+	if b.Len() != 1 {
+		t.Errorf("batch count is not 1 after first append")
+	}
+	if b.metrics != 2 {
+		t.Errorf("batch metric count is not 2 after first append")
+	}
+}
+
+func TestFileDataSource(t *testing.T) {
+	input := "cpu,hostname=host_0 usage_user=1 140\ncpu,hostname=host_1 usage_user=2 140\n"
+	ds := &fileDataSource{scanner: bufio.NewScanner(bytes.NewReader([]byte(input)))}
+	want := []string{
+		"cpu,hostname=host_0 usage_user=1 140",
+		"cpu,hostname=host_1 usage_user=2 140",
+	}
+	for _, w := range want {
+		p := ds.NextItem()
+		if got := string(p.Data.([]byte)); got != w {
+			t.Errorf("incorrect line: got %s want %s", got, w)
+		}
+	}
+	if p := ds.NextItem(); p.Data != nil {
+		t.Errorf("expected p.Data to be nil at EOF, got %v", p.Data)
+	}
+}
@@ -1,12 +1,15 @@
 package iginx
 
 import (
+	"io"
+	"math"
+	"strconv"
+
 	"github.com/timescale/tsbs/pkg/data"
 	"github.com/timescale/tsbs/pkg/data/serialize"
-	"io"
 )
 
-// Serializer writes a Point in a serialized form for MongoDB
+// Serializer writes a Point in a serialized form for IginX
 type Serializer struct{}
 
 // Serialize writes Point data to the given writer, conforming to the
@@ -83,9 +86,70 @@ func appendField(buf, key []byte, v interface{}) []byte {
 	buf = append(buf, key...)
 	buf = append(buf, '=')
 
-	buf = serialize.FastFormatAppend(v, buf)
+	if b, ok := appendInteger(buf, v); ok {
+		// Influx uses 'i' to indicate integers:
+		return append(b, 'i')
+	}
+	switch x := v.(type) {
+	case uint:
+		// too large for an integer, written as a float instead
+		buf = strconv.AppendFloat(buf, float64(x), 'f', -1, 64)
+	case uint64:
+		buf = strconv.AppendFloat(buf, float64(x), 'f', -1, 64)
+	case string:
+		buf = appendInfluxString(buf, x)
+	case []byte:
+		buf = appendInfluxString(buf, string(x))
+	default:
+		buf = serialize.FastFormatAppend(v, buf)
+	}
+	return buf
+}
 
-	// Influx uses 'i' to indicate integers:
+// appendInteger appends v if it is of any integer kind that fits in a LONG,
+// which IginX loads integers as, and reports whether it was. Unsigned values
+// above math.MaxInt64 are left to the caller.
+func appendInteger(buf []byte, v interface{}) ([]byte, bool) {
+	switch x := v.(type) {
+	case int:
+		return strconv.AppendInt(buf, int64(x), 10), true
+	case int8:
+		return strconv.AppendInt(buf, int64(x), 10), true
+	case int16:
+		return strconv.AppendInt(buf, int64(x), 10), true
+	case int32:
+		return strconv.AppendInt(buf, int64(x), 10), true
+	case int64:
+		return strconv.AppendInt(buf, x, 10), true
+	case uint:
+		if uint64(x) > math.MaxInt64 {
+			break
+		}
+		return strconv.AppendUint(buf, uint64(x), 10), true
+	case uint8:
+		return strconv.AppendUint(buf, uint64(x), 10), true
+	case uint16:
+		return strconv.AppendUint(buf, uint64(x), 10), true
+	case uint32:
+		return strconv.AppendUint(buf, uint64(x), 10), true
+	case uint64:
+		if x > math.MaxInt64 {
+			break
+		}
+		return strconv.AppendUint(buf, x, 10), true
+	}
+	return buf, false
+}
 
-	return buf
+// appendInfluxString writes a string field double quoted, so that the loader
+// does not mistake it for a number or a boolean.
+func appendInfluxString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			buf = append(buf, '\\')
+		}
+		buf = append(buf, s[i])
+	}
+	return append(buf, '"')
 }
@@ -0,0 +1,86 @@
+package iginx
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/serialize"
+)
+
+func testPointWithString() *data.Point {
+	now := time.Unix(1451606400, 0)
+	p := data.NewPoint()
+	p.SetMeasurementName([]byte("readings"))
+	p.SetTimestamp(&now)
+	p.AppendTag([]byte("name"), "truck_0")
+	p.AppendTag([]byte("load_capacity"), 1500.0)
+	p.AppendField([]byte("driver"), "Trish \"T\"")
+	p.AppendField([]byte("moving"), true)
+	return p
+}
+
+func testPointWithIntegers() *data.Point {
+	now := time.Unix(1451606400, 0)
+	p := data.NewPoint()
+	p.SetMeasurementName([]byte("diagnostics"))
+	p.SetTimestamp(&now)
+	p.AppendTag([]byte("name"), "truck_0")
+	p.AppendField([]byte("status"), int32(3))
+	p.AppendField([]byte("load"), uint(1500))
+	p.AppendField([]byte("fuel"), int8(-1))
+	p.AppendField([]byte("odometer"), uint64(123456789))
+	return p
+}
+
+func testPointWithBigUnsigned() *data.Point {
+	now := time.Unix(1451606400, 0)
+	p := data.NewPoint()
+	p.SetMeasurementName([]byte("diagnostics"))
+	p.SetTimestamp(&now)
+	p.AppendField([]byte("odometer"), uint64(math.MaxInt64)+1)
+	return p
+}
+
+func TestSerializerSerialize(t *testing.T) {
+	cases := []serialize.SerializeCase{
+		{
+			Desc:       "a regular Point",
+			InputPoint: serialize.TestPointDefault(),
+			Output:     "cpu,hostname=host_0,region=eu-west-1,datacenter=eu-west-1b usage_guest_nice=38.24311829 1451606400000000000\n",
+		},
+		{
+			Desc:       "a regular Point using int as value",
+			InputPoint: serialize.TestPointInt(),
+			Output:     "cpu,hostname=host_0,region=eu-west-1,datacenter=eu-west-1b usage_guest=38i 1451606400000000000\n",
+		},
+		{
+			Desc:       "a regular Point with multiple fields",
+			InputPoint: serialize.TestPointMultiField(),
+			Output:     "cpu,hostname=host_0,region=eu-west-1,datacenter=eu-west-1b big_usage_guest=5000000000i,usage_guest=38i,usage_guest_nice=38.24311829 1451606400000000000\n",
+		},
+		{
+			Desc:       "a Point with string and bool fields",
+			InputPoint: testPointWithString(),
+			Output:     "readings,name=truck_0 load_capacity=1500,driver=\"Trish \\\"T\\\"\",moving=true 1451606400000000000\n",
+		},
+		{
+			Desc:       "a Point with int32 and other integer fields",
+			InputPoint: testPointWithIntegers(),
+			Output:     "diagnostics,name=truck_0 status=3i,load=1500i,fuel=-1i,odometer=123456789i 1451606400000000000\n",
+		},
+		{
+			Desc:       "a Point with a uint64 field above MaxInt64",
+			InputPoint: testPointWithBigUnsigned(),
+			Output:     "diagnostics odometer=9223372036854776000 1451606400000000000\n",
+		},
+		{
+			Desc:       "a Point with a nil field",
+			InputPoint: serialize.TestPointWithNilField(),
+			Output:     "cpu usage_guest_nice=38.24311829 1451606400000000000\n",
+		},
+	}
+
+	serialize.SerializerTest(t, cases, &Serializer{})
+}
@@ -0,0 +1,217 @@
+package iginx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/thulab/iginx-client-go/client"
+)
+
+var errNoHealthyEndpoint = errors.New("no healthy IginX endpoint left")
+
+type sessionOpener func(e Endpoint) (*client.Session, error)
+
+// SessionGroup holds the sessions a single worker uses to talk to IginX and
+// picks one of them for each request according to the session assignment.
+// When a session drops, Failover marks its endpoint as dead and moves the
+// worker to the next healthy one. A dead endpoint is tried again once
+// reconnectAfter has passed, if set. A SessionGroup is not safe for
+// concurrent use.
+type SessionGroup struct {
+	assignment     string
+	endpoints      []Endpoint
+	sessions       []*client.Session
+	dead           []bool
+	deadSince      []time.Time
+	reconnectAfter time.Duration
+	current        int
+	rand           *rand.Rand
+	open           sessionOpener
+	// idleSince is when a pooled group was last handed back, see SessionPool
+	idleSince time.Time
+
+	failovers uint64
+}
+
+// NewSessionGroup opens the sessions worker workerNum should use.
+func (c *SpecificConfig) NewSessionGroup(workerNum int) (*SessionGroup, error) {
+	endpoints, err := c.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+	g, err := newSessionGroup(c.SessionAssignment, endpoints, workerNum, c.openSession)
+	if err != nil {
+		return nil, err
+	}
+	g.reconnectAfter = c.ReconnectInterval
+	return g, nil
+}
+
+func (c *SpecificConfig) openSession(e Endpoint) (*client.Session, error) {
+	s := c.NewSession(e)
+	if err := s.Open(); err != nil {
+		return nil, fmt.Errorf("could not open session to %s: %v", e, err)
+	}
+	return s, nil
+}
+
+// newLazySessionGroup returns a group that opens its sessions on first use.
+func newLazySessionGroup(assignment string, endpoints []Endpoint, workerNum int, open sessionOpener) *SessionGroup {
+	if assignment == "" {
+		assignment = SessionAssignmentPerWorker
+	}
+	return &SessionGroup{
+		assignment: assignment,
+		endpoints:  endpoints,
+		sessions:   make([]*client.Session, len(endpoints)),
+		dead:       make([]bool, len(endpoints)),
+		deadSince:  make([]time.Time, len(endpoints)),
+		// start each worker on a different host so that workers
+		// do not all hit the first host at once
+		current: workerNum % len(endpoints),
+		rand:    rand.New(rand.NewSource(int64(workerNum))),
+		open:    open,
+	}
+}
+
+func newSessionGroup(assignment string, endpoints []Endpoint, workerNum int, open sessionOpener) (*SessionGroup, error) {
+	g := newLazySessionGroup(assignment, endpoints, workerNum, open)
+
+	if g.assignment == SessionAssignmentPerWorker {
+		if _, err := g.openCurrent(); err != nil {
+			return nil, err
+		}
+		return g, nil
+	}
+
+	for i, e := range endpoints {
+		s, err := open(e)
+		if err != nil {
+			g.Close()
+			return nil, err
+		}
+		g.sessions[i] = s
+	}
+	return g, nil
+}
+
+// openCurrent makes sure the session at the current position is open, moving
+// on to the next endpoint whenever opening fails.
+func (g *SessionGroup) openCurrent() (*client.Session, error) {
+	for tries := 0; tries < len(g.endpoints); tries++ {
+		if g.dead[g.current] && g.reconnectAfter > 0 && time.Since(g.deadSince[g.current]) >= g.reconnectAfter {
+			g.dead[g.current] = false
+		}
+		if !g.dead[g.current] {
+			if g.sessions[g.current] != nil {
+				return g.sessions[g.current], nil
+			}
+			s, err := g.open(g.endpoints[g.current])
+			if err == nil {
+				g.sessions[g.current] = s
+				return s, nil
+			}
+			log.Println(err)
+			g.markDead(g.current)
+		}
+		g.current = (g.current + 1) % len(g.endpoints)
+	}
+	return nil, errNoHealthyEndpoint
+}
+
+// Session returns the session to use for the next request.
+func (g *SessionGroup) Session() (*client.Session, error) {
+	switch g.assignment {
+	case SessionAssignmentRoundRobin:
+		s, err := g.openCurrent()
+		g.current = (g.current + 1) % len(g.endpoints)
+		return s, err
+	case SessionAssignmentRandom:
+		g.current = g.rand.Intn(len(g.endpoints))
+		return g.openCurrent()
+	default:
+		return g.openCurrent()
+	}
+}
+
+// Failover closes a session that dropped and returns a session to the next
+// healthy endpoint.
+func (g *SessionGroup) Failover(broken *client.Session) (*client.Session, error) {
+	for i, s := range g.sessions {
+		if s == broken && s != nil {
+			log.Printf("IginX session to %s dropped, failing over", g.endpoints[i])
+			_ = s.Close()
+			g.sessions[i] = nil
+			g.markDead(i)
+		}
+	}
+	g.failovers++
+	return g.openCurrent()
+}
+
+func (g *SessionGroup) markDead(i int) {
+	g.dead[i] = true
+	g.deadSince[i] = time.Now()
+}
+
+// Detach forgets a session left in an unknown state, e.g. by a request that
+// timed out, without marking its endpoint as dead. The next request to that
+// endpoint opens a new session. Closing the detached session is left to the
+// caller, once the request is over.
+func (g *SessionGroup) Detach(s *client.Session) {
+	for i := range g.sessions {
+		if g.sessions[i] == s {
+			g.sessions[i] = nil
+		}
+	}
+}
+
+// HealthCheck makes a cheap request on the session to use next and fails
+// over if the session turns out to have dropped.
+func (g *SessionGroup) HealthCheck() error {
+	s, err := g.openCurrent()
+	if err != nil {
+		return err
+	}
+	if _, err := s.GetReplicaNum(); err != nil {
+		if !IsConnectionError(err) {
+			return err
+		}
+		_, err = g.Failover(s)
+		return err
+	}
+	return nil
+}
+
+// Failovers returns the number of times this group had to fail over.
+func (g *SessionGroup) Failovers() uint64 {
+	return g.failovers
+}
+
+// Close closes all open sessions.
+func (g *SessionGroup) Close() {
+	for i, s := range g.sessions {
+		if s != nil {
+			_ = s.Close()
+			g.sessions[i] = nil
+		}
+	}
+}
+
+// IsConnectionError reports whether err means the session to IginX was lost,
+// as opposed to IginX rejecting the request. A done context is not a lost
+// session, even though context.DeadlineExceeded is a net.Error.
+func IsConnectionError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var transportErr thrift.TTransportException
+	var netErr net.Error
+	return errors.As(err, &transportErr) || errors.As(err, &netErr)
+}
@@ -0,0 +1,208 @@
+package iginx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/thulab/iginx-client-go/client"
+)
+
+var testEndpoints = []Endpoint{
+	{Host: "h0", Port: "6888"},
+	{Host: "h1", Port: "6888"},
+	{Host: "h2", Port: "6888"},
+}
+
+// fakeOpener hands out unopened sessions and remembers which endpoint each
+// session belongs to. Endpoints listed in down cannot be opened.
+type fakeOpener struct {
+	hosts map[*client.Session]string
+	down  map[string]bool
+}
+
+func newFakeOpener(down ...string) *fakeOpener {
+	f := &fakeOpener{hosts: map[*client.Session]string{}, down: map[string]bool{}}
+	for _, h := range down {
+		f.down[h] = true
+	}
+	return f
+}
+
+func (f *fakeOpener) open(e Endpoint) (*client.Session, error) {
+	if f.down[e.Host] {
+		return nil, fmt.Errorf("%s is down", e.Host)
+	}
+	s := client.NewSession(e.Host, e.Port, "root", "root")
+	f.hosts[s] = e.Host
+	return s, nil
+}
+
+func nextHost(t *testing.T, g *SessionGroup, f *fakeOpener) string {
+	s, err := g.Session()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return f.hosts[s]
+}
+
+func TestSessionGroupPerWorker(t *testing.T) {
+	f := newFakeOpener()
+	g, err := newSessionGroup(SessionAssignmentPerWorker, testEndpoints, 4, f.open)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.hosts) != 1 {
+		t.Errorf("per-worker group should open 1 session, opened %d", len(f.hosts))
+	}
+	for i := 0; i < 3; i++ {
+		if got := nextHost(t, g, f); got != "h1" {
+			t.Errorf("worker 4 should be pinned to h1, got %s", got)
+		}
+	}
+}
+
+func TestSessionGroupDefaultAssignment(t *testing.T) {
+	f := newFakeOpener()
+	g, err := newSessionGroup("", testEndpoints, 2, f.open)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.hosts) != 1 {
+		t.Errorf("default group should open 1 session like per-worker, opened %d", len(f.hosts))
+	}
+	if got := nextHost(t, g, f); got != "h2" {
+		t.Errorf("worker 2 should be pinned to h2, got %s", got)
+	}
+}
+
+func TestSessionGroupRoundRobin(t *testing.T) {
+	f := newFakeOpener()
+	g, err := newSessionGroup(SessionAssignmentRoundRobin, testEndpoints, 2, f.open)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"h2", "h0", "h1", "h2"}
+	for i, w := range want {
+		if got := nextHost(t, g, f); got != w {
+			t.Errorf("request %d: got host %s want %s", i, got, w)
+		}
+	}
+}
+
+func TestSessionGroupRandom(t *testing.T) {
+	f := newFakeOpener()
+	g, err := newSessionGroup(SessionAssignmentRandom, testEndpoints, 0, f.open)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if got := nextHost(t, g, f); got == "" {
+			t.Errorf("request %d: session from unknown host", i)
+		}
+	}
+}
+
+func TestSessionGroupFailover(t *testing.T) {
+	f := newFakeOpener("h2")
+	g, err := newSessionGroup(SessionAssignmentPerWorker, testEndpoints, 1, f.open)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, _ := g.Session()
+	if f.hosts[s] != "h1" {
+		t.Fatalf("worker 1 should start on h1, got %s", f.hosts[s])
+	}
+	// h1 drops, h2 cannot be reached, so we should end up on h0
+	s, err = g.Failover(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.hosts[s] != "h0" {
+		t.Errorf("expected failover to h0, got %s", f.hosts[s])
+	}
+	if got := nextHost(t, g, f); got != "h0" {
+		t.Errorf("expected to stay on h0 after failover, got %s", got)
+	}
+	if g.Failovers() != 1 {
+		t.Errorf("expected 1 failover, got %d", g.Failovers())
+	}
+	if _, err = g.Failover(s); err != errNoHealthyEndpoint {
+		t.Errorf("expected errNoHealthyEndpoint, got %v", err)
+	}
+}
+
+func TestSessionGroupAllDown(t *testing.T) {
+	f := newFakeOpener("h0", "h1", "h2")
+	if _, err := newSessionGroup(SessionAssignmentPerWorker, testEndpoints, 0, f.open); err == nil {
+		t.Errorf("expected error when no endpoint can be opened")
+	}
+	if _, err := newSessionGroup(SessionAssignmentRoundRobin, testEndpoints, 0, f.open); err == nil {
+		t.Errorf("expected error when no endpoint can be opened")
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	cases := []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{desc: "transport error", err: thrift.NewTTransportExceptionFromError(io.EOF), want: true},
+		{desc: "net error", err: &net.OpError{Op: "read", Err: errors.New("connection reset")}, want: true},
+		{desc: "sql error", err: errors.New("Error occurred during executing"), want: false},
+		{desc: "deadline exceeded", err: context.DeadlineExceeded, want: false},
+		{desc: "wrapped cancel", err: fmt.Errorf("query: %w", context.Canceled), want: false},
+	}
+	for _, c := range cases {
+		if got := IsConnectionError(c.err); got != c.want {
+			t.Errorf("%s: got %v want %v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestSessionGroupReconnect(t *testing.T) {
+	f := newFakeOpener()
+	g, err := newSessionGroup(SessionAssignmentPerWorker, testEndpoints[:2], 0, f.open)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, _ := g.Session()
+	if s, err = g.Failover(s); err != nil || f.hosts[s] != "h1" {
+		t.Fatalf("expected failover to h1, got %s, %v", f.hosts[s], err)
+	}
+	// without a reconnect interval h0 stays dead
+	if _, err = g.Failover(s); err != errNoHealthyEndpoint {
+		t.Fatalf("expected errNoHealthyEndpoint, got %v", err)
+	}
+	g.reconnectAfter = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if got := nextHost(t, g, f); got == "" {
+		t.Errorf("expected a dead endpoint to be tried again after the reconnect interval")
+	}
+}
+
+func TestSessionGroupDetach(t *testing.T) {
+	f := newFakeOpener()
+	g, err := newSessionGroup(SessionAssignmentPerWorker, testEndpoints, 0, f.open)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, _ := g.Session()
+	g.Detach(s)
+	next, err := g.Session()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if next == s || f.hosts[next] != "h0" {
+		t.Errorf("expected a new session to h0 after detaching, got %s", f.hosts[next])
+	}
+	if g.Failovers() != 0 {
+		t.Errorf("detaching should not count as a failover, got %d", g.Failovers())
+	}
+}
@@ -0,0 +1,117 @@
+package iginx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/thulab/iginx-client-go/client"
+)
+
+// SessionPool shares a fixed number of session groups among any number of
+// workers, so that the number of connections to IginX does not depend on
+// the number of workers. Groups open their sessions on first use and those
+// left idle for longer than the health check interval are checked before
+// being handed out again. A SessionPool is safe for concurrent use, the
+// groups it hands out are not.
+type SessionPool struct {
+	groups              chan *SessionGroup
+	healthCheckInterval time.Duration
+}
+
+// NewSessionPool returns a pool of size session groups, opened lazily. A
+// zero healthCheckInterval disables health checks.
+func (c *SpecificConfig) NewSessionPool(size int, healthCheckInterval time.Duration) (*SessionPool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("session pool size must be at least 1, got %d", size)
+	}
+	endpoints, err := c.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+	return newSessionPool(size, healthCheckInterval, func(num int) *SessionGroup {
+		g := newLazySessionGroup(c.SessionAssignment, endpoints, num, c.openSession)
+		g.reconnectAfter = c.ReconnectInterval
+		return g
+	}), nil
+}
+
+func newSessionPool(size int, healthCheckInterval time.Duration, newGroup func(num int) *SessionGroup) *SessionPool {
+	p := &SessionPool{
+		groups:              make(chan *SessionGroup, size),
+		healthCheckInterval: healthCheckInterval,
+	}
+	for i := 0; i < size; i++ {
+		p.groups <- newGroup(i)
+	}
+	return p
+}
+
+// Get waits until a session group is free or ctx is done. The group must be
+// handed back with Put.
+func (p *SessionPool) Get(ctx context.Context) (*SessionGroup, error) {
+	var g *SessionGroup
+	select {
+	case g = <-p.groups:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if p.healthCheckInterval > 0 && !g.idleSince.IsZero() && time.Since(g.idleSince) > p.healthCheckInterval {
+		if err := g.HealthCheck(); err != nil {
+			log.Printf("IginX session health check failed: %v", err)
+		}
+	}
+	return g, nil
+}
+
+// Put hands a session group back to the pool.
+func (p *SessionPool) Put(g *SessionGroup) {
+	g.idleSince = time.Now()
+	p.groups <- g
+}
+
+// Close closes the sessions of all groups, which must have been handed back.
+func (p *SessionPool) Close() {
+	groups := make([]*SessionGroup, cap(p.groups))
+	for i := range groups {
+		groups[i] = <-p.groups
+		groups[i].Close()
+	}
+	for _, g := range groups {
+		p.groups <- g
+	}
+}
+
+// ExecuteSQL executes a statement on a session of the group and gives up on
+// it once ctx is done. The session of a statement that is given up on is
+// detached from the group and closed once the statement is over, as it
+// cannot be used in the meantime. Nothing is sent if ctx is already done.
+func ExecuteSQL(ctx context.Context, g *SessionGroup, s *client.Session, sql string) (*client.SQLDataSet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type result struct {
+		ds  *client.SQLDataSet
+		err error
+	}
+	done := make(chan result)
+	abandoned := make(chan struct{})
+	go func() {
+		ds, err := s.ExecuteSQL(sql)
+		select {
+		case done <- result{ds, err}:
+		case <-abandoned:
+			_ = s.Close()
+		}
+	}()
+
+	select {
+	case r := <-done:
+		return r.ds, r.err
+	case <-ctx.Done():
+		close(abandoned)
+		g.Detach(s)
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,76 @@
+package iginx
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSessionPool(t *testing.T) {
+	f := newFakeOpener()
+	p := newSessionPool(2, 0, func(num int) *SessionGroup {
+		return newLazySessionGroup(SessionAssignmentPerWorker, testEndpoints, num, f.open)
+	})
+	if len(f.hosts) != 0 {
+		t.Errorf("pool should open sessions lazily, opened %d", len(f.hosts))
+	}
+
+	ctx := context.Background()
+	g0, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g1, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g0 == g1 {
+		t.Errorf("pool handed out the same group twice")
+	}
+	if nextHost(t, g0, f) != "h0" || nextHost(t, g1, f) != "h1" {
+		t.Errorf("pooled groups should start on different hosts")
+	}
+
+	// the pool is exhausted, so Get waits until ctx is done
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := p.Get(cancelled); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	p.Put(g1)
+	if g, err := p.Get(ctx); err != nil || g != g1 {
+		t.Errorf("expected to get the group handed back, got %v", err)
+	}
+	if g1.idleSince.IsZero() {
+		t.Errorf("expected Put to record when the group went idle")
+	}
+}
+
+func TestExecuteSQLExpired(t *testing.T) {
+	f := newFakeOpener()
+	g, err := newSessionGroup(SessionAssignmentPerWorker, testEndpoints, 0, f.open)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err := g.Session()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	_, err = ExecuteSQL(ctx, g, s, "SELECT usage_user FROM cpu")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if IsConnectionError(err) {
+		t.Errorf("an expired query should not be taken for a lost session")
+	}
+	if g.Failovers() != 0 {
+		t.Errorf("an expired query should not fail over, got %d failovers", g.Failovers())
+	}
+	if got, err := g.Session(); err != nil || got != s {
+		t.Errorf("an expired query should keep its session, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package iginx
+
+import (
+	"bytes"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/serialize"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+// simulationDataSource serializes the simulated points the same way
+// tsbs_generate_data would, so that batches look identical to the ones
+// read from a file.
+type simulationDataSource struct {
+	simulator  common.Simulator
+	headers    *common.GeneratedDataHeaders
+	serializer serialize.PointSerializer
+	buf        *bytes.Buffer
+}
+
+func newSimulationDataSource(sim common.Simulator, serializer serialize.PointSerializer) targets.DataSource {
+	return &simulationDataSource{
+		simulator:  sim,
+		headers:    sim.Headers(),
+		serializer: serializer,
+		buf:        &bytes.Buffer{},
+	}
+}
+
+func (d *simulationDataSource) Headers() *common.GeneratedDataHeaders {
+	if d.headers != nil {
+		return d.headers
+	}
+
+	d.headers = d.simulator.Headers()
+	return d.headers
+}
+
+func (d *simulationDataSource) NextItem() data.LoadedPoint {
+	newSimulatorPoint := data.NewPoint()
+	for !d.simulator.Finished() {
+		write := d.simulator.Next(newSimulatorPoint)
+		if !write {
+			newSimulatorPoint.Reset()
+			continue
+		}
+
+		d.buf.Reset()
+		if err := d.serializer.Serialize(newSimulatorPoint, d.buf); err != nil {
+			fatal("could not serialize simulated point: %v", err)
+			return data.LoadedPoint{}
+		}
+		// points with only nil fields are not serialized at all
+		if d.buf.Len() == 0 {
+			newSimulatorPoint.Reset()
+			continue
+		}
+
+		// the batch keeps the bytes of the point, so they can't share d.buf,
+		// and the trailing newline is added back by the batch
+		line := bytes.TrimSuffix(d.buf.Bytes(), newLine)
+		return data.NewLoadedPoint(append([]byte(nil), line...))
+	}
+	return data.LoadedPoint{}
+}
@@ -0,0 +1,78 @@
+package iginx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+)
+
+// testSimulator replays a fixed list of points, skipping the ones that
+// should not be written.
+type testSimulator struct {
+	points []*data.Point
+	write  []bool
+	next   int
+}
+
+func (s *testSimulator) Finished() bool { return s.next >= len(s.points) }
+
+func (s *testSimulator) Next(p *data.Point) bool {
+	src := s.points[s.next]
+	write := s.write[s.next]
+	s.next++
+	p.SetMeasurementName(src.MeasurementName())
+	p.SetTimestamp(src.Timestamp())
+	for i, k := range src.TagKeys() {
+		p.AppendTag(k, src.TagValues()[i])
+	}
+	for i, k := range src.FieldKeys() {
+		p.AppendField(k, src.FieldValues()[i])
+	}
+	return write
+}
+
+func (s *testSimulator) Fields() map[string][]string           { return nil }
+func (s *testSimulator) TagKeys() []string                     { return nil }
+func (s *testSimulator) TagTypes() []string                    { return nil }
+func (s *testSimulator) Headers() *common.GeneratedDataHeaders { return nil }
+
+func TestSimulationDataSource(t *testing.T) {
+	ts := time.Unix(1451606400, 0)
+	newPoint := func(host string, value interface{}) *data.Point {
+		p := data.NewPoint()
+		p.SetMeasurementName([]byte("cpu"))
+		p.SetTimestamp(&ts)
+		p.AppendTag([]byte("hostname"), host)
+		p.AppendField([]byte("usage_user"), value)
+		return p
+	}
+	sim := &testSimulator{
+		points: []*data.Point{
+			newPoint("host_0", 1.5),
+			newPoint("host_1", 2.5),
+			newPoint("host_2", nil),
+			newPoint("host_3", 3.5),
+		},
+		write: []bool{true, false, true, true},
+	}
+	ds := newSimulationDataSource(sim, &Serializer{})
+
+	want := []string{
+		"cpu,hostname=host_0 usage_user=1.5 1451606400000000000",
+		"cpu,hostname=host_3 usage_user=3.5 1451606400000000000",
+	}
+	for _, w := range want {
+		p := ds.NextItem()
+		if p.Data == nil {
+			t.Fatalf("expected line %s, got nothing", w)
+		}
+		if got := string(p.Data.([]byte)); got != w {
+			t.Errorf("incorrect line: got %s want %s", got, w)
+		}
+	}
+	if p := ds.NextItem(); p.Data != nil {
+		t.Errorf("expected no more points, got %s", p.Data)
+	}
+}
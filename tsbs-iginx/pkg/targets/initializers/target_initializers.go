@@ -48,6 +48,8 @@ func GetTarget(format string) targets.ImplementedTarget {
 		return questdb.NewTarget()
 	case constants.FormatIginx:
 		return iginx.NewTarget()
+	case constants.FormatIginxNative:
+		return iginx.NewNativeTarget()
 	}
 
 	supportedFormatsStr := strings.Join(constants.SupportedFormats(), ",")
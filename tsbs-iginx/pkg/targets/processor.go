@@ -14,3 +14,12 @@ type ProcessorCloser interface {
 	// Close cleans up after a Processor
 	Close(doLoad bool)
 }
+
+// ProcessorTotals is a Processor that also keeps target-specific counters
+// (e.g., retries) which should be reported together with the load summary
+type ProcessorTotals interface {
+	Processor
+	// Totals returns the counters of the Processor by name. It is called
+	// once the Processor has processed all its batches
+	Totals() map[string]uint64
+}